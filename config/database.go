@@ -3,8 +3,11 @@ package config
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 var DB *sql.DB
@@ -20,11 +23,13 @@ func ConnectToDb() {
 
 	var err error
 
-	DB, err = sql.Open("postgres", dataSource)
+	// wrapped so every statement shows up as a span under the request that ran it
+	DB, err = otelsql.Open("postgres", dataSource, otelsql.WithAttributes(semconv.DBSystemPostgreSQL))
 
 	if err != nil {
-		log.Fatal("failed to connect to DB: ", err)
+		slog.Error("failed to connect to DB", "error", err)
+		os.Exit(1)
 	}
 
-	log.Print("Successfully connected to DB")
+	slog.Info("successfully connected to DB", "host", dbHost, "database", dbName)
 }
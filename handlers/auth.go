@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"database/sql"
-	"fmt"
 	"net/http"
 	"postswapapi/config"
 	"postswapapi/models"
@@ -16,22 +15,37 @@ import (
 
 //Handler to handle the registration process within the db
 
+// @Summary Register a new account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.UserRegistrationRequest true "Credentials"
+// @Success 201 {object} utils.Response{data=models.AuthResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /register [post]
 func Register(ctx *gin.Context) {
 	var req models.UserRegistrationRequest
 
 	if err := ctx.ShouldBind(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
 	//Check if the user exists in the db when registering
 	var existingID uuid.UUID
 
-	err := config.DB.QueryRow("SELECT user_id FROM users WHERE email = $1",
+	err := config.DB.QueryRowContext(ctx.Request.Context(), "SELECT user_id FROM users WHERE email = $1",
 		req.Email).Scan(&existingID)
 
+	if err == nil {
+		utils.HandleError(ctx, utils.NewConflict("An account with this email already exists"))
+		return
+	}
+
 	if err != sql.ErrNoRows {
-		utils.ErrorResponse(ctx, http.StatusConflict, err.Error())
+		utils.HandleError(ctx, utils.NewInternal("Failed to register user", err))
 		return
 	}
 
@@ -54,11 +68,11 @@ func Register(ctx *gin.Context) {
 
 	//Insert into the db the credentials from the registration model
 
-	_, err = config.DB.Exec(`INSERT INTO users (user_id, email, password_hash, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)`,
+	_, err = config.DB.ExecContext(ctx.Request.Context(), `INSERT INTO users (user_id, email, password_hash, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)`,
 		user.User_ID, user.Email, hashedPassword, user.Created_at, user.Updated_at)
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		utils.HandleError(ctx, utils.NewInternal("Failed to register user", err))
 		return
 	}
 
@@ -68,19 +82,30 @@ func Register(ctx *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusCreated, "User Created Successfully", gin.H{
-		"user":  user,
-		"token": token,
+	utils.SuccessResponse(ctx, http.StatusCreated, "User Created Successfully", models.AuthResponse{
+		User:  user,
+		Token: token,
 	})
 
 }
 
+// @Summary Set up the profile of the signed in user
+// @Description avatar_url is the media_id or url of one of your uploads, anything else is rejected.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.UserProfileSetUpRequest true "Profile details"
+// @Success 202 {object} utils.Response{data=models.ProfileSetUpResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /profileSetUp [post]
 func UserProfileSetUp(ctx *gin.Context) {
 	var req models.UserProfileSetUpRequest
 
 	if err := ctx.ShouldBind(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
-		fmt.Println(err)
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -98,14 +123,36 @@ func UserProfileSetUp(ctx *gin.Context) {
 		return
 	}
 
-	_, err := config.DB.Exec(`
+	tx, err := config.DB.BeginTx(ctx.Request.Context(), nil)
+	if err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to set up profile", err))
+		return
+	}
+
+	defer tx.Rollback()
+
+	//the avatar has to be one of the user's uploads, it is only marked in use if the profile is saved
+
+	avatar, err := attachMedia(ctx.Request.Context(), tx, user.User_ID, "avatar_url", []string{req.Avatar_url})
+	if err != nil {
+		utils.HandleError(ctx, err)
+		return
+	}
+	req.Avatar_url = avatar[0].URL
+
+	_, err = tx.ExecContext(ctx.Request.Context(), `
 	   UPDATE USERS
 	   SET first_name = $1, last_name = $2, avatar_url = $3
 	   WHERE user_id = $4
 	`, req.First_Name, req.Last_Name, req.Avatar_url, user.User_ID)
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		utils.HandleError(ctx, utils.NewInternal("Failed to set up profile", err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to set up profile", err))
 		return
 	}
 
@@ -115,18 +162,28 @@ func UserProfileSetUp(ctx *gin.Context) {
 		Avatar_url: &req.Avatar_url,
 	}
 
-	utils.SuccessResponse(ctx, http.StatusAccepted, "Profile Successfully Set Up", gin.H{
-		"profile_Update": SetUp,
+	utils.SuccessResponse(ctx, http.StatusAccepted, "Profile Successfully Set Up", models.ProfileSetUpResponse{
+		ProfileUpdate: SetUp,
 	})
 
 }
 
 // Handler to handle the login process within the db
+// @Summary Log in with email and password
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.UserLoginRequest true "Credentials"
+// @Success 200 {object} utils.Response{data=models.AuthResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /login [post]
 func Login(ctx *gin.Context) {
 	var req models.UserLoginRequest
 
 	if err := ctx.ShouldBind(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -135,7 +192,7 @@ func Login(ctx *gin.Context) {
 
 	//Goes into the db to return a row that contains the relation between the inputed credentials and the one in the db
 
-	err := config.DB.QueryRow(`
+	err := config.DB.QueryRowContext(ctx.Request.Context(), `
 	  SELECT user_id, email, password_hash, created_at, updated_at FROM users
 	  WHERE email = $1
 	`, req.Email).Scan(
@@ -151,7 +208,7 @@ func Login(ctx *gin.Context) {
 	}
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		utils.HandleError(ctx, utils.NewInternal("Failed to log in", err))
 		return
 	}
 
@@ -167,20 +224,31 @@ func Login(ctx *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusOK, "Login Successful", gin.H{
-		"user":  user,
-		"token": token,
+	utils.SuccessResponse(ctx, http.StatusOK, "Login Successful", models.AuthResponse{
+		User:  user,
+		Token: token,
 	})
 
 }
 
 //Get location of the user
 
+// @Summary Save the location of the signed in user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.UserLocationRequest true "Location"
+// @Success 201 {object} utils.Response{data=models.LocationResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /location [post]
 func GetLocation(ctx *gin.Context) {
 	var req models.UserLocationRequest
 
 	if err := ctx.ShouldBind(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -202,7 +270,7 @@ func GetLocation(ctx *gin.Context) {
 		Location: req.Location,
 	}
 
-	_, err := config.DB.Exec(`
+	_, err := config.DB.ExecContext(ctx.Request.Context(), `
 	   UPDATE users
 	   SET location = $1 
 	   WHERE user_id = $2
@@ -213,25 +281,42 @@ func GetLocation(ctx *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusCreated, "location Added", gin.H{
-		"location": location,
-		"user":     user,
+	utils.SuccessResponse(ctx, http.StatusCreated, "location Added", models.LocationResponse{
+		Location: location,
+		User:     user,
 	})
 
 }
 
 //Handler to check if the user exists
 
+// @Summary Get the signed in user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.Users}
+// @Failure 401 {object} utils.Response
+// @Router /me [get]
 func GetUser(ctx *gin.Context) {
 	user, exist := ctx.Get("User")
 
 	if !exist {
 		utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
 	}
 
 	utils.SuccessResponse(ctx, http.StatusOK, "User Found", user)
 }
 
+// @Summary Get the profile of the signed in user
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.Users}
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /userProfile [get]
 func UserProfile(ctx *gin.Context) {
 
 	presentUser, exists := ctx.Get("User")
@@ -250,10 +335,10 @@ func UserProfile(ctx *gin.Context) {
 
 	var User models.Users
 
-	err := config.DB.QueryRow(`
-	    SELECT user_id, email, first_name, last_name, avatar_url FROM users
+	err := config.DB.QueryRowContext(ctx.Request.Context(), `
+	    SELECT user_id, email, first_name, last_name, avatar_url, preferred_language FROM users
 		WHERE user_id = $1 
-	`, user.User_ID).Scan(&User.User_ID, &User.Email, &User.First_Name, &User.Last_Name, &User.Avatar_url)
+	`, user.User_ID).Scan(&User.User_ID, &User.Email, &User.First_Name, &User.Last_Name, &User.Avatar_url, &User.Preferred_language)
 
 	if err == sql.ErrNoRows {
 		utils.ErrorResponse(ctx, http.StatusNotFound, "User not found")
@@ -270,6 +355,17 @@ func UserProfile(ctx *gin.Context) {
 }
 
 // updates user's online status
+// @Summary Update the online status of the signed in user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.UpdateOnlineStatusRequest true "Status"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /users/status [put]
 func UpdateOnlineStatus(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -277,12 +373,10 @@ func UpdateOnlineStatus(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		IsOnline bool `json:"is_online" binding:"required"`
-	}
+	var req models.UpdateOnlineStatusRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
@@ -293,7 +387,7 @@ func UpdateOnlineStatus(c *gin.Context) {
         WHERE user_id = $2
     `
 
-	_, err = config.DB.Exec(query, req.IsOnline, userID)
+	_, err = config.DB.ExecContext(c.Request.Context(), query, *req.IsOnline, userID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to update status")
 		return
@@ -302,7 +396,56 @@ func UpdateOnlineStatus(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "status updated", nil)
 }
 
+// sets the language notifications, pushes and digests are rendered in
+// @Summary Set the preferred language of the signed in user
+// @Description Notifications already received are shown in the new language the next time they are fetched.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.UserLanguageRequest true "Language"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /users/language [put]
+func UpdateLanguage(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.UserLanguageRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	query := `
+        UPDATE users 
+        SET preferred_language = $1, updated_at = NOW()
+        WHERE user_id = $2
+    `
+
+	_, err = config.DB.ExecContext(c.Request.Context(), query, req.Preferred_language, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to update language")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "language updated", nil)
+}
+
 // gets a user's online status
+// @Summary Get a user's online status
+// @Tags users
+// @Produce json
+// @Param user_id path string true "User ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.UserStatusResponse}
+// @Failure 404 {object} utils.Response
+// @Router /users/{user_id}/status [get]
 func GetUserStatus(c *gin.Context) {
 	userID := c.Param("user_id")
 
@@ -317,14 +460,14 @@ func GetUserStatus(c *gin.Context) {
         WHERE user_id = $1
     `
 
-	err := config.DB.QueryRow(query, userID).Scan(&result.IsOnline, &result.LastSeen)
+	err := config.DB.QueryRowContext(c.Request.Context(), query, userID).Scan(&result.IsOnline, &result.LastSeen)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "user not found")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "status retrieved", gin.H{
-		"is_online": result.IsOnline,
-		"last_seen": result.LastSeen,
+	utils.SuccessResponse(c, http.StatusOK, "status retrieved", models.UserStatusResponse{
+		IsOnline: result.IsOnline,
+		LastSeen: result.LastSeen,
 	})
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"postswapapi/models"
+	"postswapapi/services"
+	"postswapapi/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceHandler struct {
+	push *services.PushService
+}
+
+func NewDeviceHandler(push *services.PushService) *DeviceHandler {
+	return &DeviceHandler{push: push}
+}
+
+// RegisterDevice registers a push token for the signed in user
+// POST /api/devices
+// @Summary Register a device for push notifications
+// @Description Call on every app start, registering the same token again just refreshes it. A token moves to you if another account registered it on the same device.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.RegisterDeviceRequest true "FCM registration token and platform"
+// @Success 200 {object} utils.Response{data=models.Device}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /devices [post]
+func (h *DeviceHandler) RegisterDevice(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	device, err := h.push.RegisterDevice(c.Request.Context(), userID, req.Token, req.Platform)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "device registered", device)
+}
+
+// UnregisterDevice stops pushes to a device, e.g. on sign out
+// DELETE /api/devices
+// @Summary Unregister a device from push notifications
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.UnregisterDeviceRequest true "Token to remove"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /devices [delete]
+func (h *DeviceHandler) UnregisterDevice(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.UnregisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	if err := h.push.UnregisterDevice(c.Request.Context(), userID, req.Token); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "device unregistered", nil)
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"postswapapi/config"
+	"postswapapi/models"
+	"postswapapi/services"
+	"postswapapi/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	sseKeepAlive = 25 * time.Second
+	// streams re-read the log this often, wakeups only come from events logged on this instance
+	ssePollInterval = 5 * time.Second
+)
+
+// eventService is used by the package level handlers (notifications, products) to push to /events streams.
+// It stays nil when the event stream is not wired up, publishing is then a no-op.
+var eventService *services.EventService
+
+// SetEventService wires the event log into the notification and product handlers
+func SetEventService(service *services.EventService) {
+	eventService = service
+}
+
+// outbox is woken after notifications commit so their events go out straight away, nil just means they wait for the next poll
+var outbox *services.OutboxDispatcher
+
+// SetOutbox wires the outbox dispatcher into the notification handlers
+func SetOutbox(dispatcher *services.OutboxDispatcher) {
+	outbox = dispatcher
+}
+
+type EventHandler struct {
+	events   *services.EventService
+	presence *services.PresenceService
+}
+
+func NewEventHandler(events *services.EventService, presence *services.PresenceService) *EventHandler {
+	return &EventHandler{
+		events:   events,
+		presence: presence,
+	}
+}
+
+// Stream serves the user's events as Server-Sent Events
+// GET /api/events
+// Send Last-Event-ID (or ?last_event_id) to replay everything logged after that id before going live.
+// @Summary Stream notifications and feed updates
+// @Description Server-Sent Events stream. Event names are notification, unread_count, match and product_status; each id can be sent back as Last-Event-ID to resume.
+// @Tags events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param Last-Event-ID header int false "Resume after this event id"
+// @Param last_event_id query int false "Resume after this event id, for clients that cannot set headers"
+// @Success 200 {object} models.UserEvent
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /events [get]
+func (h *EventHandler) Stream(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	lastEventID := int64(0)
+	lastEventIDStr := c.GetHeader("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = c.Query("last_event_id")
+	}
+	if lastEventIDStr != "" {
+		lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || lastEventID < 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	reqCtx := c.Request.Context()
+
+	// subscribe before replaying so an event logged while the backlog is read still wakes the stream
+	wakeups, unsubscribe := h.events.Subscribe(userID)
+	defer unsubscribe()
+
+	cursor := services.NewEventCursor(lastEventID)
+
+	after, err := h.events.Rewind(reqCtx, cursor)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	backlog, err := h.events.EventsAfter(reqCtx, userID, after)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	after = writeUserEvents(c, cursor, backlog, after)
+	if err := h.sendEventsAfter(c, userID, cursor, after); err != nil {
+		slog.WarnContext(reqCtx, "failed to replay events", "user_id", userID, "error", err)
+		return
+	}
+
+	// an open stream counts as presence
+	h.presence.Touch(reqCtx, userID)
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	poll := time.NewTicker(ssePollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-reqCtx.Done():
+			return
+		case <-wakeups:
+			// the log is read rather than the wakeup trusted, so nothing the cursor hasn't sent is skipped
+			if err := h.sendNewEvents(c, userID, cursor); err != nil {
+				slog.WarnContext(reqCtx, "failed to read events", "user_id", userID, "error", err)
+				return
+			}
+		case <-poll.C:
+			// picks up events logged by other instances and settles ids that committed out of order
+			if err := h.sendNewEvents(c, userID, cursor); err != nil {
+				slog.WarnContext(reqCtx, "failed to read events", "user_id", userID, "error", err)
+				return
+			}
+		case <-keepAlive.C:
+			// comment lines keep proxies from timing out an idle stream
+			if _, err := c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			h.presence.Touch(reqCtx, userID)
+		}
+	}
+}
+
+// sendNewEvents reads the log again from the oldest event the cursor may have missed and writes what it hasn't sent
+func (h *EventHandler) sendNewEvents(c *gin.Context, userID uuid.UUID, cursor *services.EventCursor) error {
+	after, err := h.events.Rewind(c.Request.Context(), cursor)
+	if err != nil {
+		return err
+	}
+	return h.sendEventsAfter(c, userID, cursor, after)
+}
+
+// sendEventsAfter writes the events logged after the id the cursor hasn't sent, a page at a time until the log
+// is exhausted
+func (h *EventHandler) sendEventsAfter(c *gin.Context, userID uuid.UUID, cursor *services.EventCursor, after int64) error {
+	for {
+		events, err := h.events.EventsAfter(c.Request.Context(), userID, after)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			c.Writer.Flush()
+			return nil
+		}
+		after = writeUserEvents(c, cursor, events, after)
+	}
+}
+
+// writeUserEvents writes the page's events the cursor hasn't sent and returns the id to read the next page after
+func writeUserEvents(c *gin.Context, cursor *services.EventCursor, events []models.UserEvent, after int64) int64 {
+	for _, event := range cursor.Unsent(events) {
+		writeUserEvent(c, event)
+	}
+	if len(events) > 0 {
+		after = events[len(events)-1].ID
+	}
+	return after
+}
+
+func writeUserEvent(c *gin.Context, event models.UserEvent) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatInt(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
+
+// publishUserEvent logs an event for the user, failures are logged and never fail the request
+func publishUserEvent(ctx context.Context, userID uuid.UUID, eventType string, payload any) {
+	if eventService == nil {
+		return
+	}
+
+	if err := eventService.Publish(ctx, userID, eventType, payload); err != nil {
+		slog.WarnContext(ctx, "failed to publish user event", "event", eventType, "user_id", userID, "error", err)
+	}
+}
+
+// publishUnreadCount pushes the user's current unread notification count
+func publishUnreadCount(ctx context.Context, userID uuid.UUID) {
+	if eventService == nil {
+		return
+	}
+
+	var count int
+
+	err := config.DB.QueryRowContext(ctx, `
+	   SELECT COUNT(*) FROM notifications
+	   WHERE user_id = $1 AND is_read = false AND is_hidden = false
+	`, userID).Scan(&count)
+
+	if err != nil {
+		slog.WarnContext(ctx, "failed to count unread notifications", "user_id", userID, "error", err)
+		return
+	}
+
+	publishUserEvent(ctx, userID, models.EventUnreadCount, gin.H{"unread_count": count})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"postswapapi/models"
+	"postswapapi/services"
+	"postswapapi/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// feedService ranks the For You feed, nil serves the latest feed for every mode
+var feedService *services.FeedService
+
+// SetFeedService wires the For You ranking into GetProducts
+func SetFeedService(service *services.FeedService) {
+	feedService = service
+}
+
+// getForYouFeed serves a page of the ranked feed for GetProducts
+func getForYouFeed(ctx *gin.Context, viewerID uuid.UUID, category string) {
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	var cursor *models.FeedCursor
+	if raw := ctx.Query("cursor"); raw != "" {
+		if cursor, err = models.DecodeFeedCursor(raw); err != nil {
+			utils.ErrorResponse(ctx, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		offset = cursor.Offset
+	}
+
+	items, next, err := feedService.ForYou(ctx.Request.Context(), viewerID, category, limit, offset, cursor)
+	if err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to fetch products", err))
+		return
+	}
+
+	var nextOffset *int
+	var nextCursor *string
+	if next != nil {
+		nextOffset = &next.Offset
+		encoded := next.Encode()
+		nextCursor = &encoded
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Products retrieved successfully", models.FeedResponse{
+		Items: items,
+		Meta: &models.PaginationMeta{
+			Limit:       limit,
+			Offset:      offset,
+			Has_more:    next != nil,
+			Next_offset: nextOffset,
+		},
+		NextCursor: nextCursor,
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"postswapapi/models"
+	"postswapapi/services"
+	"postswapapi/utils"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// mediaService checks that image URLs in product and profile requests are the caller's uploads.
+// It stays nil when media is not wired up, the URLs are then stored as sent.
+var mediaService *services.MediaService
+
+// SetMediaService wires upload ownership checks into the product and profile handlers
+func SetMediaService(service *services.MediaService) {
+	mediaService = service
+}
+
+// attachMedia resolves media IDs or URLs to the user's uploads, marking them in use as part of tx
+func attachMedia(ctx context.Context, tx *sql.Tx, userID uuid.UUID, field string, refs []string) ([]models.Media, error) {
+	if mediaService == nil {
+		media := make([]models.Media, len(refs))
+		for i, ref := range refs {
+			media[i].URL = ref
+		}
+		return media, nil
+	}
+	return mediaService.Attach(ctx, tx, userID, field, refs)
+}
+
+type MediaHandler struct {
+	media *services.MediaService
+}
+
+func NewMediaHandler(media *services.MediaService) *MediaHandler {
+	return &MediaHandler{media: media}
+}
+
+// SignUpload returns parameters for uploading a file straight to storage
+// POST /api/media/uploads
+// @Summary Sign a direct upload
+// @Description Upload the file to upload_url with the returned method and fields within the hour, then confirm the storage_key with POST /media.
+// @Tags uploads
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.SignUploadRequest true "What the file is for and its content type"
+// @Success 200 {object} utils.Response{data=models.SignedUpload}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /media/uploads [post]
+func (h *MediaHandler) SignUpload(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.SignUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	upload, err := h.media.SignUpload(c.Request.Context(), userID, req.Purpose, req.ContentType)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "upload signed", upload)
+}
+
+// ConfirmUpload records a file uploaded with a signed upload
+// POST /api/media
+// @Summary Confirm a direct upload
+// @Description Checks the file is in storage and is a JPEG, PNG, WebP or HEIC image within the size limits, strips its metadata, makes thumbnail and feed size copies and records it as yours. Confirming the same storage_key again returns the same media.
+// @Tags uploads
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.ConfirmMediaRequest true "The storage_key from the signed upload"
+// @Success 201 {object} utils.Response{data=models.Media}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /media [post]
+func (h *MediaHandler) ConfirmUpload(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.ConfirmMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	media, err := h.media.Confirm(c.Request.Context(), userID, req.StorageKey, req.Purpose)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "upload confirmed", media)
+}
+
+// ReceiveLocalUpload takes the file of a signed upload when media is stored on the local filesystem.
+// The signature in the query authorizes it, there is no bearer token.
+// PUT /api/media/local/{key}
+func (h *MediaHandler) ReceiveLocalUpload(c *gin.Context) {
+	store, ok := h.media.Store().(*services.LocalMediaStore)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	err := store.ReceiveUpload(c.Request.Context(), strings.TrimPrefix(c.Param("key"), "/"),
+		c.Query("content_type"), c.Query("expires"), c.Query("signature"), c.Request.Body)
+
+	switch {
+	case errors.Is(err, services.ErrInvalidUploadSignature):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrMediaTooLarge):
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "file too large, max 5MB")
+	case errors.Is(err, services.ErrMediaExists):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrMediaNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "not found")
+	case err != nil:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to store upload")
+	default:
+		utils.SuccessResponse(c, http.StatusOK, "file uploaded", nil)
+	}
+}
+
+// ServeLocalFile serves a file from the local filesystem store
+// GET /api/media/files/{key}
+func (h *MediaHandler) ServeLocalFile(c *gin.Context) {
+	store, ok := h.media.Store().(*services.LocalMediaStore)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	// only images are served, whatever else reached the directory unconfirmed stays there
+	object, err := store.Stat(c.Request.Context(), key)
+	if err != nil || !slices.Contains(models.AllowedMediaTypes, object.ContentType) {
+		utils.ErrorResponse(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	file, err := store.Open(key)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "not found")
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	c.Header("Content-Type", object.ContentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime(), file)
+}
+
+// nonEmpty is nil for an empty string, for optional columns
+func nonEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -7,11 +7,20 @@ import (
 	"postswapapi/services"
 	"postswapapi/utils"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// messageService lets the package level product handlers post system messages in product conversations
+var messageService *services.MessageService
+
+// SetMessageService wires chat into the product handlers
+func SetMessageService(service *services.MessageService) {
+	messageService = service
+}
+
 type MessageHandler struct {
 	service *services.MessageService
 }
@@ -24,6 +33,18 @@ func NewMessageHandler(service *services.MessageService) *MessageHandler {
 
 // CreateConversation creates a conversation without sending a message
 // POST /api/conversations
+// @Summary Start a conversation without sending a message
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.CreateConversationRequest true "Recipient, and optionally the product the conversation is about"
+// @Success 201 {object} utils.Response{data=models.ConversationIDResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations [post]
 func (h *MessageHandler) CreateConversation(c *gin.Context) {
 	senderID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -31,12 +52,10 @@ func (h *MessageHandler) CreateConversation(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		RecipientID uuid.UUID `json:"recipient_id" binding:"required"`
-	}
+	var req models.CreateConversationRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
@@ -46,19 +65,200 @@ func (h *MessageHandler) CreateConversation(c *gin.Context) {
 		return
 	}
 
-	conversationID, err := h.service.GetOrCreateConversation(senderID, req.RecipientID)
+	conversationID, err := h.service.GetOrCreateConversation(c.Request.Context(), senderID, req.RecipientID, req.ProductID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "conversation created", models.ConversationIDResponse{
+		ConversationID: conversationID,
+	})
+}
+
+// CreateGroupConversation starts a group conversation
+// POST /api/conversations/groups
+// @Summary Start a group conversation
+// @Description You become the owner. Groups hold up to 10 people including you, for multi-party swaps.
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.CreateGroupConversationRequest true "Title, members and optional product"
+// @Success 201 {object} utils.Response{data=models.ConversationIDResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/groups [post]
+func (h *MessageHandler) CreateGroupConversation(c *gin.Context) {
+	ownerID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.CreateGroupConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	conversationID, err := h.service.CreateGroupConversation(c.Request.Context(), ownerID, req.Title, req.ParticipantIDs, req.ProductID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "group conversation created", models.ConversationIDResponse{
+		ConversationID: conversationID,
+	})
+}
+
+// AddParticipants adds members to a group conversation
+// POST /api/conversations/:conversation_id/participants
+// @Summary Add people to a group conversation
+// @Description Only the owner or an admin can add participants.
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path string true "Conversation ID" format(uuid)
+// @Param body body models.AddParticipantsRequest true "Users to add"
+// @Success 200 {object} utils.Response{data=models.AddParticipantsResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/{conversation_id}/participants [post]
+func (h *MessageHandler) AddParticipants(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	conversationID, err := uuid.Parse(c.Param("conversation_id"))
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to create conversation")
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid conversation ID")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusCreated, "conversation created", gin.H{
-		"conversation_id": conversationID,
+	var req models.AddParticipantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	added, err := h.service.AddParticipants(c.Request.Context(), conversationID, userID, req.UserIDs)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "participants added", models.AddParticipantsResponse{
+		Added: added,
+	})
+}
+
+// RemoveParticipant removes a member from a group conversation, or leaves it when user_id is yourself
+// DELETE /api/conversations/:conversation_id/participants/:user_id
+// @Summary Remove someone from a group conversation, or leave it
+// @Description Anyone can remove themselves. The owner and admins can remove members, only the owner can remove admins.
+// @Tags conversations
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path string true "Conversation ID" format(uuid)
+// @Param user_id path string true "User to remove" format(uuid)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/{conversation_id}/participants/{user_id} [delete]
+func (h *MessageHandler) RemoveParticipant(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	conversationID, err := uuid.Parse(c.Param("conversation_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid conversation ID")
+		return
+	}
+
+	targetID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+
+	err = h.service.RemoveParticipant(c.Request.Context(), conversationID, userID, targetID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "participant removed", nil)
+}
+
+// MessageSeller opens (or reuses) a conversation with the seller about this product
+// POST /api/products/:product_id/conversation
+// @Summary Message the seller about a product
+// @Description The "Message seller" entry point on the product page. Each product gets its own thread with the seller.
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.ConversationIDResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/{product_id}/conversation [post]
+func (h *MessageHandler) MessageSeller(c *gin.Context) {
+	buyerID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	productID, err := uuid.Parse(c.Param("product_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+
+	conversationID, err := h.service.MessageSeller(c.Request.Context(), productID, buyerID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "conversation ready", models.ConversationIDResponse{
+		ConversationID: conversationID,
 	})
 }
 
 // SendMessage creates a new conversation and sends first message
 // POST /api/messages
+// @Summary Send a message, starting a conversation if needed
+// @Description message_type defaults to text, or image when attachments are sent. product_card takes {"product_id"}, swap_offer takes {"offered_product_ids", "requested_product_ids", "note"}, meeting_location takes {"name", "address", "latitude", "longitude", "meet_at"}.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.CreateMessageRequest true "Message"
+// @Success 201 {object} utils.Response{data=models.SendMessageResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /messages [post]
 func (h *MessageHandler) SendMessage(c *gin.Context) {
 	// Get authenticated user ID from context (set by your auth middleware)
 	senderID, err := getUserIDFromContext(c)
@@ -69,7 +269,7 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 
 	var req models.CreateMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
@@ -79,26 +279,34 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Validate: must have either text or image
-	if req.MessageText == "" && req.ImageUrl == nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "message must have text or image")
-		return
-	}
-
-	message, err := h.service.SendMessage(senderID, req.RecipientID, req.MessageText, req.ImageUrl)
+	message, err := h.service.SendMessage(c.Request.Context(), senderID, req.RecipientID, req.MessageContent, req.ProductID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to send message")
+		utils.HandleError(c, err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusCreated, "message sent successfully", gin.H{
-		"message":         message,
-		"conversation_id": message.ConversationID,
+	utils.SuccessResponse(c, http.StatusCreated, "message sent successfully", models.SendMessageResponse{
+		Message:        message,
+		ConversationID: message.ConversationID,
 	})
 }
 
 // SendMessageToConversation sends message to existing conversation
 // POST /api/conversations/:conversation_id/messages
+// @Summary Send a message to an existing conversation
+// @Description message_type defaults to text, or image when attachments are sent. product_card takes {"product_id"}, swap_offer takes {"offered_product_ids", "requested_product_ids", "note"}, meeting_location takes {"name", "address", "latitude", "longitude", "meet_at"}.
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path string true "Conversation ID" format(uuid)
+// @Param body body models.SendMessageRequest true "Message"
+// @Success 201 {object} utils.Response{data=models.MessageResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/{conversation_id}/messages [post]
 func (h *MessageHandler) SendMessageToConversation(c *gin.Context) {
 	senderID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -114,29 +322,40 @@ func (h *MessageHandler) SendMessageToConversation(c *gin.Context) {
 
 	var req models.SendMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(c, err)
 		return
 	}
 
-	// Validate: must have either text or image
-	if (req.MessageText == "" || req.MessageText == " ") && (req.ImageUrl == nil || *req.ImageUrl == "") {
-		utils.ErrorResponse(c, http.StatusBadRequest, "message must have text or image")
-		return
-	}
-
-	message, err := h.service.SendMessageToConversation(conversationID, senderID, req.MessageText, req.ImageUrl)
+	message, err := h.service.SendMessageToConversation(c.Request.Context(), conversationID, senderID, req.MessageContent)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.HandleError(c, err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusCreated, "message sent successfully", gin.H{
-		"message": message,
+	utils.SuccessResponse(c, http.StatusCreated, "message sent successfully", models.MessageResponse{
+		Message: message,
 	})
 }
 
 // GetConversationMessages retrieves messages for a conversation
-// GET /api/conversations/:conversation_id/messages?limit=50&offset=0
+// GET /api/conversations/:conversation_id/messages?limit=50&before=<cursor>
+// Pages are newest first. before_cursor from a response pages to older messages, after_cursor to newer ones.
+// @Summary List messages in a conversation
+// @Description Use the before/after cursors from a previous page rather than offset, offsets shift as new messages arrive.
+// @Tags conversations
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path string true "Conversation ID" format(uuid)
+// @Param limit query int false "Page size (max 100)" default(50)
+// @Param before query string false "Cursor, return messages older than this one"
+// @Param after query string false "Cursor, return messages newer than this one"
+// @Param offset query int false "Deprecated, ignored when a cursor is given" default(0)
+// @Success 200 {object} utils.Response{data=models.MessageListResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/{conversation_id}/messages [get]
 func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -151,35 +370,122 @@ func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
 	}
 
 	// Parse pagination params
-	limit := 50 // default
+	page := models.MessagePageQuery{Limit: 50}
 	if limitStr := c.Query("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+			page.Limit = l
 		}
 	}
 
-	offset := 0 // default
 	if offsetStr := c.Query("offset"); offsetStr != "" {
 		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
+			page.Offset = o
+		}
+	}
+
+	before, after := c.Query("before"), c.Query("after")
+	if before != "" && after != "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "use either before or after, not both")
+		return
+	}
+
+	if before != "" {
+		if page.Before, err = models.DecodeMessageCursor(before); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "invalid before cursor")
+			return
+		}
+	}
+
+	if after != "" {
+		if page.After, err = models.DecodeMessageCursor(after); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "invalid after cursor")
+			return
 		}
 	}
 
-	messages, err := h.service.GetConversationMessages(conversationID, userID, limit, offset)
+	messages, hasMore, err := h.service.GetConversationMessages(c.Request.Context(), conversationID, userID, page)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.HandleError(c, err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "messages retrieved successfully", gin.H{
-		"messages": messages,
-		"limit":    limit,
-		"offset":   offset,
-	})
+	response := models.MessageListResponse{
+		Messages: messages,
+		Limit:    page.Limit,
+		Offset:   page.Offset,
+		HasMore:  hasMore,
+	}
+
+	if len(messages) > 0 {
+		newest, oldest := messages[0], messages[len(messages)-1]
+		beforeCursor := models.MessageCursor{CreatedAt: oldest.CreatedAt, ID: oldest.ID}.Encode()
+		afterCursor := models.MessageCursor{CreatedAt: newest.CreatedAt, ID: newest.ID}.Encode()
+		response.BeforeCursor = &beforeCursor
+		response.AfterCursor = &afterCursor
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "messages retrieved successfully", response)
+}
+
+// SyncConversations returns every chat change since a cursor or point in time for offline catch-up
+// GET /api/conversations/sync?cursor=... or ?since=2024-01-01T00:00:00Z for the first sync
+// @Summary Catch up on all conversations
+// @Description New and edited messages, deletions, read states and receipts across your conversations since the given cursor, or time on the first sync. Repeat with cursor=next_cursor while has_more is true. Changes from the last few seconds come with the next sync.
+// @Tags conversations
+// @Produce json
+// @Security BearerAuth
+// @Param cursor query string false "next_cursor from the previous sync"
+// @Param since query string false "RFC 3339 timestamp to start from when there is no cursor yet" format(date-time)
+// @Success 200 {object} utils.Response{data=models.SyncResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/sync [get]
+func (h *MessageHandler) SyncConversations(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var after models.MessageCursor
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		decoded, err := models.DecodeMessageCursor(cursor)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		after = *decoded
+	} else {
+		since, err := time.Parse(time.RFC3339Nano, c.Query("since"))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "send cursor, or since as an RFC 3339 timestamp")
+			return
+		}
+		// uuid.Nil sorts first, so the cursor sits just before anything changed at since
+		after = models.MessageCursor{CreatedAt: since}
+	}
+
+	sync, err := h.service.Sync(c.Request.Context(), userID, after)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "sync retrieved successfully", sync)
 }
 
 // GetUserConversations retrieves all conversations for the authenticated user
 // GET /api/conversations
+// @Summary List your conversations
+// @Tags conversations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.ConversationListResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations [get]
 func (h *MessageHandler) GetUserConversations(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -187,19 +493,31 @@ func (h *MessageHandler) GetUserConversations(c *gin.Context) {
 		return
 	}
 
-	conversations, err := h.service.GetUserConversations(userID)
+	conversations, err := h.service.GetUserConversations(c.Request.Context(), userID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to get conversations")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "conversations retrieved successfully", gin.H{
-		"conversations": conversations,
+	utils.SuccessResponse(c, http.StatusOK, "conversations retrieved successfully", models.ConversationListResponse{
+		Conversations: conversations,
 	})
 }
 
 // MarkConversationAsRead marks all messages in a conversation as read
 // PUT /api/conversations/:conversation_id/read
+// @Summary Mark a conversation as read
+// @Tags conversations
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path string true "Conversation ID" format(uuid)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/{conversation_id}/read [put]
 func (h *MessageHandler) MarkConversationAsRead(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -213,17 +531,72 @@ func (h *MessageHandler) MarkConversationAsRead(c *gin.Context) {
 		return
 	}
 
-	err = h.service.MarkConversationAsRead(conversationID, userID)
+	err = h.service.MarkConversationAsRead(c.Request.Context(), conversationID, userID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.HandleError(c, err)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "conversation marked as read", nil)
 }
 
+// SetTyping publishes a typing indicator to the other participants
+// POST /api/conversations/:conversation_id/typing
+// @Summary Start or stop the typing indicator
+// @Description Publishes a typing event on the conversation channel. Send is_typing false when the user stops.
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path string true "Conversation ID" format(uuid)
+// @Param body body models.TypingRequest true "Typing state"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /conversations/{conversation_id}/typing [post]
+func (h *MessageHandler) SetTyping(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	conversationID, err := uuid.Parse(c.Param("conversation_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid conversation ID")
+		return
+	}
+
+	var req models.TypingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	err = h.service.SetTyping(c.Request.Context(), conversationID, userID, *req.IsTyping)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "typing state published", nil)
+}
+
 // DeleteMessage soft deletes a message
 // DELETE /api/messages/:message_id
+// @Summary Delete one of your messages
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID" format(uuid)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /messages/{message_id} [delete]
 func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -237,17 +610,112 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 		return
 	}
 
-	err = h.service.DeleteMessage(messageID, userID)
+	err = h.service.DeleteMessage(c.Request.Context(), messageID, userID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.HandleError(c, err)
 		return
 	}
 
 	utils.SuccessResponse(c, http.StatusOK, "message deleted successfully", nil)
 }
 
+// EditMessage changes the text of one of your messages
+// PATCH /api/messages/:message_id
+// @Summary Edit one of your messages
+// @Description Only the sender can edit, and only within the edit window (MESSAGE_EDIT_WINDOW, 15 minutes by default). The previous text is kept in the edit history.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID" format(uuid)
+// @Param body body models.EditMessageRequest true "New text"
+// @Success 200 {object} utils.Response{data=models.MessageResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /messages/{message_id} [patch]
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid message ID")
+		return
+	}
+
+	var req models.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	message, err := h.service.EditMessage(c.Request.Context(), messageID, userID, req.MessageText)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "message edited successfully", models.MessageResponse{
+		Message: message,
+	})
+}
+
+// GetMessageEdits lists the previous versions of a message
+// GET /api/messages/:message_id/edits
+// @Summary Get a message's edit history
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path string true "Message ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.MessageEditListResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /messages/{message_id}/edits [get]
+func (h *MessageHandler) GetMessageEdits(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid message ID")
+		return
+	}
+
+	edits, err := h.service.GetMessageEdits(c.Request.Context(), messageID, userID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "message edits retrieved successfully", models.MessageEditListResponse{
+		Edits: edits,
+	})
+}
+
 // GetAblyToken generates an Ably token for the authenticated user
 // GET /api/messages/ably-token
+// @Summary Get an Ably token for realtime chat
+// @Description Only available when the realtime backend is Ably, the WebSocket backend uses the API token.
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.TokenResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /messages/ably-token [get]
 func (h *MessageHandler) GetAblyToken(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -255,14 +723,14 @@ func (h *MessageHandler) GetAblyToken(c *gin.Context) {
 		return
 	}
 
-	token, err := h.service.GenerateAblyTokenForUser(userID)
+	token, err := h.service.GenerateAblyTokenForUser(c.Request.Context(), userID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to generate token")
+		utils.HandleError(c, err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "token generated successfully", gin.H{
-		"token": token,
+	utils.SuccessResponse(c, http.StatusOK, "token generated successfully", models.TokenResponse{
+		Token: token,
 	})
 }
 
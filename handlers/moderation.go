@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"postswapapi/models"
+	"postswapapi/services"
+	"postswapapi/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ModerationHandler struct {
+	service *services.ModerationService
+}
+
+func NewModerationHandler(service *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{service: service}
+}
+
+// ListFlags returns the moderation queue
+// GET /api/moderation/flags
+// @Summary List flagged listings
+// @Description Listings whose photos closely match another seller's photos or a blocked image, oldest first. Moderators only.
+// @Tags moderation
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Flag status" Enums(open, dismissed, actioned) default(open)
+// @Param limit query int false "Page size" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} utils.Response{data=models.ModerationFlagListResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /moderation/flags [get]
+func (h *ModerationHandler) ListFlags(c *gin.Context) {
+	status := c.DefaultQuery("status", models.FlagStatusOpen)
+	if status != models.FlagStatusOpen && status != models.FlagStatusDismissed && status != models.FlagStatusActioned {
+		utils.ErrorResponse(c, http.StatusBadRequest, "status must be open, dismissed or actioned")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	flags, err := h.service.ListFlags(c.Request.Context(), status, limit+1, offset)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	hasMore := len(flags) > limit
+
+	var nextOffset *int
+	if hasMore {
+		flags = flags[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "flags retrieved", models.ModerationFlagListResponse{
+		Items: flags,
+		Meta: models.PaginationMeta{
+			Limit:       limit,
+			Offset:      offset,
+			Has_more:    hasMore,
+			Next_offset: nextOffset,
+		},
+	})
+}
+
+// ResolveFlag dismisses a flag or removes the flagged listing
+// POST /api/moderation/flags/{flag_id}/resolve
+// @Summary Resolve a flag
+// @Description dismiss closes the flag, remove_listing deactivates the listing and closes all of its open flags. Moderators only.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param flag_id path string true "Flag ID" format(uuid)
+// @Param body body models.ResolveFlagRequest true "What to do"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /moderation/flags/{flag_id}/resolve [post]
+func (h *ModerationHandler) ResolveFlag(c *gin.Context) {
+	moderatorID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	flagID, err := uuid.Parse(c.Param("flag_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid flag ID")
+		return
+	}
+
+	var req models.ResolveFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	if err := h.service.ResolveFlag(c.Request.Context(), flagID, moderatorID, req.Action); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "flag resolved", nil)
+}
+
+// ListBlockedImages returns the image blocklist
+// GET /api/moderation/blocked-images
+// @Summary List blocked images
+// @Description Moderators only.
+// @Tags moderation
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.BlockedImageListResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /moderation/blocked-images [get]
+func (h *ModerationHandler) ListBlockedImages(c *gin.Context) {
+	hashes, err := h.service.ListBlockedImages(c.Request.Context())
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "blocked images retrieved", models.BlockedImageListResponse{Items: hashes})
+}
+
+// BlockImage adds the image of a listing photo or upload to the blocklist
+// POST /api/moderation/blocked-images
+// @Summary Block an image
+// @Description Send photo_id or media_id. New listings with a photo close to it are flagged, and so are active listings already using it. Moderators only.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.BlockImageRequest true "The image to block"
+// @Success 201 {object} utils.Response{data=models.BlockedImageHash}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /moderation/blocked-images [post]
+func (h *ModerationHandler) BlockImage(c *gin.Context) {
+	moderatorID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.BlockImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	blocked, err := h.service.BlockImage(c.Request.Context(), moderatorID, req)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "image blocked", blocked)
+}
+
+// UnblockImage removes an image from the blocklist
+// DELETE /api/moderation/blocked-images/{hash_id}
+// @Summary Unblock an image
+// @Description Flags the image already raised stay in the queue. Moderators only.
+// @Tags moderation
+// @Produce json
+// @Security BearerAuth
+// @Param hash_id path string true "Blocked image ID" format(uuid)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /moderation/blocked-images/{hash_id} [delete]
+func (h *ModerationHandler) UnblockImage(c *gin.Context) {
+	hashID, err := uuid.Parse(c.Param("hash_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid blocked image ID")
+		return
+	}
+
+	if err := h.service.UnblockImage(c.Request.Context(), hashID); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "image unblocked", nil)
+}
@@ -1,28 +1,141 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
 	"postswapapi/config"
 	"postswapapi/models"
+	"postswapapi/repository"
+	"postswapapi/services"
 	"postswapapi/utils"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-//used to create new notifications for an action
+//used to create new notifications for an action, the text is rendered from the type's template in the recipient's language.
+//Failures are logged, notifications that must not be lost go through the outbox with NewNotificationOutboxEntry instead
 
-func CreateNotification(userID uuid.UUID, notificationType, title, message string, relatedProductID, relatedUserID *uuid.UUID) {
+func CreateNotification(userID uuid.UUID, notificationType string, params models.NotificationParams, relatedProductID, relatedUserID *uuid.UUID) {
+	err := DeliverNotification(context.Background(), uuid.New(), models.NotificationJob{
+		UserID:           userID,
+		NotificationType: notificationType,
+		Params:           params,
+		RelatedProductID: relatedProductID,
+		RelatedUserID:    relatedUserID,
+	})
+
+	if err != nil {
+		slog.Error("failed to create notification", "user_id", userID, "type", notificationType, "error", err)
+	}
+}
+
+/* DeliverNotification renders and saves a notification under notificationID with the events announcing it.
+   The outbox delivers queued notifications with it, saving an id that already exists does nothing so retries are safe.
+*/
+
+func DeliverNotification(ctx context.Context, notificationID uuid.UUID, job models.NotificationJob) error {
+	userID, notificationType, params := job.UserID, job.NotificationType, job.Params
+
+	language := models.DefaultLanguage
+
+	err := config.DB.QueryRowContext(ctx, `SELECT preferred_language FROM users WHERE user_id = $1`, userID).Scan(&language)
+
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load notification language", "user_id", userID, "error", err)
+	}
 
-	notificationID := uuid.New()
+	title, message, err := services.RenderNotification(language, notificationType, params)
+
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notifications{
+		Notification_ID:    notificationID,
+		User_ID:            userID,
+		Notification_type:  notificationType,
+		Title:              title,
+		Message:            message,
+		Params:             params,
+		Related_product_ID: job.RelatedProductID,
+		Related_user_ID:    job.RelatedUserID,
+		Created_at:         time.Now(),
+	}
+
+	notification.Deep_link = models.NotificationDeepLink(notification)
+
+	//types the user turned off everywhere are dropped, without in-app the row is only kept for push and the digest
+
+	channels := notificationChannels(ctx, userID, notificationType)
+	if !channels.Any() {
+		return nil
+	}
+
+	//the notification and the events announcing it are saved together, the outbox delivers the events
+
+	tx, err := config.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+	   INSERT INTO notifications (notification_id, user_id, notification_type, title, message, params, related_product_id,
+	   related_user_id, is_read, is_pushed, is_hidden, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	   ON CONFLICT (notification_id) DO NOTHING
+	`, notification.Notification_ID, userID, notificationType, title, message, params, job.RelatedProductID, job.RelatedUserID, false,
+		!channels.Push, !channels.InApp, notification.Created_at)
+
+	if err != nil {
+		return err
+	}
+
+	//already saved by an earlier delivery
+
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 0 {
+		return err
+	}
+
+	if !channels.InApp {
+		return tx.Commit()
+	}
+
+	var unreadCount int
+
+	err = tx.QueryRowContext(ctx, `
+	   SELECT COUNT(*) FROM notifications
+	   WHERE user_id = $1 AND is_read = false AND is_hidden = false
+	`, userID).Scan(&unreadCount)
+
+	if err != nil {
+		return err
+	}
+
+	notificationEvent, err := models.NewUserEventOutboxEntry(userID, models.EventNotification, notification)
+	if err != nil {
+		return err
+	}
+
+	unreadEvent, err := models.NewUserEventOutboxEntry(userID, models.EventUnreadCount, gin.H{"unread_count": unreadCount})
+	if err != nil {
+		return err
+	}
+
+	if err := repository.EnqueueOutbox(ctx, tx, notificationEvent, unreadEvent); err != nil {
+		return err
+	}
 
-	config.DB.Exec(`
-	   INSERT INTO notifications (notification_id, user_id, notification_type, title, message, related_product_id,
-	   related_user_id, is_read, is_pushed, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, notificationID, userID, notificationType, title, message, relatedProductID, relatedUserID, false, false, time.Now())
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	outbox.Notify()
+	return nil
 }
 
 /* This is to trigger notification for mutual matches where user a has what user b wants and user b has what user a wants
@@ -33,7 +146,8 @@ func TriggerNotificationsForNewWant(productID, requestingUserID uuid.UUID, wante
 
 	//Get the requesting users product info
 
-	var myCategory, myProductTitle, myUsername, mySize string
+	var myCategory, myProductTitle, myUsername string
+	var mySize *string
 
 	err := config.DB.QueryRow(`
 	   SELECT p.category, p.estimated_size, p.title, u.username FROM products p
@@ -45,23 +159,21 @@ func TriggerNotificationsForNewWant(productID, requestingUserID uuid.UUID, wante
 		return //this wont be able to proceed without product info
 	}
 
-	//query for the basic description of this function
-
-	var query string
+	//query for the basic description of this function, a want without a size matches their product in any size
 
-	var args []any
+	if wantedSize != nil && *wantedSize == "" {
+		wantedSize = nil
+	}
 
-	if wantedSize != nil && *wantedSize != "" {
-		query = `
+	query := `
 	     SELECT p.product_id, p.seller_id, p.title, u.username, pw.wanted_category FROM products p
 		 JOIN users u ON p.seller_id = u.user_id
 		 JOIN product_wants pw ON p.product_id = pw.product_id
-		 WHERE p.category = $1 AND p.estimated_size = $2 AND pw.wanted_category = $3 AND 
-		 (pw.wanted_size = $4 OR pw.wanted_size IS NULL) AND p.status = 'acitve' AND p.seller_id != $5
-		 LIMIT 5	
+		 WHERE p.category = $1 AND ($2::text IS NULL OR p.estimated_size = $2) AND pw.wanted_category = $3 AND
+		 (pw.wanted_size = $4 OR pw.wanted_size IS NULL) AND p.status = 'active' AND p.seller_id != $5
+		 LIMIT 5
 		`
-		args = []any{wantedCategory, *wantedSize, myCategory, mySize, requestingUserID}
-	}
+	args := []any{wantedCategory, wantedSize, myCategory, mySize, requestingUserID}
 
 	rows, err := config.DB.Query(query, args...)
 
@@ -84,29 +196,48 @@ func TriggerNotificationsForNewWant(productID, requestingUserID uuid.UUID, wante
 			continue
 		}
 
-		//notify the other user about mutual interest
-
-		title := "Mutual Swap Interest"
-
-		message := fmt.Sprintf("Hey %s has %s and wants %s - you also have %s and want %s, you guys have a perfect match!",
-			myUsername, myProductTitle, wantedCategory, theirProductTitle, theirWantedCategory)
+		//notify both users about the mutual interest, each from their own side
 
-		CreateNotification(ownerID, "Mutual Match", title, message, &theirProductID, &requestingUserID)
+		CreateNotification(ownerID, models.NotificationMutualMatch, models.NotificationParams{
+			"other_user": myUsername, "other_product": myProductTitle, "other_wants": wantedCategory,
+			"your_product": theirProductTitle, "your_wants": theirWantedCategory,
+		}, &theirProductID, &requestingUserID)
 
-		//notify the current user about mutual interest
+		CreateNotification(requestingUserID, models.NotificationMutualInterest, models.NotificationParams{
+			"other_user": ownerUsername, "other_product": theirProductTitle, "other_wants": theirWantedCategory,
+			"your_product": myProductTitle, "your_wants": wantedCategory,
+		}, &productID, &ownerID)
 
-		myTitle := "Mutual Swap Interest"
+		//match events so both feeds can highlight the pair, unless the user turned in-app matches off
 
-		myMessage := fmt.Sprintf("Hey %s has %s and wants %s - you also have %s and want %s, you guys have a perfect match!",
-			ownerID, theirProductTitle, theirWantedCategory, myProductTitle, wantedCategory)
-
-		CreateNotification(requestingUserID, "Mutual interest", myTitle, myMessage, &productID, &ownerID)
+		if notificationChannels(context.Background(), ownerID, models.PreferenceMatches).InApp {
+			publishUserEvent(context.Background(), ownerID, models.EventMatch, models.MatchEvent{
+				ProductID: theirProductID, MatchedProductID: productID, MatchedUserID: requestingUserID,
+			})
+		}
+		if notificationChannels(context.Background(), requestingUserID, models.PreferenceMatches).InApp {
+			publishUserEvent(context.Background(), requestingUserID, models.EventMatch, models.MatchEvent{
+				ProductID: productID, MatchedProductID: theirProductID, MatchedUserID: ownerID,
+			})
+		}
 	}
 
 }
 
 //classic get all notificaitons present
 
+// @Summary List your notifications
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Param unread_only query bool false "Only unread notifications"
+// @Param type query string false "Only this type: a preference type (matches) or a notification_type"
+// @Success 200 {object} utils.Response{data=models.NotificationListResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications [get]
 func GetMyNotifications(ctx *gin.Context) {
 	presentUser, exists := ctx.Get("User")
 
@@ -127,6 +258,7 @@ func GetMyNotifications(ctx *gin.Context) {
 	limitStr := ctx.DefaultQuery("limit", "20")
 	offsetStr := ctx.DefaultQuery("offset", "0")
 	unreadOnly := ctx.DefaultQuery("unread_only", "false")
+	notificationType := ctx.Query("type")
 
 	limit, err := strconv.Atoi(limitStr)
 
@@ -146,12 +278,12 @@ func GetMyNotifications(ctx *gin.Context) {
 
 	var args []any
 
-	argIndex := 1
+	argIndex := 2
 
 	query = `
-	    SELECT notification_id, notification_type, title, message, related_conversation_id, related_product_id,
-		related_user_id, is_read, is_pushed, created_at, updated_at FROM notifications
-		WHERE user_id = $1
+	    SELECT notification_id, user_id, notification_type, title, message, params, related_conversation_id, related_product_id,
+		related_user_id, is_read, is_pushed, created_at, read_at FROM notifications
+		WHERE user_id = $1 AND is_hidden = false
 	`
 
 	args = append(args, user.User_ID)
@@ -159,7 +291,15 @@ func GetMyNotifications(ctx *gin.Context) {
 	//filter unread messages only if requested
 
 	if unreadOnly == "true" {
-		query += "AND is_read = false"
+		query += " AND is_read = false"
+	}
+
+	//filter by type, preference types cover several stored types
+
+	if notificationType != "" {
+		query += " AND notification_type = ANY($" + strconv.Itoa(argIndex) + ")"
+		args = append(args, pq.Array(models.NotificationTypesFor(notificationType)))
+		argIndex++
 	}
 
 	query += " ORDER BY created_at DESC"
@@ -171,7 +311,7 @@ func GetMyNotifications(ctx *gin.Context) {
 	query += " OFFSET $" + strconv.Itoa(argIndex)
 	args = append(args, offset)
 
-	rows, err := config.DB.Query(query, args...)
+	rows, err := config.DB.QueryContext(ctx.Request.Context(), query, args...)
 
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to fetch notifcations")
@@ -180,13 +320,13 @@ func GetMyNotifications(ctx *gin.Context) {
 
 	defer rows.Close()
 
-	var notficiations []models.Notifications
+	notficiations := []models.Notifications{}
 
 	for rows.Next() {
 		var notification models.Notifications
 
 		err := rows.Scan(&notification.Notification_ID, &notification.User_ID, &notification.Notification_type, &notification.Title,
-			&notification.Message, &notification.Related_conversation_ID, &notification.Related_product_ID, &notification.Related_user_ID,
+			&notification.Message, &notification.Params, &notification.Related_conversation_ID, &notification.Related_product_ID, &notification.Related_user_ID,
 			&notification.Is_Read, &notification.Is_Pushed, &notification.Created_at, &notification.Read_at)
 
 		if err != nil {
@@ -195,6 +335,8 @@ func GetMyNotifications(ctx *gin.Context) {
 		}
 
 		notification.User_ID = user.User_ID
+		notification.Deep_link = models.NotificationDeepLink(notification)
+		services.LocalizeNotification(&notification, user.Preferred_language)
 
 		notficiations = append(notficiations, notification)
 	}
@@ -216,7 +358,7 @@ func GetMyNotifications(ctx *gin.Context) {
 		nextOffset = &next
 	}
 
-	response := models.InfiniteScrollData{
+	response := models.NotificationListResponse{
 		Items: notficiations,
 		Meta: models.PaginationMeta{
 			Limit:       limit,
@@ -229,6 +371,17 @@ func GetMyNotifications(ctx *gin.Context) {
 	utils.SuccessResponse(ctx, http.StatusOK, "Notificaitons successfully fetched", response)
 }
 
+// @Summary Mark a notification as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param notification_id path string true "Notification ID" format(uuid)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications/{notification_id} [patch]
 func MarkNotifcationAsRead(ctx *gin.Context) {
 	notificationIDStr := ctx.Param("notification_id")
 	notificationID, err := uuid.Parse(notificationIDStr)
@@ -254,10 +407,10 @@ func MarkNotifcationAsRead(ctx *gin.Context) {
 
 	//verify notifcation belongs to user and update
 
-	result, err := config.DB.Exec(`
-       UPDATE notifcations
+	result, err := config.DB.ExecContext(ctx.Request.Context(), `
+       UPDATE notifications
 	   SET is_read = true, read_at = $1
-	   WHERE notifcation_id = $2 AND user_id = $3 AND is_read = false	
+	   WHERE notification_id = $2 AND user_id = $3 AND is_read = false	
 	`, time.Now(), notificationID, user.User_ID)
 
 	if err != nil {
@@ -277,9 +430,20 @@ func MarkNotifcationAsRead(ctx *gin.Context) {
 		return
 	}
 
+	publishUnreadCount(ctx.Request.Context(), user.User_ID)
+
 	utils.SuccessResponse(ctx, http.StatusOK, "Notifcation successfully marked as read", nil)
 }
 
+// @Summary Mark all your notifications as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "Only this type: a preference type (matches) or a notification_type"
+// @Success 200 {object} utils.Response{data=models.BulkNotificationResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications/read-all [post]
 func MarkAllNotificationsAsRead(ctx *gin.Context) {
 	presentUser, exists := ctx.Get("User")
 
@@ -295,22 +459,45 @@ func MarkAllNotificationsAsRead(ctx *gin.Context) {
 		return
 	}
 
-	//mark all notification as read
+	//mark all notification as read, optionally only one type
 
-	_, err := config.DB.Exec(`
+	var types []string
+
+	if notificationType := ctx.Query("type"); notificationType != "" {
+		types = models.NotificationTypesFor(notificationType)
+	}
+
+	result, err := config.DB.ExecContext(ctx.Request.Context(), `
        UPDATE notifications
 	   SET is_read = true, read_at = $1
-	   WHERE user_id = $2 AND is_read = false	
-	`, time.Now(), user.User_ID)
+	   WHERE user_id = $2 AND is_read = false AND ($3::text[] IS NULL OR notification_type = ANY($3))
+	`, time.Now(), user.User_ID, pq.Array(types))
 
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to mark notifications as read")
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusOK, "All notifications marked as read", nil)
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	publishUnreadCount(ctx.Request.Context(), user.User_ID)
+
+	utils.SuccessResponse(ctx, http.StatusOK, "All notifications marked as read", models.BulkNotificationResponse{Affected: affected})
 }
 
+// @Summary Count your unread notifications
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.UnreadCountResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications/unread-count [get]
 func GetUnreadNotificationCount(ctx *gin.Context) {
 	presentUser, exists := ctx.Get("User")
 
@@ -328,9 +515,9 @@ func GetUnreadNotificationCount(ctx *gin.Context) {
 
 	var count int
 
-	err := config.DB.QueryRow(`
+	err := config.DB.QueryRowContext(ctx.Request.Context(), `
 	   SELECT COUNT(*) FROM notifications
-	   WHERE user_id =  $1 AND is_read = false
+	   WHERE user_id =  $1 AND is_read = false AND is_hidden = false
 	`, user.User_ID).Scan(&count)
 
 	if err != nil {
@@ -338,7 +525,242 @@ func GetUnreadNotificationCount(ctx *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusOK, "Unread Count recieved", gin.H{
-		"unread_count": count,
+	utils.SuccessResponse(ctx, http.StatusOK, "Unread Count recieved", models.UnreadCountResponse{UnreadCount: count})
+}
+
+// readerLanguage is the signed in user's language notifications are rendered in
+func readerLanguage(ctx *gin.Context) string {
+	if user, ok := ctx.Value("User").(models.Users); ok && user.Preferred_language != "" {
+		return user.Preferred_language
+	}
+	return models.DefaultLanguage
+}
+
+// @Summary List your notifications grouped
+// @Description Notifications of one type about the same conversation, user or product collapse into one group, newest group first.
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Param unread_only query bool false "Only groups with unread notifications, counting only those"
+// @Param type query string false "Only this type: a preference type (matches) or a notification_type"
+// @Success 200 {object} utils.Response{data=models.NotificationGroupListResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications/groups [get]
+func GetNotificationGroups(ctx *gin.Context) {
+	userID, err := getUserIDFromContext(ctx)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	language := readerLanguage(ctx)
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	var types []string
+
+	if notificationType := ctx.Query("type"); notificationType != "" {
+		types = models.NotificationTypesFor(notificationType)
+	}
+
+	//a group is the type plus the entity the notification is about, the newest row of each group represents it
+
+	rows, err := config.DB.QueryContext(ctx.Request.Context(), `
+	   WITH grouped AS (
+	      SELECT n.*,
+	      ROW_NUMBER() OVER w AS position,
+	      COUNT(*) OVER w AS total,
+	      COUNT(*) FILTER (WHERE n.is_read = false) OVER w AS unread
+	      FROM notifications n
+	      WHERE n.user_id = $1 AND n.is_hidden = false
+	      AND ($2 = false OR n.is_read = false)
+	      AND ($3::text[] IS NULL OR n.notification_type = ANY($3))
+	      WINDOW w AS (
+	         PARTITION BY n.notification_type, COALESCE(n.related_conversation_id, n.related_user_id, n.related_product_id, n.notification_id)
+	         ORDER BY n.created_at DESC ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING
+	      )
+	   )
+	   SELECT g.notification_id, g.notification_type, g.title, g.message, g.params, g.related_conversation_id, g.related_product_id,
+	   g.related_user_id, g.is_read, g.is_pushed, g.created_at, g.read_at, g.total, g.unread, u.username
+	   FROM grouped g
+	   LEFT JOIN users u ON u.user_id = g.related_user_id
+	   WHERE g.position = 1
+	   ORDER BY g.created_at DESC
+	   LIMIT $4 OFFSET $5
+	`, userID, ctx.Query("unread_only") == "true", pq.Array(types), limit+1, offset)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	defer rows.Close()
+
+	groups := []models.NotificationGroup{}
+
+	for rows.Next() {
+		var group models.NotificationGroup
+
+		latest := &group.Latest
+
+		err := rows.Scan(&latest.Notification_ID, &latest.Notification_type, &latest.Title, &latest.Message, &latest.Params,
+			&latest.Related_conversation_ID, &latest.Related_product_ID, &latest.Related_user_ID, &latest.Is_Read,
+			&latest.Is_Pushed, &latest.Created_at, &latest.Read_at, &group.Count, &group.UnreadCount, &group.ActorName)
+
+		if err != nil {
+			utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to parse notification")
+			return
+		}
+
+		latest.User_ID = userID
+		latest.Deep_link = models.NotificationDeepLink(*latest)
+		services.LocalizeNotification(latest, language)
+
+		group.NotificationType = latest.Notification_type
+		group.Summary = services.NotificationGroupSummary(language, latest.Notification_type, group.Count, group.ActorName, latest.Title)
+
+		groups = append(groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	hasMore := len(groups) > limit
+
+	var nextOffset *int
+
+	if hasMore {
+		groups = groups[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Notifications successfully fetched", models.NotificationGroupListResponse{
+		Items: groups,
+		Meta: models.PaginationMeta{
+			Limit:       limit,
+			Offset:      offset,
+			Has_more:    hasMore,
+			Next_offset: nextOffset,
+		},
 	})
 }
+
+// @Summary Mark several notifications as read
+// @Description IDs that are not yours or already read are skipped, affected counts the ones changed.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.NotificationIDsRequest true "Notifications to mark read"
+// @Success 200 {object} utils.Response{data=models.BulkNotificationResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications/read [post]
+func MarkNotificationsAsRead(ctx *gin.Context) {
+	userID, err := getUserIDFromContext(ctx)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req models.NotificationIDsRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(ctx, err)
+		return
+	}
+
+	result, err := config.DB.ExecContext(ctx.Request.Context(), `
+       UPDATE notifications
+	   SET is_read = true, read_at = $1
+	   WHERE notification_id = ANY($2) AND user_id = $3 AND is_read = false
+	`, time.Now(), pq.Array(req.NotificationIDs), userID)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to mark notifications as read")
+		return
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if affected > 0 {
+		publishUnreadCount(ctx.Request.Context(), userID)
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Notifications marked as read", models.BulkNotificationResponse{Affected: affected})
+}
+
+// @Summary Delete several notifications
+// @Description IDs that are not yours are skipped, affected counts the ones deleted.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.NotificationIDsRequest true "Notifications to delete"
+// @Success 200 {object} utils.Response{data=models.BulkNotificationResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications [delete]
+func DeleteNotifications(ctx *gin.Context) {
+	userID, err := getUserIDFromContext(ctx)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var req models.NotificationIDsRequest
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(ctx, err)
+		return
+	}
+
+	result, err := config.DB.ExecContext(ctx.Request.Context(), `
+	   DELETE FROM notifications
+	   WHERE notification_id = ANY($1) AND user_id = $2
+	`, pq.Array(req.NotificationIDs), userID)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to delete notifications")
+		return
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if affected > 0 {
+		publishUnreadCount(ctx.Request.Context(), userID)
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Notifications deleted", models.BulkNotificationResponse{Affected: affected})
+}
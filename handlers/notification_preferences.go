@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"postswapapi/models"
+	"postswapapi/services"
+	"postswapapi/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var notificationPreferences *services.NotificationPreferenceService
+
+// SetNotificationPreferenceService wires notification preferences into the notification producers
+func SetNotificationPreferenceService(service *services.NotificationPreferenceService) {
+	notificationPreferences = service
+}
+
+// notificationChannels returns where a notification should be delivered, every channel when preferences aren't wired
+func notificationChannels(ctx context.Context, userID uuid.UUID, notificationType string) models.ChannelPreference {
+	if notificationPreferences == nil {
+		return models.DefaultChannelPreference(models.PreferenceTypeFor(notificationType))
+	}
+	return notificationPreferences.Channels(ctx, userID, notificationType)
+}
+
+// GetNotificationPreferences returns the signed in user's notification settings
+// GET /api/notifications/preferences
+// @Summary Get your notification preferences
+// @Description Channels per notification type, quiet hours in your timezone and the email digest frequency. Types you never changed have every channel on.
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.NotificationSettings}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications/preferences [get]
+func GetNotificationPreferences(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	settings, err := notificationPreferences.GetSettings(c.Request.Context(), userID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "notification preferences retrieved", settings)
+}
+
+// UpdateNotificationPreferences changes the signed in user's notification settings
+// PUT /api/notifications/preferences
+// @Summary Update your notification preferences
+// @Description Omitted fields are left as they are. Pushes that arrive during quiet hours are held until they end, chat message pushes are skipped.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.UpdateNotificationSettingsRequest true "Settings to change"
+// @Success 200 {object} utils.Response{data=models.NotificationSettings}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /notifications/preferences [put]
+func UpdateNotificationPreferences(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.UpdateNotificationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	settings, err := notificationPreferences.UpdateSettings(c.Request.Context(), userID, req)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "notification preferences updated", settings)
+}
@@ -4,7 +4,9 @@ import (
 	"database/sql"
 	"net/http"
 	"postswapapi/config"
+	"postswapapi/metrics"
 	"postswapapi/models"
+	"postswapapi/repository"
 	"postswapapi/utils"
 	"strconv"
 	"time"
@@ -14,12 +16,22 @@ import (
 )
 
 // @Summary Creates the product to swap for upload
-// @Description Handle uploading of a product into the app/feed
+// @Description Handle uploading of a product into the app/feed. image_urls are media_ids or urls of your own uploads, anything else is rejected.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.CreateProductRequest true "Product"
+// @Success 201 {object} utils.Response{data=models.CreateProductResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products [post]
 func CreateProduct(ctx *gin.Context) {
 	var req models.CreateProductRequest
 
 	if err := ctx.ShouldBind(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -52,7 +64,7 @@ func CreateProduct(ctx *gin.Context) {
 
 	//Process for product and photos
 
-	tx, err := config.DB.Begin()
+	tx, err := config.DB.BeginTx(ctx.Request.Context(), nil)
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to start transaction")
 		return
@@ -60,9 +72,17 @@ func CreateProduct(ctx *gin.Context) {
 
 	defer tx.Rollback()
 
+	//Photos have to be the seller's own uploads, they are only marked in use if the product is saved
+
+	media, err := attachMedia(ctx.Request.Context(), tx, user.User_ID, "image_urls", req.Image_Urls)
+	if err != nil {
+		utils.HandleError(ctx, err)
+		return
+	}
+
 	//Putting in the products to the db
 
-	_, err = tx.Exec(`
+	_, err = tx.ExecContext(ctx.Request.Context(), `
 	   INSERT INTO products (product_id, seller_id, title, category, estimated_size, status, created_at, updated_at)
 	   VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	`, product.Product_ID, product.Seller_ID, product.Title, product.Category, product.Estimated_size,
@@ -78,19 +98,22 @@ func CreateProduct(ctx *gin.Context) {
 
 	var photos []models.ProductPhotos
 
-	for i, imageUrl := range req.Image_Urls {
+	for i, upload := range media {
 		photo := models.ProductPhotos{
 			Photo_ID:      uuid.New(),
 			Product_ID:    product.Product_ID,
-			Image_Url:     imageUrl,
+			Image_Url:     upload.URL,
 			Display_order: i + 1,
 			Created_at:    time.Now(),
+			Thumbnail_Url: nonEmpty(upload.ThumbnailURL),
+			Feed_Url:      nonEmpty(upload.FeedURL),
 		}
 
-		_, err = tx.Exec(`
-	    INSERT INTO product_photos (photo_id, product_id, image_url, display_order, created_at)
-		VALUES($1, $2, $3, $4, $5)
-	 `, photo.Photo_ID, photo.Product_ID, photo.Image_Url, photo.Display_order, photo.Created_at)
+		_, err = tx.ExecContext(ctx.Request.Context(), `
+	    INSERT INTO product_photos (photo_id, product_id, image_url, display_order, created_at, thumbnail_url, feed_url, phash)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+	 `, photo.Photo_ID, photo.Product_ID, photo.Image_Url, photo.Display_order, photo.Created_at, photo.Thumbnail_Url, photo.Feed_Url,
+			upload.PerceptualHash)
 
 		if err != nil {
 			utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to save photos")
@@ -101,6 +124,18 @@ func CreateProduct(ctx *gin.Context) {
 
 	}
 
+	//photos reused from another seller's listing or on the blocklist go to the moderation queue, the listing stays up.
+	//Screening runs from the outbox once the listing is committed
+
+	screening, err := models.NewScreenProductOutboxEntry(product.Product_ID)
+	if err == nil {
+		err = repository.EnqueueOutbox(ctx.Request.Context(), tx, screening)
+	}
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to create product")
+		return
+	}
+
 	//commit changes
 
 	if err = tx.Commit(); err != nil {
@@ -108,6 +143,9 @@ func CreateProduct(ctx *gin.Context) {
 		return
 	}
 
+	metrics.ProductsCreated.Inc()
+	outbox.Notify()
+
 	//Preparing the response with photos
 
 	response := models.ProductWithSeller{
@@ -122,14 +160,27 @@ func CreateProduct(ctx *gin.Context) {
 		Updated_at:     time.Now(),
 	}
 
-	utils.SuccessResponse(ctx, http.StatusCreated, "Product Successfully Created", gin.H{
-		"product":    response,
-		"product_id": product.Product_ID,
+	utils.SuccessResponse(ctx, http.StatusCreated, "Product Successfully Created", models.CreateProductResponse{
+		Product:   response,
+		ProductID: product.Product_ID,
 	})
 }
 
 //function to get products uploaded by users in the main page
 
+// @Summary Get the main feed
+// @Description Active products from other sellers, signed in users never see their own products. mode=latest (the default) lists them all newest first, mode=for_you ranks recent products for the viewer, boosting ones matching their wants and wishlist, sellers who want what they list and sellers nearby, and says why in reasons. It is paginated with limit, send next_cursor back as cursor to get the next page of the same ranking.
+// @Tags products
+// @Produce json
+// @Param category query string false "Filter by category"
+// @Param mode query string false "Feed mode" Enums(latest, for_you) default(latest)
+// @Param limit query int false "Page size, for_you only" default(20)
+// @Param offset query int false "Offset, for_you only" default(0)
+// @Param cursor query string false "next_cursor of the previous page, for_you only"
+// @Success 200 {object} utils.Response{data=models.FeedResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products [get]
 func GetProducts(ctx *gin.Context) {
 	//parse pagination parameters
 
@@ -147,6 +198,18 @@ func GetProducts(ctx *gin.Context) {
 		}
 	}
 
+	switch ctx.DefaultQuery("mode", models.FeedModeLatest) {
+	case models.FeedModeLatest:
+	case models.FeedModeForYou:
+		if feedService != nil {
+			getForYouFeed(ctx, currentUserID, category)
+			return
+		}
+	default:
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "mode must be latest or for_you")
+		return
+	}
+
 	//If user doesn't have location then present an empty feed
 
 	// if currentUserLocation == "" {
@@ -162,7 +225,7 @@ func GetProducts(ctx *gin.Context) {
 	argIndex := 1
 
 	query = `
-	   SELECT p.product_id, p.title, p.estimated_size, p.created_at, pp.image_url
+	   SELECT p.product_id, p.title, p.estimated_size, p.created_at, COALESCE(pp.feed_url, pp.image_url)
 	   FROM products p LEFT JOIN product_photos pp ON p.product_id = pp.product_id AND pp.display_order = 1
 	   INNER JOIN users u ON p.seller_id  = u.user_id
 	   WHERE p.status = $1
@@ -188,10 +251,10 @@ func GetProducts(ctx *gin.Context) {
 
 	query += " ORDER BY p.created_at DESC"
 
-	rows, err := config.DB.Query(query, args...)
+	rows, err := config.DB.QueryContext(ctx.Request.Context(), query, args...)
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		utils.HandleError(ctx, utils.NewInternal("Failed to fetch products", err))
 		return
 	}
 
@@ -199,40 +262,24 @@ func GetProducts(ctx *gin.Context) {
 
 	//Simple structure for the feed
 
-	var products []gin.H
+	products := []models.FeedItem{}
 	for rows.Next() {
-		var productId uuid.UUID
-		var title string
-		var estimatedSize *string
-		var createdAt time.Time
-		var imageUrl sql.NullString
+		var product models.FeedItem
 
-		err := rows.Scan(&productId, &title, &estimatedSize, &createdAt, &imageUrl)
+		err := rows.Scan(&product.Product_ID, &product.Title, &product.Estimated_size, &product.Created_at, &product.Image_Url)
 
 		if err != nil {
 			utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to parse products")
 			return
 		}
 
-		product := gin.H{
-			"product_id":     productId,
-			"title":          title,
-			"estimated_size": estimatedSize,
-			"created_at":     createdAt,
-			"image_url":      imageUrl,
-		}
-
-		if imageUrl.Valid {
-			product["image_url"] = imageUrl.String
-		}
-
 		products = append(products, product)
 	}
 
 	//Checking if there are more items when scrolling
 
-	response := gin.H{
-		"items": products,
+	response := models.FeedResponse{
+		Items: products,
 	}
 
 	utils.SuccessResponse(ctx, http.StatusOK, "Products retrieved successfully", response)
@@ -241,6 +288,16 @@ func GetProducts(ctx *gin.Context) {
 
 //Get product via ID (Basically when you tap on the product)
 
+// @Summary Get a product with its seller and photos
+// @Description Signed in viewers also get conversation_id when they already have a thread with the seller about it, otherwise POST /products/{product_id}/conversation starts one. is_saved says whether the viewer saved it and the seller gets save_count.
+// @Tags products
+// @Produce json
+// @Param product_id path string true "Product ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.ProductWithSeller}
+// @Failure 400 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/{product_id} [get]
 func GetProductById(ctx *gin.Context) {
 	productIDStr := ctx.Param("product_id")
 	productID, err := uuid.Parse(productIDStr)
@@ -254,7 +311,7 @@ func GetProductById(ctx *gin.Context) {
 
 	var product models.ProductWithSeller
 
-	err = config.DB.QueryRow(`
+	err = config.DB.QueryRowContext(ctx.Request.Context(), `
 	    SELECT p.product_id, p.seller_id, p.title, p.category, p.estimated_size,
 		p.status, p.created_at, p.updated_at, u.first_name, u.last_name, u.avatar_url
 		FROM products p JOIN users u ON p.seller_id = user_id
@@ -268,14 +325,14 @@ func GetProductById(ctx *gin.Context) {
 	}
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		utils.HandleError(ctx, utils.NewInternal("Failed to fetch product", err))
 		return
 	}
 
 	//Get all photos in the product view
 
-	photoRows, err := config.DB.Query(`
-	 SELECT photo_id, image_url, display_order, created_at
+	photoRows, err := config.DB.QueryContext(ctx.Request.Context(), `
+	 SELECT photo_id, image_url, display_order, created_at, thumbnail_url, feed_url
 	 FROM product_photos
 	 WHERE product_id = $1 ORDER BY display_order
 	`, productID)
@@ -293,10 +350,10 @@ func GetProductById(ctx *gin.Context) {
 		var photo models.ProductPhotos
 		photo.Product_ID = productID
 
-		err := photoRows.Scan(&photo.Photo_ID, &photo.Image_Url, &photo.Display_order, &photo.Created_at)
+		err := photoRows.Scan(&photo.Photo_ID, &photo.Image_Url, &photo.Display_order, &photo.Created_at, &photo.Thumbnail_Url, &photo.Feed_Url)
 
 		if err != nil {
-			utils.ErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			utils.HandleError(ctx, utils.NewInternal("Failed to parse photos", err))
 			return
 		}
 
@@ -305,11 +362,62 @@ func GetProductById(ctx *gin.Context) {
 
 	product.Photos = photos
 
+	//message seller entry point, point the viewer at their existing thread about this product
+
+	if presentUser, exists := ctx.Get("User"); exists {
+		if user, ok := presentUser.(models.Users); ok && user.User_ID != product.Seller.User_ID {
+			var conversationID uuid.UUID
+
+			err = config.DB.QueryRowContext(ctx.Request.Context(), `
+			  SELECT c.id FROM conversations c
+			  JOIN conversation_participants cp ON cp.conversation_id = c.id
+			  WHERE c.related_product_id = $1 AND cp.user_id = $2
+			  LIMIT 1
+			`, productID, user.User_ID).Scan(&conversationID)
+
+			if err == nil {
+				product.Conversation_ID = &conversationID
+			}
+
+			err = config.DB.QueryRowContext(ctx.Request.Context(), `
+			  SELECT EXISTS (SELECT 1 FROM saved_products WHERE product_id = $1 AND user_id = $2)
+			`, productID, user.User_ID).Scan(&product.Is_saved)
+
+			if err != nil {
+				utils.HandleError(ctx, utils.NewInternal("Failed to fetch product", err))
+				return
+			}
+		}
+
+		//only the seller sees how many users saved the product
+
+		if user, ok := presentUser.(models.Users); ok && user.User_ID == product.Seller.User_ID {
+			var saveCount int
+
+			err = config.DB.QueryRowContext(ctx.Request.Context(), `SELECT COUNT(*) FROM saved_products WHERE product_id = $1`, productID).Scan(&saveCount)
+
+			if err != nil {
+				utils.HandleError(ctx, utils.NewInternal("Failed to fetch product", err))
+				return
+			}
+
+			product.Save_count = &saveCount
+		}
+	}
+
 	utils.SuccessResponse(ctx, http.StatusOK, "Product retrieved successfully", product)
 }
 
 //Viewing all the users app listed on the app(for the user themself)
 
+// @Summary List the signed in user's products
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.Products}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/me [get]
 func GetMyProducts(ctx *gin.Context) {
 	presentUser, exists := ctx.Get("User")
 
@@ -325,7 +433,7 @@ func GetMyProducts(ctx *gin.Context) {
 		return
 	}
 
-	rows, err := config.DB.Query(`
+	rows, err := config.DB.QueryContext(ctx.Request.Context(), `
 	  SELECT product_id, title, category, estimated_size, status, created_at, updated_at
 	  FROM products 
 	  WHERE seller_id = $1
@@ -364,6 +472,20 @@ func GetMyProducts(ctx *gin.Context) {
 
 //Function to update product status
 
+// @Summary Update the status of your product
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Param body body models.UpdateProductStatusRequest true "New status"
+// @Success 200 {object} utils.Response{data=models.ProductStatusResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/{product_id} [post]
 func UpdateProductStatus(ctx *gin.Context) {
 	productIDstr := ctx.Param("product_id")
 	productID, err := uuid.Parse(productIDstr)
@@ -373,12 +495,10 @@ func UpdateProductStatus(ctx *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Status string `json:"status" binding:"required,oneof=active swapped inactive" `
-	}
+	var req models.UpdateProductStatusRequest
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -396,7 +516,7 @@ func UpdateProductStatus(ctx *gin.Context) {
 
 	var ownerID uuid.UUID
 
-	err = config.DB.QueryRow("SELECT seller_id FROM products WHERE product_id = $1 ", productID).Scan(&ownerID)
+	err = config.DB.QueryRowContext(ctx.Request.Context(), "SELECT seller_id FROM products WHERE product_id = $1 ", productID).Scan(&ownerID)
 
 	if err == sql.ErrNoRows {
 		utils.ErrorResponse(ctx, http.StatusNotFound, "Product Not found")
@@ -413,12 +533,23 @@ func UpdateProductStatus(ctx *gin.Context) {
 		return
 	}
 
-	//updating the status of the product
+	//the status, the events and the system messages announcing it are saved together, the outbox delivers them
+
+	tx, err := config.DB.BeginTx(ctx.Request.Context(), nil)
 
-	_, err = config.DB.Exec(`
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+
+	defer tx.Rollback()
+
+	//updating the status of the product, sending the same status again changes nothing and tells no one
+
+	result, err := tx.ExecContext(ctx.Request.Context(), `
 	  UPDATE products
 	  SET status = $1, updated_at = $2
-	  WHERE product_id = $3
+	  WHERE product_id = $3 AND status <> $1
 	`, req.Status, time.Now(), productID)
 
 	if err != nil {
@@ -426,14 +557,85 @@ func UpdateProductStatus(ctx *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusOK, "Successfully Updated Product Status", gin.H{
-		"product_id": productID,
-		"status":     req.Status,
+	updated, err := result.RowsAffected()
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to update product status")
+		return
+	}
+
+	if updated == 0 {
+		utils.SuccessResponse(ctx, http.StatusOK, "Successfully Updated Product Status", models.ProductStatusResponse{
+			ProductID: productID,
+			Status:    req.Status,
+		})
+		return
+	}
+
+	//let everyone talking to the seller about this product know it changed
+
+	if eventService != nil {
+		err = eventService.EnqueueToProductConversations(ctx.Request.Context(), tx, productID, user.User_ID, models.EventProductStatus, models.ProductStatusResponse{
+			ProductID: productID,
+			Status:    req.Status,
+		})
+		if err != nil {
+			utils.HandleError(ctx, utils.NewInternal("Failed to update product status", err))
+			return
+		}
+	}
+
+	if messageService != nil {
+		err = messageService.NotifyProductStatusChanged(ctx.Request.Context(), tx, productID, user.User_ID, req.Status)
+		if err != nil {
+			utils.HandleError(ctx, utils.NewInternal("Failed to update product status", err))
+			return
+		}
+	}
+
+	//tell the users who saved it that it is gone
+
+	if req.Status == "swapped" || req.Status == "inactive" {
+		err = notifySavers(ctx.Request.Context(), tx, productID, user.User_ID, models.NotificationSavedProductStatus, models.NotificationParams{"status": req.Status})
+		if err != nil {
+			utils.HandleError(ctx, utils.NewInternal("Failed to update product status", err))
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to commit changes")
+		return
+	}
+
+	outbox.Notify()
+
+	//a product is counted once when it becomes swapped, the update above skips products already swapped
+
+	if req.Status == "swapped" {
+		metrics.SwapsCompleted.Inc()
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Successfully Updated Product Status", models.ProductStatusResponse{
+		ProductID: productID,
+		Status:    req.Status,
 	})
 }
 
 //Removing product (set the product status as inactive hence a soft delete)
 
+// @Summary Delete your product
+// @Tags products
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.ProductIDResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/{product_id} [delete]
 func DeleteProduct(ctx *gin.Context) {
 	productIDStr := ctx.Param("product_id")
 	productID, err := uuid.Parse(productIDStr)
@@ -459,8 +661,9 @@ func DeleteProduct(ctx *gin.Context) {
 
 	//verify if product belongs to user
 	var ownerID uuid.UUID
+	var status string
 
-	err = config.DB.QueryRow("SELECT seller_id FROM products WHERE product_id = $1", productID).Scan(&ownerID)
+	err = config.DB.QueryRowContext(ctx.Request.Context(), "SELECT seller_id, status FROM products WHERE product_id = $1", productID).Scan(&ownerID, &status)
 
 	if err == sql.ErrNoRows {
 		utils.ErrorResponse(ctx, http.StatusNotFound, "Product Not Found")
@@ -479,7 +682,7 @@ func DeleteProduct(ctx *gin.Context) {
 
 	//Start transaction for hard delete
 
-	tx, err := config.DB.Begin()
+	tx, err := config.DB.BeginTx(ctx.Request.Context(), nil)
 
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to start transaction")
@@ -488,9 +691,23 @@ func DeleteProduct(ctx *gin.Context) {
 
 	defer tx.Rollback()
 
+	//the saves are deleted with the product, so read who to tell first
+
+	savers, saverParams, err := savedProductAudience(ctx.Request.Context(), tx, productID)
+
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(ctx, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	if err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to delete product", err))
+		return
+	}
+
 	//Delete product photos first
 
-	_, err = tx.Exec(`DELETE FROM product_photos WHERE product_id = $1`, productID)
+	_, err = tx.ExecContext(ctx.Request.Context(), `DELETE FROM product_photos WHERE product_id = $1`, productID)
 
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to delete photos")
@@ -499,7 +716,7 @@ func DeleteProduct(ctx *gin.Context) {
 
 	//Delete product wants
 
-	_, err = tx.Exec(`DELETE FROM product_wants WHERE product_id = $1`, productID)
+	_, err = tx.ExecContext(ctx.Request.Context(), `DELETE FROM product_wants WHERE product_id = $1`, productID)
 
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to delete product wants")
@@ -508,7 +725,7 @@ func DeleteProduct(ctx *gin.Context) {
 
 	//Delete the product entirely
 
-	result, err := tx.Exec(`DELETE FROM products WHERE product_id = $1`, productID)
+	result, err := tx.ExecContext(ctx.Request.Context(), `DELETE FROM products WHERE product_id = $1`, productID)
 
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to delete product")
@@ -529,6 +746,17 @@ func DeleteProduct(ctx *gin.Context) {
 		return
 	}
 
+	//savers of a swapped or taken down product already heard it is gone
+
+	if status == "active" && len(savers) > 0 {
+		saverParams["status"] = "inactive"
+		err = enqueueSaverNotifications(ctx.Request.Context(), tx, savers, user.User_ID, nil, models.NotificationSavedProductStatus, saverParams)
+		if err != nil {
+			utils.HandleError(ctx, utils.NewInternal("Failed to delete product", err))
+			return
+		}
+	}
+
 	//commit transaction
 
 	if err = tx.Commit(); err != nil {
@@ -536,8 +764,10 @@ func DeleteProduct(ctx *gin.Context) {
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusOK, "Product Successfully deleted", gin.H{
-		"product_id": productID,
+	outbox.Notify()
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Product Successfully deleted", models.ProductIDResponse{
+		ProductID: productID,
 	})
 
 }
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 	"postswapapi/config"
 	"postswapapi/models"
+	"postswapapi/repository"
 	"postswapapi/utils"
 	"time"
 
@@ -14,11 +16,25 @@ import (
 
 //function to request what the user wants in exchange for what theyre swapping with
 
+// @Summary Set what you want in exchange for your product
+// @Tags product wants
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Param body body models.CreateProductWantRequest true "Want"
+// @Success 200 {object} utils.Response{data=models.WantIDResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /product_wants/{product_id}/want [post]
 func CreateProductWant(ctx *gin.Context) {
 	var req models.CreateProductWantRequest
 
 	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -49,7 +65,7 @@ func CreateProductWant(ctx *gin.Context) {
 
 	var productOwner uuid.UUID
 
-	err := config.DB.QueryRow(`
+	err := config.DB.QueryRowContext(ctx.Request.Context(), `
          SELECT seller_id FROM products WHERE product_id = $1
     `, productID).Scan(&productOwner)
 
@@ -70,16 +86,30 @@ func CreateProductWant(ctx *gin.Context) {
 
 	//check if wants already exist for this product
 
-	var existWantID string
+	var existWantID, existCategory string
+	var existSize *string
 
-	err = config.DB.QueryRow(`
-       SELECT want_id FROM product_wants WHERE product_id = $1 
-    `, productID).Scan(&existWantID)
+	err = config.DB.QueryRowContext(ctx.Request.Context(), `
+       SELECT want_id, wanted_category, wanted_size FROM product_wants WHERE product_id = $1 
+    `, productID).Scan(&existWantID, &existCategory, &existSize)
+
+	wantChanged := err == sql.ErrNoRows || wantDiffers(existCategory, existSize, req.WantedCategory, req.WantedSize)
 
 	var want models.ProductWants
 
 	now := time.Now()
 
+	//the want and the notifications telling savers about it are saved together
+
+	tx, txErr := config.DB.BeginTx(ctx.Request.Context(), nil)
+
+	if txErr != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+
+	defer tx.Rollback()
+
 	//creating what you want in return request
 	switch err {
 	case sql.ErrNoRows:
@@ -93,7 +123,7 @@ func CreateProductWant(ctx *gin.Context) {
 			UpdatedAt:      now,
 		}
 
-		_, err = config.DB.Exec(`
+		_, err = tx.ExecContext(ctx.Request.Context(), `
             INSERT INTO product_wants (want_id, product_id, want_user_id ,wanted_category, wanted_size, created_at, updated_at)
             VALUES($1, $2, $3, $4, $5, $6, $7) 
         `, want.WantID, want.ProductID, want.WantUserID, want.WantedCategory, want.WantedSize, want.CreatedAt,
@@ -106,25 +136,49 @@ func CreateProductWant(ctx *gin.Context) {
 		want.WantedSize = req.WantedSize
 		want.UpdatedAt = now
 
-		_, err = config.DB.Exec(`
+		_, err = tx.ExecContext(ctx.Request.Context(), `
             UPDATE product_wants SET wanted_category = $2, wanted_size = $3, updated_at = $4
             WHERE want_id = $1
         `, want.WantID, want.WantedCategory, want.WantedSize, want.UpdatedAt)
 	}
 
 	if err != nil {
-		utils.ErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+		utils.HandleError(ctx, utils.NewInternal("Failed to save product want", err))
+		return
+	}
+
+	if wantChanged {
+		if err = notifySaversOfWant(ctx.Request.Context(), tx, want.ProductID, user.User_ID, req.WantedCategory, req.WantedSize); err != nil {
+			utils.HandleError(ctx, utils.NewInternal("Failed to save product want", err))
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to save product want", err))
 		return
 	}
 
-	utils.SuccessResponse(ctx, http.StatusOK, "Product Want Saved Successfully", gin.H{
-		"want_id": want.WantID,
+	outbox.Notify()
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Product Want Saved Successfully", models.WantIDResponse{
+		WantID: want.WantID,
 	})
 
 }
 
 //get product wants for editing purposes
 
+// @Summary Get the want of your product
+// @Tags product wants
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.ProductWants}
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /product_wants/{product_id}/want [get]
 func GetProductWant(ctx *gin.Context) {
 	productID := ctx.Param("product_id")
 
@@ -144,7 +198,7 @@ func GetProductWant(ctx *gin.Context) {
 
 	var want models.ProductWants
 
-	err := config.DB.QueryRow(`
+	err := config.DB.QueryRowContext(ctx.Request.Context(), `
         SELECT pw.want_id, pw.product_id, pw.wanted_category, pw.wanted_size, pw.created_at, pw.updated_at
         FROM product_wants pw JOIN products p ON pw.product_id = p.product_id
         WHERE pw.product_id = $1 AND p.seller_id = $2
@@ -167,11 +221,24 @@ func GetProductWant(ctx *gin.Context) {
 
 //update product want
 
+// @Summary Update the want of your product
+// @Tags product wants
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Param body body models.UpdateProductWantRequest true "Want"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /product_wants/{product_id}/want [put]
 func UpdateProductWant(ctx *gin.Context) {
 	var req models.UpdateProductWantRequest
 
 	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
-		utils.ErrorResponse(ctx, http.StatusBadRequest, err.Error())
+		utils.BindingErrorResponse(ctx, err)
 		return
 	}
 
@@ -194,8 +261,11 @@ func UpdateProductWant(ctx *gin.Context) {
 	//verify ownership and update
 
 	var ownerID uuid.UUID
+	var existCategory string
+	var existSize *string
 
-	err := config.DB.QueryRow(`SELECT want_user_id FROM product_wants WHERE product_id = $1`, productID).Scan(&ownerID)
+	err := config.DB.QueryRowContext(ctx.Request.Context(), `SELECT want_user_id, wanted_category, wanted_size FROM product_wants WHERE product_id = $1`,
+		productID).Scan(&ownerID, &existCategory, &existSize)
 
 	if err != nil {
 		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Database Error")
@@ -207,7 +277,18 @@ func UpdateProductWant(ctx *gin.Context) {
 		return
 	}
 
-	_, err = config.DB.Exec(`
+	//the want and the notifications telling savers about it are saved together
+
+	tx, err := config.DB.BeginTx(ctx.Request.Context(), nil)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx.Request.Context(), `
        UPDATE product_wants
 	   SET wanted_category = $1, wanted_size = $2, updated_at = $3
 	   WHERE product_id = $4
@@ -218,9 +299,48 @@ func UpdateProductWant(ctx *gin.Context) {
 		return
 	}
 
+	if wantDiffers(existCategory, existSize, req.WantedCategory, req.WantedSize) {
+		err = notifySaversOfWant(ctx.Request.Context(), tx, uuid.MustParse(productID), user.User_ID, req.WantedCategory, req.WantedSize)
+		if err != nil {
+			utils.ErrorResponse(ctx, http.StatusInternalServerError, "failed to update product want")
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		utils.ErrorResponse(ctx, http.StatusInternalServerError, "failed to update product want")
+		return
+	}
+
+	outbox.Notify()
+
 	//trigger notficiation for new want
 
 	go TriggerNotificationsForNewWant(uuid.MustParse(productID), user.User_ID, req.WantedCategory, req.WantedSize)
 
 	utils.SuccessResponse(ctx, http.StatusOK, "Product want updated successfully", nil)
 }
+
+//wantDiffers reports whether a product want changed, an empty size is the same as none
+
+func wantDiffers(category string, size *string, newCategory string, newSize *string) bool {
+	sizeOf := func(size *string) string {
+		if size == nil {
+			return ""
+		}
+		return *size
+	}
+
+	return category != newCategory || sizeOf(size) != sizeOf(newSize)
+}
+
+//savers of the product hear what its seller wants for it now, queued as part of tx
+
+func notifySaversOfWant(ctx context.Context, tx repository.DBTX, productID, sellerID uuid.UUID, category string, size *string) error {
+	params := models.NotificationParams{"category": category, "size": ""}
+	if size != nil {
+		params["size"] = *size
+	}
+
+	return notifySavers(ctx, tx, productID, sellerID, models.NotificationSavedProductWants, params)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"postswapapi/config"
+	"postswapapi/services"
+	"postswapapi/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RealtimeHandler struct {
+	publisher services.RealtimePublisher
+	presence  *services.PresenceService
+}
+
+func NewRealtimeHandler(publisher services.RealtimePublisher, presence *services.PresenceService) *RealtimeHandler {
+	return &RealtimeHandler{
+		publisher: publisher,
+		presence:  presence,
+	}
+}
+
+// Connect opens a WebSocket to the built-in realtime hub
+// GET /api/realtime/ws?token=<jwt>
+// Browsers cannot set headers on a WebSocket handshake so the JWT may also come as the token query param.
+// @Summary Connect to the realtime WebSocket hub
+// @Description Only available when REALTIME_BACKEND is websocket. After connecting send {"action":"subscribe","channel":"conversation:<id>"}.
+// @Tags realtime
+// @Param token query string false "JWT, when the Authorization header cannot be set"
+// @Success 101
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /realtime/ws [get]
+func (h *RealtimeHandler) Connect(c *gin.Context) {
+	hub, ok := h.publisher.(*services.WebSocketHub)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusNotFound, "websocket transport is not enabled")
+		return
+	}
+
+	token := c.Query("token")
+	if parts := strings.Split(c.GetHeader("Authorization"), " "); len(parts) == 2 && strings.ToLower(parts[0]) == "bearer" {
+		token = parts[1]
+	}
+
+	claims, err := services.ValidateToken(token)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid Token")
+		return
+	}
+
+	var userID uuid.UUID
+	err = config.DB.QueryRowContext(c.Request.Context(), `SELECT user_id FROM users WHERE user_id = $1`, claims.UserID).Scan(&userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	reqCtx := utils.WithUserID(c.Request.Context(), userID.String())
+	heartbeat := func(ctx context.Context) { h.presence.Touch(ctx, userID) }
+	if err := hub.Serve(c.Writer, c.Request.WithContext(reqCtx), userID, heartbeat); err != nil {
+		slog.WarnContext(reqCtx, "websocket connection closed with error", "error", err)
+	}
+}
+
+// Heartbeat keeps the user online for clients whose realtime connection does not go through this API (Ably)
+// POST /api/realtime/heartbeat
+// @Summary Report that the user is still active
+// @Description WebSocket and /events connections heartbeat on their own. Other clients call this at least every 60 seconds to stay online.
+// @Tags realtime
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /realtime/heartbeat [post]
+func (h *RealtimeHandler) Heartbeat(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	h.presence.Touch(c.Request.Context(), userID)
+
+	utils.SuccessResponse(c, http.StatusOK, "heartbeat recorded", nil)
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"postswapapi/config"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"postswapapi/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+//bookmark a product to find it again in GET /me/saved
+
+// @Summary Save a product
+// @Description Adds the product to your saved list, saving it again does nothing. You hear when it is swapped, taken down or its seller changes what they want for it.
+// @Tags saved products
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.SaveProductResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/{product_id}/save [post]
+func SaveProduct(ctx *gin.Context) {
+	productID, err := uuid.Parse(ctx.Param("product_id"))
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid Product ID")
+		return
+	}
+
+	userID, err := getUserIDFromContext(ctx)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var sellerID uuid.UUID
+	var status string
+
+	err = config.DB.QueryRowContext(ctx.Request.Context(), `SELECT seller_id, status FROM products WHERE product_id = $1`, productID).Scan(&sellerID, &status)
+
+	if err == sql.ErrNoRows {
+		utils.ErrorResponse(ctx, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	if err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to fetch product", err))
+		return
+	}
+
+	if sellerID == userID {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "You can't save your own product")
+		return
+	}
+
+	if status != "active" {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Only active products can be saved")
+		return
+	}
+
+	_, err = config.DB.ExecContext(ctx.Request.Context(), `
+	   INSERT INTO saved_products (user_id, product_id) VALUES ($1, $2)
+	   ON CONFLICT (user_id, product_id) DO NOTHING
+	`, userID, productID)
+
+	if err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to save product", err))
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Product saved", models.SaveProductResponse{ProductID: productID, Saved: true})
+}
+
+// @Summary Remove a product from your saved list
+// @Description Removing a product that isn't saved does nothing.
+// @Tags saved products
+// @Produce json
+// @Security BearerAuth
+// @Param product_id path string true "Product ID" format(uuid)
+// @Success 200 {object} utils.Response{data=models.SaveProductResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /products/{product_id}/save [delete]
+func UnsaveProduct(ctx *gin.Context) {
+	productID, err := uuid.Parse(ctx.Param("product_id"))
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusBadRequest, "Invalid Product ID")
+		return
+	}
+
+	userID, err := getUserIDFromContext(ctx)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	_, err = config.DB.ExecContext(ctx.Request.Context(), `DELETE FROM saved_products WHERE user_id = $1 AND product_id = $2`, userID, productID)
+
+	if err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to remove saved product", err))
+		return
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Product removed from saved", models.SaveProductResponse{ProductID: productID, Saved: false})
+}
+
+// @Summary List your saved products
+// @Description Most recently saved first. Products stay listed after they are swapped or taken down, check status.
+// @Tags saved products
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Page size" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} utils.Response{data=models.SavedProductListResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /me/saved [get]
+func GetSavedProducts(ctx *gin.Context) {
+	userID, err := getUserIDFromContext(ctx)
+
+	if err != nil {
+		utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "20"))
+
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	rows, err := config.DB.QueryContext(ctx.Request.Context(), `
+	   SELECT p.product_id, p.title, p.category, p.estimated_size, p.status, COALESCE(pp.feed_url, pp.image_url), p.created_at, sp.created_at
+	   FROM saved_products sp
+	   JOIN products p ON p.product_id = sp.product_id
+	   LEFT JOIN product_photos pp ON pp.product_id = p.product_id AND pp.display_order = 1
+	   WHERE sp.user_id = $1
+	   ORDER BY sp.created_at DESC, p.product_id
+	   LIMIT $2 OFFSET $3
+	`, userID, limit+1, offset)
+
+	if err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to fetch saved products", err))
+		return
+	}
+
+	defer rows.Close()
+
+	products := []models.SavedProduct{}
+
+	for rows.Next() {
+		var product models.SavedProduct
+
+		err := rows.Scan(&product.Product_ID, &product.Title, &product.Category, &product.Estimated_size, &product.Status,
+			&product.Image_Url, &product.Created_at, &product.Saved_at)
+
+		if err != nil {
+			utils.HandleError(ctx, utils.NewInternal("Failed to parse saved products", err))
+			return
+		}
+
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		utils.HandleError(ctx, utils.NewInternal("Failed to fetch saved products", err))
+		return
+	}
+
+	hasMore := len(products) > limit
+
+	var nextOffset *int
+	if hasMore {
+		products = products[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	utils.SuccessResponse(ctx, http.StatusOK, "Saved products retrieved", models.SavedProductListResponse{
+		Items: products,
+		Meta: models.PaginationMeta{
+			Limit:       limit,
+			Offset:      offset,
+			Has_more:    hasMore,
+			Next_offset: nextOffset,
+		},
+	})
+}
+
+//who saved a product and what notifications about it are rendered with, read before a product is deleted since the saves go with it
+
+func savedProductAudience(ctx context.Context, db repository.DBTX, productID uuid.UUID) ([]uuid.UUID, models.NotificationParams, error) {
+	var title, username string
+
+	err := db.QueryRowContext(ctx, `
+	   SELECT p.title, u.username FROM products p
+	   JOIN users u ON u.user_id = p.seller_id
+	   WHERE p.product_id = $1
+	`, productID).Scan(&title, &username)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT user_id FROM saved_products WHERE product_id = $1`, productID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	var savers []uuid.UUID
+
+	for rows.Next() {
+		var saverID uuid.UUID
+
+		if err := rows.Scan(&saverID); err != nil {
+			return nil, nil, err
+		}
+
+		savers = append(savers, saverID)
+	}
+
+	return savers, models.NotificationParams{"product": title, "seller": username}, rows.Err()
+}
+
+/* notifySavers queues a notification for everyone who saved a product about a change to it, params get the product
+   title and the seller's username as product and seller. Pass the transaction making the change, the outbox
+   delivers the notifications once it commits.
+*/
+
+func notifySavers(ctx context.Context, tx repository.DBTX, productID, sellerID uuid.UUID, notificationType string, params models.NotificationParams) error {
+	savers, audienceParams, err := savedProductAudience(ctx, tx, productID)
+
+	if err != nil {
+		return err
+	}
+
+	for key, value := range params {
+		audienceParams[key] = value
+	}
+
+	return enqueueSaverNotifications(ctx, tx, savers, sellerID, &productID, notificationType, audienceParams)
+}
+
+//relatedProductID is nil once the product is deleted, the notification then links to the seller
+
+func enqueueSaverNotifications(ctx context.Context, tx repository.DBTX, savers []uuid.UUID, sellerID uuid.UUID, relatedProductID *uuid.UUID, notificationType string, params models.NotificationParams) error {
+	entries := make([]models.OutboxEntry, 0, len(savers))
+
+	for _, saverID := range savers {
+		entry, err := models.NewNotificationOutboxEntry(models.NotificationJob{
+			UserID:           saverID,
+			NotificationType: notificationType,
+			Params:           params,
+			RelatedProductID: relatedProductID,
+			RelatedUserID:    &sellerID,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return repository.EnqueueOutbox(ctx, tx, entries...)
+}
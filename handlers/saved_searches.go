@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"net/http"
+	"postswapapi/models"
+	"postswapapi/services"
+	"postswapapi/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type SavedSearchHandler struct {
+	service *services.SavedSearchService
+}
+
+func NewSavedSearchHandler(service *services.SavedSearchService) *SavedSearchHandler {
+	return &SavedSearchHandler{service: service}
+}
+
+// CreateSavedSearch saves a feed search to be alerted about
+// POST /api/saved-searches
+// @Summary Save a search
+// @Description Alerts batch every new matching listing since the last alert: instant within minutes, daily or weekly. Every word of query has to be in the title or category. Users only have a location name, so radius_km keeps sellers in your location like nearby in the for_you feed, whatever the number. At most 20 saved searches.
+// @Tags saved searches
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body models.CreateSavedSearchRequest true "Filters and alert frequency"
+// @Success 201 {object} utils.Response{data=models.SavedSearch}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /saved-searches [post]
+func (h *SavedSearchHandler) CreateSavedSearch(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req models.CreateSavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	search, err := h.service.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "search saved", search)
+}
+
+// ListSavedSearches returns the user's saved searches
+// GET /api/saved-searches
+// @Summary List your saved searches
+// @Tags saved searches
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=models.SavedSearchListResponse}
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /saved-searches [get]
+func (h *SavedSearchHandler) ListSavedSearches(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	searches, err := h.service.List(c.Request.Context(), userID)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "saved searches retrieved", models.SavedSearchListResponse{Items: searches})
+}
+
+// UpdateSavedSearch changes the filters or alert frequency of a saved search
+// PATCH /api/saved-searches/{search_id}
+// @Summary Update a saved search
+// @Description Omitted fields are left as they are, an empty category or size and a radius_km of 0 remove that filter. frequency off stops alerts, turning them back on only alerts about listings from then on.
+// @Tags saved searches
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param search_id path string true "Saved search ID" format(uuid)
+// @Param body body models.UpdateSavedSearchRequest true "Changes"
+// @Success 200 {object} utils.Response{data=models.SavedSearch}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /saved-searches/{search_id} [patch]
+func (h *SavedSearchHandler) UpdateSavedSearch(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	searchID, err := uuid.Parse(c.Param("search_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid saved search ID")
+		return
+	}
+
+	var req models.UpdateSavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BindingErrorResponse(c, err)
+		return
+	}
+
+	search, err := h.service.Update(c.Request.Context(), userID, searchID, req)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "saved search updated", search)
+}
+
+// DeleteSavedSearch deletes a saved search and stops its alerts
+// DELETE /api/saved-searches/{search_id}
+// @Summary Delete a saved search
+// @Tags saved searches
+// @Produce json
+// @Security BearerAuth
+// @Param search_id path string true "Saved search ID" format(uuid)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /saved-searches/{search_id} [delete]
+func (h *SavedSearchHandler) DeleteSavedSearch(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	searchID, err := uuid.Parse(c.Param("search_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid saved search ID")
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), userID, searchID); err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "saved search deleted", nil)
+}
+
+// GetSavedSearchResults lists the active listings matching a saved search, where its alerts link to
+// GET /api/saved-searches/{search_id}/results
+// @Summary List the listings matching a saved search
+// @Description Active listings from other sellers, newest first.
+// @Tags saved searches
+// @Produce json
+// @Security BearerAuth
+// @Param search_id path string true "Saved search ID" format(uuid)
+// @Param limit query int false "Page size" default(20)
+// @Param offset query int false "Offset" default(0)
+// @Success 200 {object} utils.Response{data=models.FeedResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /saved-searches/{search_id}/results [get]
+func (h *SavedSearchHandler) GetSavedSearchResults(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	searchID, err := uuid.Parse(c.Param("search_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "invalid saved search ID")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	items, err := h.service.Results(c.Request.Context(), userID, searchID, limit+1, offset)
+	if err != nil {
+		utils.HandleError(c, err)
+		return
+	}
+
+	hasMore := len(items) > limit
+
+	var nextOffset *int
+	if hasMore {
+		items = items[:limit]
+		next := offset + limit
+		nextOffset = &next
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "saved search results retrieved", models.FeedResponse{
+		Items: items,
+		Meta: &models.PaginationMeta{
+			Limit:       limit,
+			Offset:      offset,
+			Has_more:    hasMore,
+			Next_offset: nextOffset,
+		},
+	})
+}
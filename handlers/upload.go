@@ -1,50 +1,52 @@
 package handlers
 
 import (
-	"context"
-	"fmt"
 	"net/http"
-	"os"
+	"postswapapi/models"
+	"postswapapi/services"
 	"postswapapi/utils"
 
-	"github.com/cloudinary/cloudinary-go/v2"
-	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"github.com/gin-gonic/gin"
 )
 
 type UploadHandler struct {
-	cloudinary *cloudinary.Cloudinary
+	media *services.MediaService
 }
 
-func NewUploadHandler() (*UploadHandler, error) {
-	cloudName := os.Getenv("CLOUDINARY_CLOUD_NAME")
-	apiKey := os.Getenv("CLOUDINARY_API_KEY")
-	apiSecret := os.Getenv("CLOUDINARY_API_SECRET")
-
-	if cloudName == "" || apiKey == "" || apiSecret == "" {
-		return nil, fmt.Errorf("cloudinary credentials not configured")
-	}
-
-	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize cloudinary: %w", err)
-	}
-
-	return &UploadHandler{
-		cloudinary: cld,
-	}, nil
+func NewUploadHandler(media *services.MediaService) *UploadHandler {
+	return &UploadHandler{media: media}
 }
 
-// UploadImage uploads an image to Cloudinary and returns the URL
+// UploadImage uploads an image through the API and returns the URL.
+// Prefer signed uploads (POST /media/uploads), they don't pass the file through the API.
 // POST /api/upload/image
+// @Summary Upload an image
+// @Description Kept for older clients, new clients should use POST /media/uploads and upload straight to storage.
+// @Accept multipart/form-data
+// @Tags uploads
+// @Produce json
+// @Security BearerAuth
+// @Param image formData file true "JPEG, PNG, WebP or HEIC image, max 5MB. Metadata is stripped and thumbnail and feed size copies are made"
+// @Param purpose formData string false "What the image is for" Enums(avatar, product, chat) default(chat)
+// @Success 200 {object} utils.Response{data=models.UploadImageResponse}
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /upload/image [post]
 func (h *UploadHandler) UploadImage(c *gin.Context) {
 	// Get user ID from context (authentication check)
-	_, err := getUserIDFromContext(c)
+	userID, err := getUserIDFromContext(c)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
+	purpose := c.DefaultPostForm("purpose", models.MediaPurposeChat)
+	if models.MediaFolder(purpose) == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "purpose must be avatar, product or chat")
+		return
+	}
+
 	// Get the uploaded file
 	file, err := c.FormFile("image")
 	if err != nil {
@@ -52,8 +54,8 @@ func (h *UploadHandler) UploadImage(c *gin.Context) {
 		return
 	}
 
-	// Validate file size (max 5MB)
-	if file.Size > 5*1024*1024 {
+	// Validate file size (max 5MB), the type is checked from the content when it is processed
+	if file.Size > models.MaxMediaBytes {
 		utils.ErrorResponse(c, http.StatusBadRequest, "image too large, max 5MB")
 		return
 	}
@@ -66,25 +68,18 @@ func (h *UploadHandler) UploadImage(c *gin.Context) {
 	}
 	defer fileContent.Close()
 
-	// Upload to Cloudinary
-	uploadResult, err := h.cloudinary.Upload.Upload(
-		context.Background(),
-		fileContent,
-		uploader.UploadParams{
-			Folder:         "pointswap/chat", // Organize in folder
-			ResourceType:   "image",
-			Transformation: "q_auto,f_auto", // Auto quality and format
-		},
-	)
-
+	media, err := h.media.Upload(c.Request.Context(), userID, purpose, fileContent)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "failed to upload image")
+		utils.HandleError(c, err)
 		return
 	}
 
 	// Return the secure URL
-	utils.SuccessResponse(c, http.StatusOK, "image uploaded successfully", gin.H{
-		"image_url": uploadResult.SecureURL,
-		"public_id": uploadResult.PublicID,
+	utils.SuccessResponse(c, http.StatusOK, "image uploaded successfully", models.UploadImageResponse{
+		ImageUrl:     media.URL,
+		PublicID:     media.StorageKey,
+		MediaID:      media.MediaID,
+		ThumbnailUrl: media.ThumbnailURL,
+		FeedUrl:      media.FeedURL,
 	})
 }
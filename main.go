@@ -1,37 +1,147 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"os"
 	"postswapapi/config"
 	"postswapapi/handlers"
+	"postswapapi/metrics"
 	"postswapapi/repository"
 	"postswapapi/routes"
 	"postswapapi/services"
+	"postswapapi/utils"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 
 	_ "github.com/lib/pq"
 )
 
+// how long the /events log keeps entries for Last-Event-ID resume
+const eventRetention = 7 * 24 * time.Hour
+
+// read notifications older than this are archived, NOTIFICATION_RETENTION_DAYS overrides it
+const defaultNotificationRetentionDays = 90
+
+// @title PointSwap API
+// @version 1.0
+// @description Swap marketplace API: accounts, product feed, wants, notifications and chat.
+// @BasePath /pointSwapApi/v1
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and the JWT.
 func main() {
 	err := godotenv.Load()
 
+	slog.SetDefault(utils.NewLogger(os.Stdout, os.Getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT")))
+
 	if err != nil {
-		log.Print("env file not found")
+		slog.Info("env file not found")
 	}
 
+	shutdownTracing, err := config.SetupTracing(context.Background())
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
 	config.ConnectToDb()
+	metrics.RegisterDBStats(config.DB, os.Getenv("DB_NAME"))
 
 	// Initialize message components
 	messageRepo := repository.NewMessageRepository(config.DB)
-	messageService, err := services.NewMessageService(messageRepo)
+	publisher, err := services.NewRealtimePublisher(services.ConversationAuthorizer(messageRepo))
 	if err != nil {
-		log.Fatal("Failed to initialize message service:", err)
+		slog.Error("failed to initialize realtime publisher", "error", err)
+		os.Exit(1)
 	}
+
+	messageService := services.NewMessageService(messageRepo, publisher)
 	defer messageService.Close()
 
 	messageHandler := handlers.NewMessageHandler(messageService)
+	handlers.SetMessageService(messageService)
+
+	// Presence from realtime heartbeats
+	presenceService := services.NewPresenceService(repository.NewPresenceRepository(config.DB), messageRepo, publisher)
+	realtimeHandler := handlers.NewRealtimeHandler(publisher, presenceService)
+
+	// Per-user event log behind GET /events
+	eventService := services.NewEventService(repository.NewEventRepository(config.DB))
+	handlers.SetEventService(eventService)
+	eventHandler := handlers.NewEventHandler(eventService, presenceService)
+
+	// Realtime and user events written with the change they announce, delivered with retries
+	outbox := services.NewOutboxDispatcher(repository.NewOutboxRepository(config.DB), publisher, eventService)
+	messageService.SetOutbox(outbox)
+	handlers.SetOutbox(outbox)
+
+	// Pushes for notifications and for messages to offline participants
+	pushSender, err := services.NewPushSender()
+	if err != nil {
+		slog.Error("failed to initialize push sender", "error", err)
+		os.Exit(1)
+	}
+	pushService := services.NewPushService(repository.NewPushRepository(config.DB), pushSender)
+	outbox.SetPush(pushService)
+
+	// Per-type channel preferences, quiet hours and the email digest
+	preferenceRepo := repository.NewNotificationPreferenceRepository(config.DB)
+	handlers.SetNotificationPreferenceService(services.NewNotificationPreferenceService(preferenceRepo))
+	emailSender, err := services.NewEmailSender()
+	if err != nil {
+		slog.Error("failed to initialize email sender", "error", err)
+		os.Exit(1)
+	}
+	digestService := services.NewDigestService(preferenceRepo, emailSender)
+
+	retentionDays, err := strconv.Atoi(os.Getenv("NOTIFICATION_RETENTION_DAYS"))
+	if err != nil || retentionDays <= 0 {
+		retentionDays = defaultNotificationRetentionDays
+	}
+	notificationRetention := services.NewNotificationRetention(repository.NewNotificationRepository(config.DB), time.Duration(retentionDays)*24*time.Hour)
+
+	// Uploads go straight to the media store, the API signs and confirms them
+	mediaStore, err := services.NewMediaStore()
+	if err != nil {
+		slog.Error("failed to initialize media store", "error", err)
+		os.Exit(1)
+	}
+	mediaService := services.NewMediaService(repository.NewMediaRepository(config.DB), mediaStore)
+	// Product photos, avatars and attachments must be the user's own uploads, the rest are cleaned up after a day
+	handlers.SetMediaService(mediaService)
+	messageService.SetMedia(mediaService)
+	mediaCleanup := services.NewMediaCleanup(mediaService)
+
+	// New listings with reused or blocked photos go to the moderation queue, screened from the outbox
+	moderationService := services.NewModerationService(repository.NewModerationRepository(config.DB))
+	outbox.SetModeration(moderationService)
+	outbox.SetNotifier(handlers.DeliverNotification)
+
+	// The For You feed, weights are tuned with the FEED_* environment variables
+	handlers.SetFeedService(services.NewFeedService(repository.NewFeedRepository(config.DB), services.FeedWeightsFromEnv()))
+
+	// Saved searches, the job batches new matching listings into one alert per search
+	savedSearchService := services.NewSavedSearchService(repository.NewSavedSearchRepository(config.DB))
+	savedSearchService.SetNotifier(handlers.CreateNotification)
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go eventService.RunRetention(backgroundCtx, eventRetention)
+	go presenceService.Run(backgroundCtx)
+	go outbox.Run(backgroundCtx)
+	go pushService.Run(backgroundCtx)
+	go digestService.Run(backgroundCtx)
+	go notificationRetention.Run(backgroundCtx)
+	go mediaCleanup.Run(backgroundCtx)
+	go savedSearchService.Run(backgroundCtx)
+
+	// Prometheus scrapes an internal listener, METRICS_ADDR sets it and defaults to loopback
+	go metrics.Serve(backgroundCtx, os.Getenv("METRICS_ADDR"))
 
 	port := os.Getenv("PORT")
 
@@ -39,9 +149,11 @@ func main() {
 		port = "8080"
 	}
 
-	log.Println("Server running on port ", port)
+	slog.Info("server running", "port", port)
 
-	r := routes.SetupRouter(messageHandler)
+	r := routes.SetupRouter(messageHandler, realtimeHandler, eventHandler, handlers.NewDeviceHandler(pushService),
+		handlers.NewMediaHandler(mediaService), handlers.NewUploadHandler(mediaService), handlers.NewModerationHandler(moderationService),
+		handlers.NewSavedSearchHandler(savedSearchService))
 	r.Run(":" + port)
 
 }
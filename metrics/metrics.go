@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "pointswap"
+
+// HTTP metrics, labelled by the route pattern rather than the raw path to keep cardinality low
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// External service metrics
+var (
+	RealtimePublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "realtime_publish_failures_total",
+		Help:      "Realtime publishes (Ably or the WebSocket hub) that failed, by event name.",
+	}, []string{"event"})
+
+	OutboxRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_retries_total",
+		Help:      "Outbox deliveries that failed and were scheduled again, by event name.",
+	}, []string{"event"})
+
+	OutboxDeadLettered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_dead_lettered_total",
+		Help:      "Outbox entries that ran out of delivery attempts, by event name.",
+	}, []string{"event"})
+
+	CloudinaryUploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cloudinary_upload_duration_seconds",
+		Help:      "Time taken to upload an image to Cloudinary, by outcome.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	}, []string{"outcome"})
+)
+
+// Business metrics for the dashboards
+var (
+	ProductsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "products_created_total",
+		Help:      "Products listed on the feed.",
+	})
+
+	SwapsCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "swaps_completed_total",
+		Help:      "Products their seller moved to swapped, marking a swapped product again is not counted.",
+	})
+
+	MessagesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Chat messages saved.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		RealtimePublishFailures,
+		OutboxRetries,
+		OutboxDeadLettered,
+		CloudinaryUploadDuration,
+		ProductsCreated,
+		SwapsCompleted,
+		MessagesSent,
+	)
+}
+
+// RegisterDBStats exposes the sql.DBStats connection pool gauges for db
+func RegisterDBStats(db *sql.DB, dbName string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultAddr is where /metrics is served when METRICS_ADDR is not set, loopback only so it is never public
+const DefaultAddr = "127.0.0.1:9090"
+
+// Serve exposes /metrics on its own listener at addr, away from the public API, until ctx is cancelled
+func Serve(ctx context.Context, addr string) {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("metrics server running", "addr", addr)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("metrics server stopped", "error", err)
+	}
+}
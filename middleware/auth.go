@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
 	"postswapapi/config"
 	"postswapapi/models"
@@ -25,7 +24,6 @@ func AuthMiddleWare() gin.HandlerFunc {
 		}
 
 		bearerToken := strings.Split(authHeader, " ")
-		fmt.Println(bearerToken)
 
 		if len(bearerToken) != 2 || strings.ToLower(bearerToken[0]) != "bearer" {
 			utils.ErrorResponse(ctx, http.StatusUnauthorized, "Invalid Authorization Header Format")
@@ -41,10 +39,10 @@ func AuthMiddleWare() gin.HandlerFunc {
 		}
 
 		var user models.Users
-		err = config.DB.QueryRow(`
-		SELECT user_id, email, created_at, updated_at FROM users
+		err = config.DB.QueryRowContext(ctx.Request.Context(), `
+		SELECT user_id, email, preferred_language, is_moderator, created_at, updated_at FROM users
 	    WHERE user_id = $1
-		`, claims.UserID).Scan(&user.User_ID, &user.Email, &user.Created_at, &user.Updated_at)
+		`, claims.UserID).Scan(&user.User_ID, &user.Email, &user.Preferred_language, &user.Is_moderator, &user.Created_at, &user.Updated_at)
 
 		if err != nil {
 			utils.ErrorResponse(ctx, http.StatusUnauthorized, "User not found")
@@ -53,6 +51,7 @@ func AuthMiddleWare() gin.HandlerFunc {
 		}
 
 		ctx.Set("User", user)
+		ctx.Request = ctx.Request.WithContext(utils.WithUserID(ctx.Request.Context(), user.User_ID.String()))
 		ctx.Next()
 	}
 }
@@ -79,18 +78,35 @@ func OptionalAuthMiddleWare() gin.HandlerFunc {
 
 		var user models.Users
 
-		err = config.DB.QueryRow(`
-		   SELECT user_id, email, created_at, updated_at FROM users
+		err = config.DB.QueryRowContext(ctx.Request.Context(), `
+		   SELECT user_id, email, preferred_language, is_moderator, created_at, updated_at FROM users
 	       WHERE user_id = $1
 		`, claims.UserID).Scan(
-			&user.User_ID, &user.Email, &user.Created_at, &user.Updated_at,
+			&user.User_ID, &user.Email, &user.Preferred_language, &user.Is_moderator, &user.Created_at, &user.Updated_at,
 		)
 
 		if err == nil {
 			ctx.Set("User", user)
+			ctx.Request = ctx.Request.WithContext(utils.WithUserID(ctx.Request.Context(), user.User_ID.String()))
 		}
 
 		ctx.Next()
 
 	}
 }
+
+// RequireModerator only lets moderators through, it goes after AuthMiddleWare
+func RequireModerator() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		presentUser, _ := ctx.Get("User")
+
+		user, ok := presentUser.(models.Users)
+		if !ok || !user.Is_moderator {
+			utils.ErrorResponse(ctx, http.StatusForbidden, "Moderators only")
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"postswapapi/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records request counts and latency per route
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			// unmatched paths all share one label so scanners can't blow up cardinality
+			route = "unmatched"
+		}
+
+		status := strconv.Itoa(ctx.Writer.Status())
+
+		metrics.HTTPRequestsTotal.WithLabelValues(ctx.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(ctx.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,12 @@
+-- Per-user event log backing the GET /events SSE stream.
+-- event_id doubles as the SSE id so clients resume with Last-Event-ID.
+CREATE TABLE IF NOT EXISTS user_events (
+    event_id   BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    payload    JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_events_user_event ON user_events (user_id, event_id);
+CREATE INDEX IF NOT EXISTS idx_user_events_created_at ON user_events (created_at);
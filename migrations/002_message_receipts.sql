@@ -0,0 +1,14 @@
+-- Per-user delivered/read receipts for chat messages.
+-- messages.is_read is still set when the recipient reads, for older clients.
+CREATE TABLE IF NOT EXISTS message_receipts (
+    message_id   UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id      UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    delivered_at TIMESTAMPTZ,
+    read_at      TIMESTAMPTZ,
+    PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_receipts_user ON message_receipts (user_id);
+
+-- presence sweeps look for stale online users
+CREATE INDEX IF NOT EXISTS idx_users_online_last_seen ON users (last_seen) WHERE is_online;
@@ -0,0 +1,11 @@
+-- Message editing: edited_at on the message plus the text it replaced on every edit.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+    id            UUID PRIMARY KEY,
+    message_id    UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    previous_text TEXT NOT NULL,
+    edited_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_edits_message ON message_edits (message_id, edited_at);
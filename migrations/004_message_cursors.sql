@@ -0,0 +1,4 @@
+-- Keyset pagination on (created_at, id) and the change scans behind GET /conversations/sync.
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_cursor ON messages (conversation_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_edited_at ON messages (edited_at) WHERE edited_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at) WHERE deleted_at IS NOT NULL;
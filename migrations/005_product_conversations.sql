@@ -0,0 +1,5 @@
+-- Conversations can be about a specific product, and messages can be system notices.
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS related_product_id UUID REFERENCES products(product_id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_conversations_related_product ON conversations (related_product_id) WHERE related_product_id IS NOT NULL;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS message_type TEXT NOT NULL DEFAULT 'text';
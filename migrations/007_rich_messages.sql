@@ -0,0 +1,23 @@
+-- Typed messages carry a JSON payload (product card, swap offer, meeting location) and any number of attachments.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS payload JSONB;
+
+CREATE TABLE IF NOT EXISTS message_attachments (
+    id UUID PRIMARY KEY,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    content_type TEXT NOT NULL DEFAULT 'image',
+    display_order INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_attachments_message ON message_attachments (message_id, display_order);
+
+-- Older image messages kept their single picture in messages.image_url with placeholder text
+INSERT INTO message_attachments (id, message_id, url, content_type, display_order, created_at)
+SELECT gen_random_uuid(), m.id, m.image_url, 'image', 0, m.created_at
+FROM messages m
+WHERE m.image_url IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM message_attachments a WHERE a.message_id = m.id);
+
+UPDATE messages SET message_type = 'image' WHERE image_url IS NOT NULL AND message_type = 'text';
+UPDATE messages SET message_text = '' WHERE message_type = 'image' AND message_text = '📷 Image';
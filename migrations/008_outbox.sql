@@ -0,0 +1,24 @@
+-- Realtime publishes and user events are written here in the same transaction as the change they announce,
+-- then delivered by the outbox dispatcher with retries. Entries that keep failing are kept with status 'dead'.
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    idempotency_key UUID NOT NULL UNIQUE,
+    destination TEXT NOT NULL,
+    channel TEXT,
+    user_id UUID,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox (next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_outbox_dispatched ON outbox (dispatched_at) WHERE status = 'dispatched';
+
+-- Redelivered user events are dropped instead of appearing twice in the log
+ALTER TABLE user_events ADD COLUMN IF NOT EXISTS idempotency_key UUID;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_events_idempotency ON user_events (idempotency_key) WHERE idempotency_key IS NOT NULL;
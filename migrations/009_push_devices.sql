@@ -0,0 +1,21 @@
+-- Device tokens for push notifications, a user can be signed in on several devices.
+-- A token belongs to one user at a time, signing in as someone else on the device moves it.
+CREATE TABLE IF NOT EXISTS user_devices (
+    device_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    token TEXT NOT NULL UNIQUE,
+    platform TEXT NOT NULL DEFAULT 'android',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_devices_user ON user_devices (user_id, last_seen_at DESC);
+
+-- users.fcm_token only ever held one device
+INSERT INTO user_devices (device_id, user_id, token)
+SELECT gen_random_uuid(), user_id, fcm_token
+FROM users
+WHERE fcm_token IS NOT NULL AND fcm_token != ''
+ON CONFLICT (token) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unpushed ON notifications (created_at) WHERE is_pushed = false;
@@ -0,0 +1,26 @@
+-- Per-type channel preferences, a missing row means every channel is on
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    notification_type TEXT NOT NULL,
+    in_app BOOLEAN NOT NULL DEFAULT true,
+    push BOOLEAN NOT NULL DEFAULT true,
+    email BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, notification_type)
+);
+
+-- Quiet hours are local to timezone and may wrap past midnight (22:00 to 07:00)
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    quiet_hours_start TIME,
+    quiet_hours_end TIME,
+    digest_frequency TEXT NOT NULL DEFAULT 'weekly' CHECK (digest_frequency IN ('off', 'daily', 'weekly')),
+    last_digest_at TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Notifications kept only for push or the email digest are hidden from the in-app list and unread count
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS is_hidden BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_digest ON notifications (user_id, created_at) WHERE is_read = false;
@@ -0,0 +1,20 @@
+-- Read notifications past the retention period are moved here, out of the list and unread queries
+CREATE TABLE IF NOT EXISTS notifications_archive (
+    notification_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    notification_type TEXT NOT NULL,
+    title TEXT NOT NULL,
+    message TEXT NOT NULL,
+    related_conversation_id UUID,
+    related_product_id UUID,
+    related_user_id UUID,
+    created_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP,
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_archive_user ON notifications_archive (user_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_read ON notifications (created_at) WHERE is_read = true;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_type ON notifications (user_id, notification_type, created_at DESC);
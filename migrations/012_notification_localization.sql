@@ -0,0 +1,6 @@
+-- Language notifications, pushes and digests are rendered in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferred_language TEXT NOT NULL DEFAULT 'en';
+
+-- Template params, title and message keep the text rendered at creation for older clients
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS params JSONB;
+ALTER TABLE notifications_archive ADD COLUMN IF NOT EXISTS params JSONB;
@@ -0,0 +1,16 @@
+-- Uploaded files, recorded once the uploader confirms them. storage_key names the object in the backend.
+CREATE TABLE IF NOT EXISTS media (
+    media_id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    purpose TEXT NOT NULL CHECK (purpose IN ('avatar', 'product', 'chat')),
+    storage_backend TEXT NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    bytes BIGINT NOT NULL,
+    width INT NOT NULL DEFAULT 0,
+    height INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_owner ON media (owner_id, created_at DESC);
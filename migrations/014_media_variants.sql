@@ -0,0 +1,9 @@
+-- Resized copies generated when an image is uploaded, stored next to the original
+ALTER TABLE media ADD COLUMN IF NOT EXISTS thumbnail_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE media ADD COLUMN IF NOT EXISTS feed_url TEXT NOT NULL DEFAULT '';
+
+-- Copied from media when a product is created, null for photos that weren't uploaded through the API
+ALTER TABLE product_photos ADD COLUMN IF NOT EXISTS thumbnail_url TEXT;
+ALTER TABLE product_photos ADD COLUMN IF NOT EXISTS feed_url TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_media_url ON media (url);
@@ -0,0 +1,7 @@
+-- Set the first time an upload is used by a product, avatar or message. Uploads never attached are garbage collected.
+ALTER TABLE media ADD COLUMN IF NOT EXISTS attached_at TIMESTAMP;
+
+-- Uploads from before attachments were tracked may already be in use, keep them
+UPDATE media SET attached_at = created_at WHERE attached_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_media_unattached ON media (created_at) WHERE attached_at IS NULL;
@@ -0,0 +1,32 @@
+-- Perceptual hashes of uploaded images, copied to product photos so new listings can be compared against existing ones
+ALTER TABLE media ADD COLUMN IF NOT EXISTS phash BIGINT;
+ALTER TABLE product_photos ADD COLUMN IF NOT EXISTS phash BIGINT;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Images moderators have banned, listings with a photo close to one of them are flagged
+CREATE TABLE IF NOT EXISTS blocked_image_hashes (
+    hash_id UUID PRIMARY KEY,
+    phash BIGINT NOT NULL UNIQUE,
+    reason TEXT NOT NULL DEFAULT '',
+    created_by UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- The moderation queue. A flag is about one photo of a listing and what it matched.
+CREATE TABLE IF NOT EXISTS moderation_flags (
+    flag_id UUID PRIMARY KEY,
+    product_id UUID NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    photo_id UUID NOT NULL REFERENCES product_photos(photo_id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('duplicate_photo', 'blocked_photo')),
+    matched_photo_id UUID REFERENCES product_photos(photo_id) ON DELETE SET NULL,
+    matched_hash_id UUID REFERENCES blocked_image_hashes(hash_id) ON DELETE SET NULL,
+    distance INT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'dismissed', 'actioned')),
+    resolved_by UUID REFERENCES users(user_id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (photo_id, reason)
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_flags_status ON moderation_flags (status, created_at);
@@ -0,0 +1,14 @@
+-- The wishlist the For You feed reads, created here for databases set up after it was shelved
+CREATE TABLE IF NOT EXISTS user_preferences (
+    preference_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    category TEXT NOT NULL,
+    size TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_preferences_user ON user_preferences (user_id) WHERE is_active;
+CREATE INDEX IF NOT EXISTS idx_product_wants_user ON product_wants (want_user_id);
+CREATE INDEX IF NOT EXISTS idx_products_active_created ON products (created_at DESC) WHERE status = 'active';
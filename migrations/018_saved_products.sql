@@ -0,0 +1,10 @@
+-- Products users bookmarked, savers hear when a saved product is swapped, taken down or its wants change
+CREATE TABLE IF NOT EXISTS saved_products (
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(product_id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, product_id)
+);
+
+-- counting and notifying the savers of a product
+CREATE INDEX IF NOT EXISTS idx_saved_products_product ON saved_products (product_id);
@@ -0,0 +1,18 @@
+-- Feed filters users asked to hear about. The job looks for listings created after last_checked_at once
+-- next_check_at passes, so daily and weekly searches batch everything new since their last alert.
+CREATE TABLE IF NOT EXISTS saved_searches (
+    search_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    query TEXT NOT NULL DEFAULT '',
+    category TEXT,
+    size TEXT,
+    radius_km INT CHECK (radius_km > 0),
+    frequency TEXT NOT NULL DEFAULT 'daily' CHECK (frequency IN ('instant', 'daily', 'weekly', 'off')),
+    last_checked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    next_check_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_searches_user ON saved_searches (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_saved_searches_due ON saved_searches (next_check_at) WHERE frequency <> 'off';
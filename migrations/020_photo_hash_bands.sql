@@ -0,0 +1,19 @@
+-- Perceptual hashes are split into 7 bands, two hashes differing in at most 6 bits agree on at least one band.
+-- Photo screening looks candidates up by exact band instead of comparing a new photo with every hash.
+-- The expressions have to match hashBand in repository/moderation_repository.go.
+
+CREATE INDEX IF NOT EXISTS idx_product_photos_band0 ON product_photos (((phash >> 0) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_product_photos_band1 ON product_photos (((phash >> 9) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_product_photos_band2 ON product_photos (((phash >> 18) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_product_photos_band3 ON product_photos (((phash >> 27) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_product_photos_band4 ON product_photos (((phash >> 36) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_product_photos_band5 ON product_photos (((phash >> 45) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_product_photos_band6 ON product_photos (((phash >> 54) & 1023)) WHERE phash IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_blocked_hashes_band0 ON blocked_image_hashes (((phash >> 0) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocked_hashes_band1 ON blocked_image_hashes (((phash >> 9) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocked_hashes_band2 ON blocked_image_hashes (((phash >> 18) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocked_hashes_band3 ON blocked_image_hashes (((phash >> 27) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocked_hashes_band4 ON blocked_image_hashes (((phash >> 36) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocked_hashes_band5 ON blocked_image_hashes (((phash >> 45) & 511)) WHERE phash IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_blocked_hashes_band6 ON blocked_image_hashes (((phash >> 54) & 1023)) WHERE phash IS NOT NULL;
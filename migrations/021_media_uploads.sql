@@ -0,0 +1,9 @@
+-- Keys handed out for signed uploads that haven't been confirmed yet. Confirming an upload removes its key,
+-- keys still here a day later are deleted from storage with whatever was uploaded under them.
+CREATE TABLE IF NOT EXISTS media_uploads (
+    storage_key TEXT PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_uploads_created ON media_uploads (created_at);
@@ -1,16 +1,42 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Conversation struct {
-	ID            uuid.UUID `json:"id" db:"id"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
-	LastMessageAt time.Time `json:"last_message_at" db:"last_message_at"`
+	ID               uuid.UUID  `json:"id" db:"id"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+	LastMessageAt    time.Time  `json:"last_message_at" db:"last_message_at"`
+	RelatedProductID *uuid.UUID `json:"related_product_id,omitempty" db:"related_product_id"`
+	IsGroup          bool       `json:"is_group" db:"is_group"`
+	Title            *string    `json:"title,omitempty" db:"title"`
+	CreatedBy        *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+}
+
+// Participant roles, only group conversations have owners and admins
+const (
+	ParticipantRoleOwner  = "owner"
+	ParticipantRoleAdmin  = "admin"
+	ParticipantRoleMember = "member"
+)
+
+// Message types, system messages are written by the API (e.g. product status changes) and can't be edited
+const (
+	MessageTypeText   = "text"
+	MessageTypeSystem = "system"
+)
+
+// ConversationProduct is the product card shown on a product-linked conversation
+type ConversationProduct struct {
+	ProductID uuid.UUID `json:"product_id" db:"product_id"`
+	Title     string    `json:"title" db:"title"`
+	Status    string    `json:"status" db:"status"`
+	ImageUrl  *string   `json:"image_url,omitempty" db:"image_url"`
 }
 
 // links users to conversations
@@ -18,58 +44,138 @@ type ConversationParticipant struct {
 	ID             uuid.UUID `json:"id" db:"id"`
 	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
 	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Role           string    `json:"role" db:"role"`
 	JoinedAt       time.Time `json:"joined_at" db:"joined_at"`
 	LastReadAt     time.Time `json:"last_read_at" db:"last_read_at"`
 }
 
+// ParticipantInfo is one member of a conversation as shown in the conversation list
+type ParticipantInfo struct {
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	Name        string    `json:"name" db:"name"`
+	Avatar      *string   `json:"avatar,omitempty" db:"avatar_url"`
+	Role        string    `json:"role" db:"role"`
+	LastReadAt  time.Time `json:"last_read_at" db:"last_read_at"`
+	UnreadCount int       `json:"unread_count" db:"unread_count"`
+}
+
 // Basically message in a convo
 type Message struct {
-	ID             uuid.UUID `json:"id" db:"id"`
-	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
-	SenderID       uuid.UUID `json:"sender_id" db:"sender_id"`
-	MessageText    string    `json:"message_text" db:"message_text"`
-	ImageUrl       *string   `json:"image_url,omitempty" db:"image_url"`
-	IsRead         bool      `json:"is_read" db:"is_read"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	DeletedAt      time.Time `json:"deleted_at" db:"deleted_at"`
+	ID             uuid.UUID           `json:"id" db:"id"`
+	ConversationID uuid.UUID           `json:"conversation_id" db:"conversation_id"`
+	SenderID       uuid.UUID           `json:"sender_id" db:"sender_id"`
+	MessageText    string              `json:"message_text" db:"message_text"`
+	ImageUrl       *string             `json:"image_url,omitempty" db:"image_url"`
+	MessageType    string              `json:"message_type" db:"message_type"`
+	Payload        json.RawMessage     `json:"payload,omitempty" db:"payload" swaggertype:"object"`
+	Attachments    []MessageAttachment `json:"attachments"`
+	IsRead         bool                `json:"is_read" db:"is_read"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+	EditedAt       *time.Time          `json:"edited_at,omitempty" db:"edited_at"`
+	DeletedAt      time.Time           `json:"deleted_at" db:"deleted_at"`
 }
 
-// The particpants info with their last message display
+// The particpants info with their last message display.
+// other_user_* is only filled for one-to-one conversations, groups use title and participants.
 type ConversationWithDetails struct {
-	ID              uuid.UUID  `json:"id" db:"id"`
-	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
-	LastMessageAt   *time.Time `json:"last_message_at,omitempty" db:"last_message_at"`
-	OtherUserID     uuid.UUID  `json:"other_user_id" db:"other_user_id"`
-	OtherUserName   string     `json:"other_user_name" db:"other_user_name"`
-	OtherUserAvatar *string    `json:"other_user_avatar,omitempty" db:"other_user_avatar"`
-	LastMessageText *string    `json:"last_message_text,omitempty" db:"last_message_text"`
-	UnreadCount     int        `json:"unread_count" db:"unread_count"`
+	ID              uuid.UUID            `json:"id" db:"id"`
+	CreatedAt       time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at" db:"updated_at"`
+	LastMessageAt   *time.Time           `json:"last_message_at,omitempty" db:"last_message_at"`
+	IsGroup         bool                 `json:"is_group" db:"is_group"`
+	Title           *string              `json:"title,omitempty" db:"title"`
+	OtherUserID     uuid.UUID            `json:"other_user_id" db:"other_user_id"`
+	OtherUserName   string               `json:"other_user_name" db:"other_user_name"`
+	OtherUserAvatar *string              `json:"other_user_avatar,omitempty" db:"other_user_avatar"`
+	LastMessageText *string              `json:"last_message_text,omitempty" db:"last_message_text"`
+	UnreadCount     int                  `json:"unread_count" db:"unread_count"`
+	Product         *ConversationProduct `json:"product,omitempty"`
+	Participants    []ParticipantInfo    `json:"participants"`
 }
 
 // Includes senders info
 type MessageWithSender struct {
-	ID             uuid.UUID  `json:"id" db:"id"`
-	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
-	SenderID       uuid.UUID  `json:"sender_id" db:"sender_id"`
-	SenderName     string     `json:"sender_name" db:"sender_name"`
-	SenderAvatar   *string    `json:"sender_avatar,omitempty" db:"sender_avatar"`
-	MessageText    string     `json:"message_text" db:"message_text"`
-	ImageUrl       *string    `json:"image_url" db:"image_url"`
-	IsRead         bool       `json:"is_read" db:"is_read"`
-	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
-	DeletedAt      *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	ID             uuid.UUID           `json:"id" db:"id"`
+	ConversationID uuid.UUID           `json:"conversation_id" db:"conversation_id"`
+	SenderID       uuid.UUID           `json:"sender_id" db:"sender_id"`
+	SenderName     string              `json:"sender_name" db:"sender_name"`
+	SenderAvatar   *string             `json:"sender_avatar,omitempty" db:"sender_avatar"`
+	MessageText    string              `json:"message_text" db:"message_text"`
+	ImageUrl       *string             `json:"image_url" db:"image_url"`
+	MessageType    string              `json:"message_type" db:"message_type"`
+	Payload        json.RawMessage     `json:"payload,omitempty" db:"payload" swaggertype:"object"`
+	Attachments    []MessageAttachment `json:"attachments"`
+	IsRead         bool                `json:"is_read" db:"is_read"`
+	CreatedAt      time.Time           `json:"created_at" db:"created_at"`
+	EditedAt       *time.Time          `json:"edited_at,omitempty" db:"edited_at"`
+	DeletedAt      *time.Time          `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// MessageEdit is the text a message had before one of its edits
+type MessageEdit struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	MessageID    uuid.UUID `json:"message_id" db:"message_id"`
+	PreviousText string    `json:"previous_text" db:"previous_text"`
+	EditedAt     time.Time `json:"edited_at" db:"edited_at"`
+}
+
+// CreateConversationRequest starts a conversation without a first message
+type CreateConversationRequest struct {
+	RecipientID uuid.UUID  `json:"recipient_id" binding:"required"`
+	ProductID   *uuid.UUID `json:"product_id"`
 }
 
 // Creating a message request for Ably endpoint
 type CreateMessageRequest struct {
-	RecipientID uuid.UUID `json:"recipient_id" binding:"required"`
-	MessageText string    `json:"message_text" binding:"required,min=1,max=5000"`
-	ImageUrl    *string   `json:"image_url"`
+	RecipientID uuid.UUID  `json:"recipient_id" binding:"required"`
+	ProductID   *uuid.UUID `json:"product_id"`
+	MessageContent
 }
 
 // SendMessageRequest for sending to existing conversation
 type SendMessageRequest struct {
-	MessageText string  `json:"message_text"`
-	ImageUrl    *string `json:"image_url"`
+	MessageContent
+}
+
+// EditMessageRequest replaces the text of one of your messages
+type EditMessageRequest struct {
+	MessageText string `json:"message_text" binding:"required,min=1,max=5000"`
+}
+
+// DeletedMessage tells a syncing client to drop a message it may have cached
+type DeletedMessage struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	DeletedAt      time.Time `json:"deleted_at" db:"deleted_at"`
+}
+
+// ReadState is how far a participant has read a conversation
+type ReadState struct {
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	LastReadAt     time.Time `json:"last_read_at" db:"last_read_at"`
+}
+
+// MessageReceipt is one participant's delivered/read state for a message
+type MessageReceipt struct {
+	MessageID      uuid.UUID  `json:"message_id" db:"message_id"`
+	ConversationID uuid.UUID  `json:"conversation_id" db:"conversation_id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	DeliveredAt    *time.Time `json:"delivered_at" db:"delivered_at"`
+	ReadAt         *time.Time `json:"read_at" db:"read_at"`
+}
+
+// MaxGroupParticipants caps group size, including the owner
+const MaxGroupParticipants = 10
+
+// CreateGroupConversationRequest starts a group with the signed in user as owner
+type CreateGroupConversationRequest struct {
+	Title          string      `json:"title" binding:"required,min=1,max=100"`
+	ParticipantIDs []uuid.UUID `json:"participant_ids" binding:"required,min=2,max=9,dive,required"`
+	ProductID      *uuid.UUID  `json:"product_id"`
+}
+
+// AddParticipantsRequest adds users to a group conversation
+type AddParticipantsRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids" binding:"required,min=1,max=9,dive,required"`
 }
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// MessageCursor points at a message by its (created_at, id) position, ties on created_at are broken by id
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode returns the opaque string clients send back as before/after
+func (c MessageCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeMessageCursor parses a cursor made by Encode
+func DecodeMessageCursor(s string) (*MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	createdAtStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	return &MessageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// FeedCursor pins a For You session: every page is ranked at RankedAt over the products listed between
+// Oldest and RankedAt, so scores and the candidate pool don't move between pages. Offset is where the next page starts.
+type FeedCursor struct {
+	RankedAt time.Time
+	Oldest   time.Time
+	Offset   int
+}
+
+// Encode returns the opaque string clients send back as cursor
+func (c FeedCursor) Encode() string {
+	raw := c.RankedAt.UTC().Format(time.RFC3339Nano) + "|" + c.Oldest.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.Offset)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeFeedCursor parses a cursor made by Encode
+func DecodeFeedCursor(s string) (*FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return nil, errInvalidCursor
+	}
+
+	rankedAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	oldest, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	offset, err := strconv.Atoi(parts[2])
+	if err != nil || offset < 0 {
+		return nil, errInvalidCursor
+	}
+
+	return &FeedCursor{RankedAt: rankedAt, Oldest: oldest, Offset: offset}, nil
+}
+
+// MessagePageQuery selects a page of a conversation's messages.
+// Before and After are mutually exclusive, Offset is only used without a cursor for older clients.
+type MessagePageQuery struct {
+	Before *MessageCursor
+	After  *MessageCursor
+	Limit  int
+	Offset int
+}
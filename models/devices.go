@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxDevicesPerUser caps registered devices, the least recently seen are dropped first
+const MaxDevicesPerUser = 10
+
+// Device is a push token registered by one of the user's apps
+type Device struct {
+	DeviceID   uuid.UUID `json:"device_id" db:"device_id"`
+	UserID     uuid.UUID `json:"user_id" db:"user_id"`
+	Token      string    `json:"token" db:"token"`
+	Platform   string    `json:"platform" db:"platform"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
+// RegisterDeviceRequest registers (or refreshes) a push token for the signed in user
+type RegisterDeviceRequest struct {
+	Token    string `json:"token" binding:"required,max=4096"`
+	Platform string `json:"platform" binding:"required,oneof=android ios web" enums:"android,ios,web"`
+}
+
+// UnregisterDeviceRequest removes a push token, e.g. on sign out
+type UnregisterDeviceRequest struct {
+	Token string `json:"token" binding:"required,max=4096"`
+}
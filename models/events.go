@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types pushed on the /events stream
+const (
+	EventNotification  = "notification"
+	EventUnreadCount   = "unread_count"
+	EventMatch         = "match"
+	EventProductStatus = "product_status"
+)
+
+// UserEvent is one entry in a user's event log, ID is what clients send back as Last-Event-ID
+type UserEvent struct {
+	ID        int64           `json:"id" db:"event_id"`
+	UserID    uuid.UUID       `json:"user_id" db:"user_id"`
+	Type      string          `json:"type" db:"event_type"`
+	Payload   json.RawMessage `json:"payload" db:"payload" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// MatchEvent is the payload of a match event, ProductID is the receiving user's product
+type MatchEvent struct {
+	ProductID        uuid.UUID `json:"product_id"`
+	MatchedProductID uuid.UUID `json:"matched_product_id"`
+	MatchedUserID    uuid.UUID `json:"matched_user_id"`
+}
+
+// Realtime events published on conversation channels
+const (
+	RealtimeTyping            = "typing"
+	RealtimeMessagesDelivered = "messages_delivered"
+	RealtimeMessagesRead      = "messages_read"
+	RealtimePresence          = "presence"
+)
+
+// TypingEvent is published while a participant is composing a message
+type TypingEvent struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	IsTyping       bool      `json:"is_typing"`
+}
+
+// ReceiptEvent lists the messages one participant has received or read
+type ReceiptEvent struct {
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	UserID         uuid.UUID   `json:"user_id"`
+	MessageIDs     []uuid.UUID `json:"message_ids"`
+	At             time.Time   `json:"at"`
+}
+
+// PresenceEvent is published when a user comes online or times out
+type PresenceEvent struct {
+	UserID   uuid.UUID `json:"user_id"`
+	IsOnline bool      `json:"is_online"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// TypingRequest is the body of POST /conversations/:conversation_id/typing
+type TypingRequest struct {
+	IsTyping *bool `json:"is_typing" binding:"required"`
+}
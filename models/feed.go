@@ -0,0 +1,31 @@
+package models
+
+import "github.com/google/uuid"
+
+// Feed modes, latest is newest first and for_you is ranked for the viewer
+const (
+	FeedModeLatest = "latest"
+	FeedModeForYou = "for_you"
+)
+
+// Why a product was boosted in the For You feed
+const (
+	FeedReasonMatchesWants = "matches_your_wants"
+	FeedReasonWishlist     = "on_your_wishlist"
+	FeedReasonReciprocal   = "wants_what_you_list"
+	FeedReasonNearby       = "nearby"
+)
+
+// FeedCandidate is an active product with the For You signals it matches for the viewer, Score is set when it is ranked
+type FeedCandidate struct {
+	Item     FeedItem  `json:"item"`
+	SellerID uuid.UUID `json:"seller_id"`
+	// one of the viewer's own product wants asks for this category and size
+	MatchesWant bool `json:"matches_want"`
+	// the category and size are on the viewer's wishlist
+	MatchesWishlist bool `json:"matches_wishlist"`
+	// the seller wants a category the viewer lists, so a swap can go both ways
+	SellerWantsViewerCategory bool    `json:"seller_wants_viewer_category"`
+	Nearby                    bool    `json:"nearby"`
+	Score                     float64 `json:"score"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// What an upload is for, each purpose has its own storage folder
+const (
+	MediaPurposeAvatar  = "avatar"
+	MediaPurposeProduct = "product"
+	MediaPurposeChat    = "chat"
+)
+
+var mediaFolders = map[string]string{
+	MediaPurposeAvatar:  "avatars",
+	MediaPurposeProduct: "products",
+	MediaPurposeChat:    "chat",
+}
+
+// MediaFolder returns the storage folder of a purpose, empty for unknown purposes
+func MediaFolder(purpose string) string {
+	return mediaFolders[purpose]
+}
+
+// MaxMediaBytes is the largest upload accepted
+const MaxMediaBytes = 5 * 1024 * 1024
+
+// AllowedMediaTypes are the content types that can be uploaded, checked against the file's content rather than its name or headers
+var AllowedMediaTypes = []string{"image/jpeg", "image/png", "image/webp", "image/heic"}
+
+// Limits on the dimensions of uploaded images, in pixels
+const (
+	MinImageDimension = 32
+	MaxImageDimension = 8192
+	MaxImagePixels    = 40_000_000
+)
+
+// Variants generated for every uploaded image, the longest edge is scaled down to the size
+const (
+	MediaVariantThumbnail = "thumbnail"
+	MediaVariantFeed      = "feed"
+)
+
+// MediaVariantSizes maps each variant to the length of its longest edge
+var MediaVariantSizes = map[string]int{
+	MediaVariantThumbnail: 320,
+	MediaVariantFeed:      1080,
+}
+
+// Media is an uploaded file owned by the user who uploaded it
+type Media struct {
+	MediaID        uuid.UUID `json:"media_id"`
+	OwnerID        uuid.UUID `json:"owner_id"`
+	Purpose        string    `json:"purpose" enums:"avatar,product,chat"`
+	StorageBackend string    `json:"storage_backend" example:"cloudinary"`
+	StorageKey     string    `json:"storage_key"`
+	URL            string    `json:"url"`
+	ContentType    string    `json:"content_type" example:"image/jpeg"`
+	Bytes          int64     `json:"bytes"`
+	Width          int       `json:"width"`
+	Height         int       `json:"height"`
+	ThumbnailURL   string    `json:"thumbnail_url"`
+	FeedURL        string    `json:"feed_url"`
+	//perceptual hash for duplicate detection, nil for images with too little detail
+	PerceptualHash *int64    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type SignUploadRequest struct {
+	Purpose     string `json:"purpose" binding:"required,oneof=avatar product chat"`
+	ContentType string `json:"content_type" binding:"required,oneof=image/jpeg image/png image/webp image/heic"`
+}
+
+// SignedUpload tells the client how to upload straight to storage: send the file to UploadURL with
+// Method, adding Fields as multipart form fields when there are any, then confirm StorageKey
+type SignedUpload struct {
+	StorageKey string            `json:"storage_key"`
+	UploadURL  string            `json:"upload_url"`
+	Method     string            `json:"method" enums:"POST,PUT"`
+	FileField  string            `json:"file_field,omitempty" example:"file"`
+	Fields     map[string]string `json:"fields"`
+	MaxBytes   int64             `json:"max_bytes"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+}
+
+type ConfirmMediaRequest struct {
+	StorageKey string `json:"storage_key" binding:"required"`
+	Purpose    string `json:"purpose" binding:"required,oneof=avatar product chat"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message types users can send, besides text and system
+const (
+	MessageTypeImage           = "image"
+	MessageTypeProductCard     = "product_card"
+	MessageTypeSwapOffer       = "swap_offer"
+	MessageTypeMeetingLocation = "meeting_location"
+)
+
+// MaxMessageAttachments caps how many files one message can carry
+const MaxMessageAttachments = 10
+
+// MessageAttachment is a file sent with a message, shown in display_order
+type MessageAttachment struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	URL          string    `json:"url" db:"url"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	DisplayOrder int       `json:"display_order" db:"display_order"`
+}
+
+// AttachmentInput is one of the sender's uploads to attach to a message, by media_id or url.
+// content_type defaults to image.
+type AttachmentInput struct {
+	URL         string `json:"url" binding:"required" example:"3fa85f64-5717-4562-b3fc-2c963f66afa6"`
+	ContentType string `json:"content_type" binding:"omitempty,oneof=image video file"`
+}
+
+// ProductCardPayload shares a listing in a conversation, the API fills in title, status and image
+type ProductCardPayload struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Title     string    `json:"title"`
+	Status    string    `json:"status"`
+	ImageUrl  *string   `json:"image_url,omitempty"`
+}
+
+// SwapOfferPayload proposes exchanging the sender's products for products of the other participants
+type SwapOfferPayload struct {
+	OfferedProductIDs   []uuid.UUID `json:"offered_product_ids"`
+	RequestedProductIDs []uuid.UUID `json:"requested_product_ids"`
+	Note                string      `json:"note,omitempty"`
+}
+
+// MeetingLocationPayload suggests where (and optionally when) to meet for the swap
+type MeetingLocationPayload struct {
+	Name      string     `json:"name"`
+	Address   string     `json:"address,omitempty"`
+	Latitude  float64    `json:"latitude"`
+	Longitude float64    `json:"longitude"`
+	MeetAt    *time.Time `json:"meet_at,omitempty"`
+}
+
+// MessageContent is what a user sends, the payload shape depends on the type
+type MessageContent struct {
+	MessageType string            `json:"message_type" binding:"omitempty,oneof=text image product_card swap_offer meeting_location"`
+	MessageText string            `json:"message_text" binding:"max=5000"`
+	Payload     json.RawMessage   `json:"payload,omitempty" swaggertype:"object"`
+	Attachments []AttachmentInput `json:"attachments" binding:"max=10,dive"`
+	// Deprecated: send attachments instead. Like attachments it must be one of the sender's uploads.
+	ImageUrl *string `json:"image_url"`
+}
+
+// MessagePreview is the one line summary of a message shown in the conversation list
+func MessagePreview(messageType, messageText string, payload json.RawMessage, attachmentCount int) string {
+	switch messageType {
+	case MessageTypeImage:
+		if messageText != "" {
+			return "📷 " + messageText
+		}
+		if attachmentCount > 1 {
+			return fmt.Sprintf("📷 %d photos", attachmentCount)
+		}
+		return "📷 Photo"
+	case MessageTypeProductCard:
+		var card ProductCardPayload
+		if json.Unmarshal(payload, &card) == nil && card.Title != "" {
+			return "🏷️ " + card.Title
+		}
+		return "🏷️ Shared a listing"
+	case MessageTypeSwapOffer:
+		return "🔄 Swap offer"
+	case MessageTypeMeetingLocation:
+		var location MeetingLocationPayload
+		if json.Unmarshal(payload, &location) == nil && location.Name != "" {
+			return "📍 " + location.Name
+		}
+		return "📍 Meeting location"
+	}
+	return messageText
+}
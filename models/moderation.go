@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Why a listing is in the moderation queue
+const (
+	FlagReasonDuplicatePhoto = "duplicate_photo"
+	FlagReasonBlockedPhoto   = "blocked_photo"
+)
+
+// ModerationScreenProduct is the outbox event that screens the photos of a new listing
+const ModerationScreenProduct = "screen_product"
+
+// ScreenProductJob is the payload of a ModerationScreenProduct outbox entry
+type ScreenProductJob struct {
+	ProductID uuid.UUID `json:"product_id"`
+}
+
+const (
+	FlagStatusOpen      = "open"
+	FlagStatusDismissed = "dismissed"
+	FlagStatusActioned  = "actioned"
+)
+
+// What a moderator can do with a flag, remove_listing deactivates the listing and closes all of its flags
+const (
+	ModerationActionDismiss       = "dismiss"
+	ModerationActionRemoveListing = "remove_listing"
+)
+
+// ModerationFlag is a listing photo that matched another seller's photo or a blocked image.
+// Distance is how many of the 64 bits of the perceptual hashes differ, 0 is the same picture.
+type ModerationFlag struct {
+	FlagID           uuid.UUID  `json:"flag_id"`
+	ProductID        uuid.UUID  `json:"product_id"`
+	ProductTitle     string     `json:"product_title"`
+	ProductStatus    string     `json:"product_status" enums:"active,swapped,inactive"`
+	SellerID         uuid.UUID  `json:"seller_id"`
+	PhotoID          uuid.UUID  `json:"photo_id"`
+	PhotoURL         string     `json:"photo_url"`
+	Reason           string     `json:"reason" enums:"duplicate_photo,blocked_photo"`
+	MatchedPhotoID   *uuid.UUID `json:"matched_photo_id"`
+	MatchedPhotoURL  *string    `json:"matched_photo_url"`
+	MatchedProductID *uuid.UUID `json:"matched_product_id"`
+	MatchedSellerID  *uuid.UUID `json:"matched_seller_id"`
+	MatchedHashID    *uuid.UUID `json:"matched_hash_id"`
+	Distance         int        `json:"distance"`
+	Status           string     `json:"status" enums:"open,dismissed,actioned"`
+	ResolvedBy       *uuid.UUID `json:"resolved_by"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type ModerationFlagListResponse struct {
+	Items []ModerationFlag `json:"items"`
+	Meta  PaginationMeta   `json:"meta"`
+}
+
+type ResolveFlagRequest struct {
+	Action string `json:"action" binding:"required,oneof=dismiss remove_listing" enums:"dismiss,remove_listing"`
+}
+
+// BlockedImageHash is an image moderators banned, Hash is its perceptual hash in hex
+type BlockedImageHash struct {
+	HashID    uuid.UUID  `json:"hash_id"`
+	Hash      string     `json:"hash" example:"c4e0f0d8b8f0e0c0"`
+	Reason    string     `json:"reason"`
+	CreatedBy *uuid.UUID `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BlockImageRequest bans the image of a listing photo, or of an upload
+type BlockImageRequest struct {
+	PhotoID *uuid.UUID `json:"photo_id"`
+	MediaID *uuid.UUID `json:"media_id"`
+	Reason  string     `json:"reason" binding:"max=500"`
+}
+
+type BlockedImageListResponse struct {
+	Items []BlockedImageHash `json:"items"`
+}
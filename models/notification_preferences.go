@@ -0,0 +1,117 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification types users can set channel preferences for
+const (
+	PreferenceMatches  = "matches"
+	PreferenceMessages = "messages"
+	PreferenceSaved    = "saved_items"
+	PreferenceSearches = "saved_searches"
+)
+
+// PreferenceTypes lists the configurable notification types in the order they are shown
+var PreferenceTypes = []string{PreferenceMatches, PreferenceMessages, PreferenceSaved, PreferenceSearches}
+
+// Email digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+const DefaultTimezone = "UTC"
+
+// notificationTypes lists the stored notification_type values each preference controls
+var notificationTypes = map[string][]string{
+	PreferenceMatches:  {NotificationMutualMatch, NotificationMutualInterest},
+	PreferenceMessages: {"new_message"},
+	PreferenceSaved:    {NotificationSavedProductStatus, NotificationSavedProductWants},
+	PreferenceSearches: {NotificationSavedSearchMatches},
+}
+
+// PreferenceTypeFor maps a stored notification_type to the preference that controls it
+func PreferenceTypeFor(notificationType string) string {
+	for preferenceType, types := range notificationTypes {
+		if slices.Contains(types, notificationType) {
+			return preferenceType
+		}
+	}
+	return notificationType
+}
+
+// NotificationTypesFor expands a preference type to the stored notification_type values it covers,
+// anything else is taken as a stored type itself
+func NotificationTypesFor(preferenceType string) []string {
+	if types, ok := notificationTypes[preferenceType]; ok {
+		return types
+	}
+	return []string{preferenceType}
+}
+
+// ChannelPreference says where notifications of one type are delivered
+type ChannelPreference struct {
+	NotificationType string `json:"notification_type"`
+	InApp            bool   `json:"in_app"`
+	Push             bool   `json:"push"`
+	Email            bool   `json:"email"`
+}
+
+// DefaultChannelPreference is used for types the user never changed: every channel on
+func DefaultChannelPreference(notificationType string) ChannelPreference {
+	return ChannelPreference{NotificationType: notificationType, InApp: true, Push: true, Email: true}
+}
+
+// Any reports whether the notification is delivered anywhere at all
+func (p ChannelPreference) Any() bool {
+	return p.InApp || p.Push || p.Email
+}
+
+// NotificationSettings are the user's notification preferences
+type NotificationSettings struct {
+	Timezone        string              `json:"timezone" example:"Europe/Berlin"`
+	QuietHoursStart *string             `json:"quiet_hours_start" example:"22:00"`
+	QuietHoursEnd   *string             `json:"quiet_hours_end" example:"07:00"`
+	DigestFrequency string              `json:"digest_frequency" enums:"off,daily,weekly"`
+	Preferences     []ChannelPreference `json:"preferences"`
+}
+
+// ChannelPreferenceUpdate changes the channels of one type, omitted channels are left as they are
+type ChannelPreferenceUpdate struct {
+	NotificationType string `json:"notification_type" binding:"required,oneof=matches messages saved_items saved_searches"`
+	InApp            *bool  `json:"in_app"`
+	Push             *bool  `json:"push"`
+	Email            *bool  `json:"email"`
+}
+
+// UpdateNotificationSettingsRequest changes notification preferences, omitted fields are left as they are.
+// Quiet hours are set with both start and end in HH:MM, empty strings turn them off.
+type UpdateNotificationSettingsRequest struct {
+	Timezone        *string                   `json:"timezone" example:"Europe/Berlin"`
+	QuietHoursStart *string                   `json:"quiet_hours_start" binding:"omitempty,datetime=15:04" example:"22:00"`
+	QuietHoursEnd   *string                   `json:"quiet_hours_end" binding:"omitempty,datetime=15:04" example:"07:00"`
+	DigestFrequency *string                   `json:"digest_frequency" binding:"omitempty,oneof=off daily weekly"`
+	Preferences     []ChannelPreferenceUpdate `json:"preferences" binding:"omitempty,dive"`
+}
+
+// DigestRecipient is a user whose email digest is due
+type DigestRecipient struct {
+	UserID    uuid.UUID
+	Email     string
+	Username  string
+	Frequency string
+	Language  string
+	Since     time.Time
+}
+
+// Digest summarizes what a user missed since their last digest
+type Digest struct {
+	UnreadCount   int
+	NewMatchCount int
+	Notifications []Notifications
+}
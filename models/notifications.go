@@ -1,30 +1,117 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Notification types stored in notification_type
+const (
+	NotificationMutualMatch    = "Mutual Match"
+	NotificationMutualInterest = "Mutual interest"
+	// a saved product was swapped or taken down, params: product, status
+	NotificationSavedProductStatus = "saved_product_status"
+	// the seller of a saved product changed what they want for it, params: product, seller, category, size
+	NotificationSavedProductWants = "saved_product_wants"
+	// listings matching a saved search were created, params: count, search, search_id
+	NotificationSavedSearchMatches = "saved_search_matches"
+)
+
+// NotificationParams are the values a notification's templates are rendered with
+type NotificationParams map[string]string
+
+func (p NotificationParams) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *NotificationParams) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return fmt.Errorf("cannot scan %T into NotificationParams", src)
+}
+
 type Notifications struct {
-	Notification_ID         uuid.UUID  `json:"notification_id" db:"notification_id"`
-	User_ID                 uuid.UUID  `json:"user_id" db:"user_id"`
-	Notification_type       string     `json:"notification_type" db:"notification_type"`
-	Title                   string     `json:"title" db:"title"`
-	Message                 string     `json:"message" db:"message"`
-	Related_conversation_ID *uuid.UUID `json:"related_conversation_id"`
-	Related_product_ID      *uuid.UUID `json:"related_product_id" db:"related_product_id"`
-	Related_user_ID         *uuid.UUID `json:"related_user_id" db:"related_user_id"`
-	Is_Read                 bool       `json:"is_read" db:"is_read"`
-	Is_Pushed               bool       `json:"is_pushed" db:"is_pushed"`
-	Created_at              time.Time  `json:"created_at" db:"created_at"`
-	Read_at                 time.Time  `json:"read_at" db:"read_at"`
+	Notification_ID         uuid.UUID          `json:"notification_id" db:"notification_id"`
+	User_ID                 uuid.UUID          `json:"user_id" db:"user_id"`
+	Notification_type       string             `json:"notification_type" db:"notification_type"`
+	Title                   string             `json:"title" db:"title"`
+	Message                 string             `json:"message" db:"message"`
+	Related_conversation_ID *uuid.UUID         `json:"related_conversation_id" db:"related_conversation_id"`
+	Related_product_ID      *uuid.UUID         `json:"related_product_id" db:"related_product_id"`
+	Related_user_ID         *uuid.UUID         `json:"related_user_id" db:"related_user_id"`
+	Is_Read                 bool               `json:"is_read" db:"is_read"`
+	Is_Pushed               bool               `json:"is_pushed" db:"is_pushed"`
+	Created_at              time.Time          `json:"created_at" db:"created_at"`
+	Read_at                 *time.Time         `json:"read_at" db:"read_at"`
+	Params                  NotificationParams `json:"params" db:"params" swaggertype:"object,string"`
+	Deep_link               string             `json:"deep_link" db:"-" example:"pointswap://products/3f1c..."`
+}
+
+// DeepLinkScheme prefixes the app links notifications open
+const DeepLinkScheme = "pointswap://"
+
+// NotificationDeepLink is the screen the app opens for a notification: the conversation, else the product, else the
+// saved search, else the user
+func NotificationDeepLink(n Notifications) string {
+	switch {
+	case n.Related_conversation_ID != nil:
+		return DeepLinkScheme + "conversations/" + n.Related_conversation_ID.String()
+	case n.Related_product_ID != nil:
+		return DeepLinkScheme + "products/" + n.Related_product_ID.String()
+	case n.Notification_type == NotificationSavedSearchMatches && n.Params["search_id"] != "":
+		return DeepLinkScheme + "saved-searches/" + n.Params["search_id"]
+	case n.Related_user_ID != nil:
+		return DeepLinkScheme + "users/" + n.Related_user_ID.String()
+	}
+	return DeepLinkScheme + "notifications"
+}
+
+// NotificationGroup collapses notifications of one type about the same entity, newest first
+type NotificationGroup struct {
+	NotificationType string        `json:"notification_type"`
+	Count            int           `json:"count"`
+	UnreadCount      int           `json:"unread_count"`
+	Summary          string        `json:"summary" example:"3 new matches with ada"`
+	ActorName        *string       `json:"actor_name"`
+	Latest           Notifications `json:"latest"`
+}
+
+// NotificationIDsRequest selects notifications for a bulk action
+type NotificationIDsRequest struct {
+	NotificationIDs []uuid.UUID `json:"notification_ids" binding:"required,min=1,max=100"`
+}
+
+// BulkNotificationResponse reports how many notifications a bulk action changed
+type BulkNotificationResponse struct {
+	Affected int64 `json:"affected"`
+}
+
+// NotificationDelivery is a notification on its way to a device or inbox, with the language to render it in
+type NotificationDelivery struct {
+	Notifications
+	Language string
 }
 
+// MessageNotification is the push sent to offline participants for a new chat message
 type MessageNotification struct {
 	ConversationID   uuid.UUID `json:"conversation_id"`
 	MessageID        uuid.UUID `json:"message_id"`
 	SenderID         uuid.UUID `json:"sender_id"`
 	MessageType      string    `json:"message_type"`
 	NotificationType string    `json:"notification_type"`
+	Preview          string    `json:"preview"`
 }
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outbox destinations, realtime goes to a channel on the realtime backend, user_event to a user's /events log,
+// push to the devices of offline conversation participants, moderation to the photo screening of a new listing
+// and notification to a user's notifications
+const (
+	OutboxRealtime     = "realtime"
+	OutboxUserEvent    = "user_event"
+	OutboxPush         = "push"
+	OutboxModeration   = "moderation"
+	OutboxNotification = "notification"
+)
+
+// Outbox entry states, dead entries ran out of attempts and are kept for inspection
+const (
+	OutboxPending    = "pending"
+	OutboxDispatched = "dispatched"
+	OutboxDead       = "dead"
+)
+
+// OutboxEntry is an event waiting to be delivered once the transaction that wrote it commits
+type OutboxEntry struct {
+	ID             int64           `json:"id" db:"id"`
+	IdempotencyKey uuid.UUID       `json:"idempotency_key" db:"idempotency_key"`
+	Destination    string          `json:"destination" db:"destination"`
+	Channel        string          `json:"channel,omitempty" db:"channel"`
+	UserID         *uuid.UUID      `json:"user_id,omitempty" db:"user_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+// NewRealtimeOutboxEntry queues an event for everyone subscribed to channel
+func NewRealtimeOutboxEntry(channel, eventType string, payload any) (OutboxEntry, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEntry{}, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	return OutboxEntry{
+		IdempotencyKey: uuid.New(),
+		Destination:    OutboxRealtime,
+		Channel:        channel,
+		EventType:      eventType,
+		Payload:        data,
+	}, nil
+}
+
+// NewUserEventOutboxEntry queues an event for the user's /events stream
+func NewUserEventOutboxEntry(userID uuid.UUID, eventType string, payload any) (OutboxEntry, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEntry{}, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	return OutboxEntry{
+		IdempotencyKey: uuid.New(),
+		Destination:    OutboxUserEvent,
+		UserID:         &userID,
+		EventType:      eventType,
+		Payload:        data,
+	}, nil
+}
+
+// NewPushOutboxEntry queues a chat message push for the conversation's offline participants
+func NewPushOutboxEntry(payload MessageNotification) (OutboxEntry, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEntry{}, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	return OutboxEntry{
+		IdempotencyKey: uuid.New(),
+		Destination:    OutboxPush,
+		EventType:      payload.NotificationType,
+		Payload:        data,
+	}, nil
+}
+
+// NewScreenProductOutboxEntry queues the photo screening of a new listing
+func NewScreenProductOutboxEntry(productID uuid.UUID) (OutboxEntry, error) {
+	data, err := json.Marshal(ScreenProductJob{ProductID: productID})
+	if err != nil {
+		return OutboxEntry{}, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	return OutboxEntry{
+		IdempotencyKey: uuid.New(),
+		Destination:    OutboxModeration,
+		EventType:      ModerationScreenProduct,
+		Payload:        data,
+	}, nil
+}
+
+// NotificationJob is a notification waiting in the outbox, it is rendered in the recipient's language on delivery
+type NotificationJob struct {
+	UserID           uuid.UUID          `json:"user_id"`
+	NotificationType string             `json:"notification_type"`
+	Params           NotificationParams `json:"params"`
+	RelatedProductID *uuid.UUID         `json:"related_product_id,omitempty"`
+	RelatedUserID    *uuid.UUID         `json:"related_user_id,omitempty"`
+}
+
+// NewNotificationOutboxEntry queues a notification, the entry's idempotency key becomes the notification's id
+func NewNotificationOutboxEntry(job NotificationJob) (OutboxEntry, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return OutboxEntry{}, fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+
+	return OutboxEntry{
+		IdempotencyKey: uuid.New(),
+		Destination:    OutboxNotification,
+		UserID:         &job.UserID,
+		EventType:      job.NotificationType,
+		Payload:        data,
+	}, nil
+}
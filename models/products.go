@@ -23,6 +23,9 @@ type ProductPhotos struct {
 	Image_Url     string    `json:"image_url" db:"image_url"`
 	Display_order int       `json:"display_order" db:"display_order"`
 	Created_at    time.Time `json:"created_at" db:"created_at"`
+	//smaller copies of the image, null for photos that weren't uploaded through the API
+	Thumbnail_Url *string `json:"thumbnail_url" db:"thumbnail_url"`
+	Feed_Url      *string `json:"feed_url" db:"feed_url"`
 }
 
 //Model for when you click on a product and want to preview it in full detail
@@ -40,9 +43,16 @@ type ProductWithSeller struct {
 	Photos         []ProductPhotos `json:"photos"`
 	Created_at     time.Time       `json:"created_at"`
 	Updated_at     time.Time       `json:"updated_at"`
+	//the signed in viewer's conversation with the seller about this product, if one exists
+	Conversation_ID *uuid.UUID `json:"conversation_id,omitempty"`
+	//how many users saved this product, only shown to its seller
+	Save_count *int `json:"save_count,omitempty"`
+	//whether the signed in viewer saved this product
+	Is_saved bool `json:"is_saved"`
 }
 
 // Models required for creating a product upload request
+// Image_Urls are media_ids or urls of the seller's uploads, in display order
 type CreateProductRequest struct {
 	Category       string   `json:"category" binding:"required"`
 	Image_Urls     []string `json:"image_urls"  binding:"required,min=1"`
@@ -50,6 +60,10 @@ type CreateProductRequest struct {
 	Estimated_size string   ` json:"estimated_size" binding:"required"`
 }
 
+type UpdateProductStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=active swapped inactive" enums:"active,swapped,inactive"`
+}
+
 // (Inactive)
 
 //To preview your product before post
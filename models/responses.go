@@ -0,0 +1,138 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//Response payloads returned in the "data" field of utils.Response
+
+type AuthResponse struct {
+	User  Users  `json:"user"`
+	Token string `json:"token"`
+}
+
+type ProfileSetUpResponse struct {
+	ProfileUpdate Users `json:"profile_Update"`
+}
+
+type LocationResponse struct {
+	Location Users `json:"location"`
+	User     Users `json:"user"`
+}
+
+type UserStatusResponse struct {
+	IsOnline bool       `json:"is_online"`
+	LastSeen *time.Time `json:"last_seen"`
+}
+
+type CreateProductResponse struct {
+	Product   ProductWithSeller `json:"product"`
+	ProductID uuid.UUID         `json:"product_id"`
+}
+
+//One card in the main feed
+
+type FeedItem struct {
+	Product_ID     uuid.UUID `json:"product_id"`
+	Title          string    `json:"title"`
+	Estimated_size *string   `json:"estimated_size"`
+	Created_at     time.Time `json:"created_at"`
+	Image_Url      *string   `json:"image_url"`
+	//why a For You item was boosted, empty in the latest feed
+	Reasons []string `json:"reasons,omitempty" enums:"matches_your_wants,on_your_wishlist,wants_what_you_list,nearby"`
+}
+
+type FeedResponse struct {
+	Items []FeedItem `json:"items"`
+	//only set for the For You feed, which is paginated
+	Meta *PaginationMeta `json:"meta,omitempty"`
+	//For You only, send it back as cursor for the next page of the same ranking
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
+type ProductStatusResponse struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Status    string    `json:"status"`
+}
+
+type ProductIDResponse struct {
+	ProductID uuid.UUID `json:"product_id"`
+}
+
+type WantIDResponse struct {
+	WantID uuid.UUID `json:"want_id"`
+}
+
+type ConversationIDResponse struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+}
+
+type SendMessageResponse struct {
+	Message        *Message  `json:"message"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+}
+
+type MessageResponse struct {
+	Message *Message `json:"message"`
+}
+
+type AddParticipantsResponse struct {
+	Added []uuid.UUID `json:"added"`
+}
+
+type MessageEditListResponse struct {
+	Edits []MessageEdit `json:"edits"`
+}
+
+type ConversationListResponse struct {
+	Conversations []ConversationWithDetails `json:"conversations"`
+}
+
+type MessageListResponse struct {
+	Messages []MessageWithSender `json:"messages"`
+	Limit    int                 `json:"limit"`
+	Offset   int                 `json:"offset"`
+	HasMore  bool                `json:"has_more"`
+	// cursors for the oldest and newest message on this page, send as before/after to page from them
+	BeforeCursor *string `json:"before_cursor,omitempty"`
+	AfterCursor  *string `json:"after_cursor,omitempty"`
+}
+
+// SyncResponse is everything that changed in the user's conversations since the requested cursor or time.
+// Call again with cursor=next_cursor until has_more is false, and keep next_cursor for the next catch-up.
+type SyncResponse struct {
+	Messages   []MessageWithSender `json:"messages"`
+	Deleted    []DeletedMessage    `json:"deleted"`
+	ReadStates []ReadState         `json:"read_states"`
+	Receipts   []MessageReceipt    `json:"receipts"`
+	HasMore    bool                `json:"has_more"`
+	NextCursor string              `json:"next_cursor"`
+}
+
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+type UploadImageResponse struct {
+	ImageUrl     string    `json:"image_url"`
+	PublicID     string    `json:"public_id"`
+	MediaID      uuid.UUID `json:"media_id"`
+	ThumbnailUrl string    `json:"thumbnail_url"`
+	FeedUrl      string    `json:"feed_url"`
+}
+
+type NotificationListResponse struct {
+	Items []Notifications `json:"items"`
+	Meta  PaginationMeta  `json:"meta"`
+}
+
+type NotificationGroupListResponse struct {
+	Items []NotificationGroup `json:"items"`
+	Meta  PaginationMeta      `json:"meta"`
+}
+
+type UnreadCountResponse struct {
+	UnreadCount int `json:"unread_count"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SavedProduct is a product in the user's saved list, it stays listed after it is swapped or taken down
+type SavedProduct struct {
+	Product_ID     uuid.UUID `json:"product_id"`
+	Title          string    `json:"title"`
+	Category       string    `json:"category"`
+	Estimated_size *string   `json:"estimated_size"`
+	Status         string    `json:"status" enums:"active,swapped,inactive"`
+	Image_Url      *string   `json:"image_url"`
+	Created_at     time.Time `json:"created_at"`
+	Saved_at       time.Time `json:"saved_at"`
+}
+
+type SavedProductListResponse struct {
+	Items []SavedProduct `json:"items"`
+	Meta  PaginationMeta `json:"meta"`
+}
+
+type SaveProductResponse struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Saved     bool      `json:"saved"`
+}
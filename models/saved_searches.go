@@ -0,0 +1,85 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How often a saved search alerts about new listings, off keeps the search without alerts
+const (
+	SearchFrequencyInstant = "instant"
+	SearchFrequencyDaily   = "daily"
+	SearchFrequencyWeekly  = "weekly"
+	SearchFrequencyOff     = "off"
+)
+
+// MaxSavedSearches is how many searches one user can save
+const MaxSavedSearches = 20
+
+// SavedSearch is a feed filter the user is alerted about. Every word of Query has to appear in the title
+// or category. Users only have a location name, so any RadiusKm keeps sellers in the user's location, the same
+// test the For You feed uses for nearby.
+type SavedSearch struct {
+	SearchID      uuid.UUID `json:"search_id"`
+	UserID        uuid.UUID `json:"-"`
+	Query         string    `json:"query" example:"vintage denim"`
+	Category      *string   `json:"category" example:"jeans"`
+	Size          *string   `json:"size" example:"M"`
+	RadiusKm      *int      `json:"radius_km" example:"10"`
+	Frequency     string    `json:"frequency" enums:"instant,daily,weekly,off"`
+	Label         string    `json:"label" example:"vintage denim, jeans, M, nearby"`
+	LastCheckedAt time.Time `json:"last_checked_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Describe sums up the filters in one line for lists and alerts, e.g. "vintage denim, M, nearby"
+func (s SavedSearch) Describe() string {
+	var parts []string
+	if query := strings.TrimSpace(s.Query); query != "" {
+		parts = append(parts, query)
+	}
+	if s.Category != nil {
+		parts = append(parts, *s.Category)
+	}
+	if s.Size != nil {
+		parts = append(parts, *s.Size)
+	}
+	if s.RadiusKm != nil {
+		parts = append(parts, FeedReasonNearby)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CreateSavedSearchRequest saves a search, at least one of query, category and size is required.
+// frequency defaults to daily.
+type CreateSavedSearchRequest struct {
+	Query     string  `json:"query" binding:"max=100" example:"vintage denim"`
+	Category  *string `json:"category" binding:"omitempty,max=50" example:"jeans"`
+	Size      *string `json:"size" binding:"omitempty,max=20" example:"M"`
+	RadiusKm  *int    `json:"radius_km" binding:"omitempty,min=1,max=500" example:"10"`
+	Frequency string  `json:"frequency" binding:"omitempty,oneof=instant daily weekly off" enums:"instant,daily,weekly,off"`
+}
+
+// UpdateSavedSearchRequest changes a saved search, omitted fields are left as they are.
+// An empty category or size and a radius_km of 0 remove that filter.
+type UpdateSavedSearchRequest struct {
+	Query     *string `json:"query" binding:"omitempty,max=100" example:"vintage denim"`
+	Category  *string `json:"category" binding:"omitempty,max=50" example:"jeans"`
+	Size      *string `json:"size" binding:"omitempty,max=20" example:"M"`
+	RadiusKm  *int    `json:"radius_km" binding:"omitempty,min=0,max=500" example:"10"`
+	Frequency *string `json:"frequency" binding:"omitempty,oneof=instant daily weekly off" enums:"instant,daily,weekly,off"`
+}
+
+type SavedSearchListResponse struct {
+	Items []SavedSearch `json:"items"`
+}
+
+// SavedSearchDue is a claimed saved search and the window of listings to check it against
+type SavedSearchDue struct {
+	Search SavedSearch
+	Since  time.Time
+	Until  time.Time
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"postswapapi/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventRepository struct {
+	db *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// AppendEvent writes an event to the user's log and returns it with its id
+func (r *EventRepository) AppendEvent(ctx context.Context, userID uuid.UUID, eventType string, payload any) (*models.UserEvent, error) {
+	ctx, span := tracer.Start(ctx, "EventRepository.AppendEvent")
+	defer span.End()
+
+	return r.appendEvent(ctx, nil, userID, eventType, payload)
+}
+
+// AppendEventOnce is AppendEvent for redeliverable events, it returns nil when the key was already logged
+func (r *EventRepository) AppendEventOnce(ctx context.Context, idempotencyKey, userID uuid.UUID, eventType string, payload any) (*models.UserEvent, error) {
+	ctx, span := tracer.Start(ctx, "EventRepository.AppendEventOnce")
+	defer span.End()
+
+	event, err := r.appendEvent(ctx, &idempotencyKey, userID, eventType, payload)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return event, err
+}
+
+func (r *EventRepository) appendEvent(ctx context.Context, idempotencyKey *uuid.UUID, userID uuid.UUID, eventType string, payload any) (*models.UserEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	event := &models.UserEvent{
+		UserID:  userID,
+		Type:    eventType,
+		Payload: data,
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// one writer per user at a time, so a user's event ids commit in order and a stream reading
+	// after its last id never passes over an id that commits later
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1::text, 0))`, userID); err != nil {
+		return nil, fmt.Errorf("failed to lock event log: %w", err)
+	}
+
+	query := `
+        INSERT INTO user_events (user_id, event_type, payload, idempotency_key)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
+        RETURNING event_id, created_at
+    `
+
+	err = tx.QueryRowContext(ctx, query, userID, eventType, data, idempotencyKey).Scan(&event.ID, &event.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to append event: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit event: %w", err)
+	}
+
+	return event, nil
+}
+
+// Now returns the database clock, event ages are measured with it rather than the app server's
+func (r *EventRepository) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := r.db.QueryRowContext(ctx, `SELECT NOW()`).Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read database time: %w", err)
+	}
+	return now, nil
+}
+
+// GetEventsAfter returns the user's events with an id greater than afterID, oldest first
+func (r *EventRepository) GetEventsAfter(ctx context.Context, userID uuid.UUID, afterID int64, limit int) ([]models.UserEvent, error) {
+	ctx, span := tracer.Start(ctx, "EventRepository.GetEventsAfter")
+	defer span.End()
+
+	events := []models.UserEvent{}
+
+	query := `
+        SELECT event_id, user_id, event_type, payload, created_at
+        FROM user_events
+        WHERE user_id = $1 AND event_id > $2
+        ORDER BY event_id ASC
+        LIMIT $3
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.UserEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Type, &event.Payload, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %w", err)
+	}
+
+	return events, nil
+}
+
+// DeleteEventsBefore prunes the event log, returns how many rows were removed
+func (r *EventRepository) DeleteEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "EventRepository.DeleteEventsBefore")
+	defer span.End()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// GetProductConversationUserIDs returns everyone in a conversation about the product except excludeUserID,
+// read through db so it can be part of the caller's transaction
+func (r *EventRepository) GetProductConversationUserIDs(ctx context.Context, db DBTX, productID, excludeUserID uuid.UUID) ([]uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "EventRepository.GetProductConversationUserIDs")
+	defer span.End()
+
+	query := `
+        SELECT DISTINCT cp.user_id
+        FROM conversations c
+        INNER JOIN conversation_participants cp ON cp.conversation_id = c.id
+        WHERE c.related_product_id = $1 AND cp.user_id != $2
+    `
+
+	rows, err := db.QueryContext(ctx, query, productID, excludeUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product conversation users: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan product conversation user: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
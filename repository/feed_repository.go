@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"postswapapi/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type FeedRepository struct {
+	db *sql.DB
+}
+
+func NewFeedRepository(db *sql.DB) *FeedRepository {
+	return &FeedRepository{db: db}
+}
+
+// GetFeedCandidates returns up to limit of the newest active products of other sellers listed between oldest
+// and newest with the signals they match for the viewer, optionally in one category. An anonymous viewer
+// (uuid.Nil) matches no signals.
+func (r *FeedRepository) GetFeedCandidates(ctx context.Context, viewerID uuid.UUID, category string, oldest, newest time.Time, limit int) ([]models.FeedCandidate, error) {
+	ctx, span := tracer.Start(ctx, "FeedRepository.GetFeedCandidates")
+	defer span.End()
+
+	// a size left empty on a want or wishlist entry accepts any size
+	query := `
+        WITH viewer AS (
+            SELECT LOWER(TRIM(location)) AS location FROM users WHERE user_id = $1
+        ),
+        viewer_wants AS (
+            SELECT pw.wanted_category, pw.wanted_size
+            FROM product_wants pw
+            JOIN products p ON p.product_id = pw.product_id AND p.status = 'active'
+            WHERE pw.want_user_id = $1
+        ),
+        viewer_wishlist AS (
+            SELECT category, size FROM user_preferences WHERE user_id = $1 AND is_active
+        ),
+        viewer_categories AS (
+            SELECT DISTINCT category FROM products WHERE seller_id = $1 AND status = 'active'
+        ),
+        candidates AS (
+            SELECT p.product_id, p.seller_id, p.title, p.category, p.estimated_size, p.created_at
+            FROM products p
+            WHERE p.status = 'active' AND p.seller_id <> $1 AND ($2 = '' OR p.category = $2)
+                AND p.created_at >= $4 AND p.created_at <= $5
+            ORDER BY p.created_at DESC
+            LIMIT $3
+        )
+        SELECT c.product_id, c.seller_id, c.title, c.estimated_size, c.created_at, COALESCE(pp.feed_url, pp.image_url),
+            EXISTS (
+                SELECT 1 FROM viewer_wants w
+                WHERE w.wanted_category = c.category
+                    AND (COALESCE(w.wanted_size, '') = '' OR w.wanted_size = c.estimated_size)
+            ),
+            EXISTS (
+                SELECT 1 FROM viewer_wishlist w
+                WHERE w.category = c.category
+                    AND (COALESCE(w.size, '') = '' OR w.size = c.estimated_size)
+            ),
+            EXISTS (
+                SELECT 1 FROM product_wants sw
+                JOIN products sp ON sp.product_id = sw.product_id AND sp.status = 'active'
+                JOIN viewer_categories vc ON vc.category = sw.wanted_category
+                WHERE sw.want_user_id = c.seller_id
+            ),
+            COALESCE((SELECT v.location FROM viewer v) <> '' AND LOWER(TRIM(u.location)) = (SELECT v.location FROM viewer v), FALSE)
+        FROM candidates c
+        JOIN users u ON u.user_id = c.seller_id
+        LEFT JOIN product_photos pp ON pp.product_id = c.product_id AND pp.display_order = 1
+        ORDER BY c.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, viewerID, category, limit, oldest, newest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed candidates: %w", err)
+	}
+	defer rows.Close()
+
+	candidates := []models.FeedCandidate{}
+	for rows.Next() {
+		var candidate models.FeedCandidate
+		if err := rows.Scan(
+			&candidate.Item.Product_ID,
+			&candidate.SellerID,
+			&candidate.Item.Title,
+			&candidate.Item.Estimated_size,
+			&candidate.Item.Created_at,
+			&candidate.Item.Image_Url,
+			&candidate.MatchesWant,
+			&candidate.MatchesWishlist,
+			&candidate.SellerWantsViewerCategory,
+			&candidate.Nearby,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan feed candidate: %w", err)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, rows.Err()
+}
@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"postswapapi/models"
+	"postswapapi/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type MediaRepository struct {
+	db *sql.DB
+}
+
+func NewMediaRepository(db *sql.DB) *MediaRepository {
+	return &MediaRepository{db: db}
+}
+
+const mediaColumns = `media_id, owner_id, purpose, storage_backend, storage_key, url, content_type, bytes, width, height,
+    thumbnail_url, feed_url, phash, created_at`
+
+func scanMedia(row interface{ Scan(...any) error }, media *models.Media) error {
+	return row.Scan(
+		&media.MediaID,
+		&media.OwnerID,
+		&media.Purpose,
+		&media.StorageBackend,
+		&media.StorageKey,
+		&media.URL,
+		&media.ContentType,
+		&media.Bytes,
+		&media.Width,
+		&media.Height,
+		&media.ThumbnailURL,
+		&media.FeedURL,
+		&media.PerceptualHash,
+		&media.CreatedAt,
+	)
+}
+
+// CreateMedia records an uploaded file, confirming the same storage key twice returns the first record.
+// The key stops being a pending upload in the same statement.
+func (r *MediaRepository) CreateMedia(ctx context.Context, media *models.Media) (*models.Media, error) {
+	ctx, span := tracer.Start(ctx, "MediaRepository.CreateMedia")
+	defer span.End()
+
+	query := `
+        WITH confirmed AS (
+            DELETE FROM media_uploads WHERE storage_key = $5
+        )
+        INSERT INTO media (media_id, owner_id, purpose, storage_backend, storage_key, url, content_type, bytes, width, height,
+            thumbnail_url, feed_url, phash)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+        ON CONFLICT (storage_key) DO UPDATE SET storage_key = EXCLUDED.storage_key
+        RETURNING ` + mediaColumns
+
+	created := &models.Media{}
+	err := scanMedia(r.db.QueryRowContext(ctx, query,
+		media.MediaID,
+		media.OwnerID,
+		media.Purpose,
+		media.StorageBackend,
+		media.StorageKey,
+		media.URL,
+		media.ContentType,
+		media.Bytes,
+		media.Width,
+		media.Height,
+		media.ThumbnailURL,
+		media.FeedURL,
+		media.PerceptualHash,
+	), created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record media: %w", err)
+	}
+
+	return created, nil
+}
+
+// CreatePendingUpload records a key signed for an upload, so it is cleaned up if the upload is never confirmed
+func (r *MediaRepository) CreatePendingUpload(ctx context.Context, storageKey string, ownerID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "MediaRepository.CreatePendingUpload")
+	defer span.End()
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO media_uploads (storage_key, owner_id) VALUES ($1, $2)`, storageKey, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to record pending upload: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePendingUploadsBefore removes up to limit keys signed before the cutoff that were never confirmed,
+// returning them so whatever was uploaded under them can be deleted from storage
+func (r *MediaRepository) DeletePendingUploadsBefore(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MediaRepository.DeletePendingUploadsBefore")
+	defer span.End()
+
+	query := `
+        DELETE FROM media_uploads
+        WHERE storage_key IN (
+            SELECT u.storage_key FROM media_uploads u
+            WHERE u.created_at < $1
+                AND NOT EXISTS (SELECT 1 FROM media m WHERE m.storage_key = u.storage_key)
+            ORDER BY u.created_at
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING storage_key`
+
+	rows, err := r.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete pending uploads: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan pending upload: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetMediaByKey returns the media stored under a storage key
+func (r *MediaRepository) GetMediaByKey(ctx context.Context, storageKey string) (*models.Media, error) {
+	ctx, span := tracer.Start(ctx, "MediaRepository.GetMediaByKey")
+	defer span.End()
+
+	media := &models.Media{}
+	err := scanMedia(r.db.QueryRowContext(ctx, `SELECT `+mediaColumns+` FROM media WHERE storage_key = $1`, storageKey), media)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("media not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media: %w", err)
+	}
+
+	return media, nil
+}
+
+// AttachMedia marks the owner's uploads matching refs, by media ID or URL, as in use and returns them.
+// Refs that aren't one of the owner's uploads are left out of the result. Pass the transaction that saves
+// whatever the uploads are attached to, so they stay unattached when it rolls back.
+func (r *MediaRepository) AttachMedia(ctx context.Context, db DBTX, ownerID uuid.UUID, refs []string) ([]models.Media, error) {
+	ctx, span := tracer.Start(ctx, "MediaRepository.AttachMedia")
+	defer span.End()
+
+	query := `
+        UPDATE media SET attached_at = COALESCE(attached_at, NOW())
+        WHERE owner_id = $1 AND (media_id::text = ANY($2) OR url = ANY($2))
+        RETURNING ` + mediaColumns
+
+	rows, err := db.QueryContext(ctx, query, ownerID, pq.Array(refs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach media: %w", err)
+	}
+	defer rows.Close()
+
+	var attached []models.Media
+	for rows.Next() {
+		var media models.Media
+		if err := scanMedia(rows, &media); err != nil {
+			return nil, fmt.Errorf("failed to scan media: %w", err)
+		}
+		attached = append(attached, media)
+	}
+
+	return attached, rows.Err()
+}
+
+// DeleteUnattachedBefore removes up to limit uploads created before the cutoff that were never attached,
+// returning them so their files can be deleted from storage
+func (r *MediaRepository) DeleteUnattachedBefore(ctx context.Context, before time.Time, limit int) ([]models.Media, error) {
+	ctx, span := tracer.Start(ctx, "MediaRepository.DeleteUnattachedBefore")
+	defer span.End()
+
+	query := `
+        DELETE FROM media
+        WHERE media_id IN (
+            SELECT media_id FROM media
+            WHERE attached_at IS NULL AND created_at < $1
+            ORDER BY created_at
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        ) AND attached_at IS NULL
+        RETURNING ` + mediaColumns
+
+	rows, err := r.db.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete unattached media: %w", err)
+	}
+	defer rows.Close()
+
+	var deleted []models.Media
+	for rows.Next() {
+		var media models.Media
+		if err := scanMedia(rows, &media); err != nil {
+			return nil, fmt.Errorf("failed to scan media: %w", err)
+		}
+		deleted = append(deleted, media)
+	}
+
+	return deleted, rows.Err()
+}
@@ -1,14 +1,22 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"postswapapi/models"
+	"postswapapi/utils"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("postswapapi/repository")
+
 type MessageRepository struct {
 	db *sql.DB
 }
@@ -17,11 +25,15 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
-// GetOrCreateConversation finds existing conversation or creates new one between two users
-func (r *MessageRepository) GetOrCreateConversation(user1ID, user2ID uuid.UUID) (uuid.UUID, error) {
+// GetOrCreateConversation finds existing conversation or creates new one between two users.
+// A conversation about a product is kept apart from the pair's general one and from their other products.
+func (r *MessageRepository) GetOrCreateConversation(ctx context.Context, user1ID, user2ID uuid.UUID, productID *uuid.UUID) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetOrCreateConversation")
+	defer span.End()
+
 	var conversationID uuid.UUID
 
-	// Find conversation where BOTH users are participants and ONLY these 2 users
+	// Find conversation where BOTH users are participants and ONLY these 2 users, about the same product (or none)
 	query := `
         SELECT c.id 
         FROM conversations c
@@ -37,10 +49,12 @@ func (r *MessageRepository) GetOrCreateConversation(user1ID, user2ID uuid.UUID)
             SELECT COUNT(*) FROM conversation_participants cp 
             WHERE cp.conversation_id = c.id
         ) = 2
+        AND c.related_product_id IS NOT DISTINCT FROM $3
+        AND NOT c.is_group
         LIMIT 1
     `
 
-	err := r.db.QueryRow(query, user1ID, user2ID).Scan(&conversationID)
+	err := r.db.QueryRowContext(ctx, query, user1ID, user2ID, productID).Scan(&conversationID)
 
 	// If conversation exists, return it
 	if err == nil {
@@ -51,10 +65,10 @@ func (r *MessageRepository) GetOrCreateConversation(user1ID, user2ID uuid.UUID)
 	if err == sql.ErrNoRows {
 		// Create new conversation
 		insertConvQuery := `
-            INSERT INTO conversations DEFAULT VALUES 
+            INSERT INTO conversations (related_product_id) VALUES ($1)
             RETURNING id
         `
-		err = r.db.QueryRow(insertConvQuery).Scan(&conversationID)
+		err = r.db.QueryRowContext(ctx, insertConvQuery, productID).Scan(&conversationID)
 		if err != nil {
 			return uuid.Nil, fmt.Errorf("failed to create conversation: %w", err)
 		}
@@ -66,7 +80,7 @@ func (r *MessageRepository) GetOrCreateConversation(user1ID, user2ID uuid.UUID)
                 (gen_random_uuid(), $1, $2),
                 (gen_random_uuid(), $1, $3)
         `
-		_, err = r.db.Exec(insertParticipantsQuery, conversationID, user1ID, user2ID)
+		_, err = r.db.ExecContext(ctx, insertParticipantsQuery, conversationID, user1ID, user2ID)
 		if err != nil {
 			return uuid.Nil, fmt.Errorf("failed to add participants: %w", err)
 		}
@@ -77,84 +91,414 @@ func (r *MessageRepository) GetOrCreateConversation(user1ID, user2ID uuid.UUID)
 	return uuid.Nil, fmt.Errorf("failed to get or create conversation: %w", err)
 }
 
-// CreateMessage saves a new message to the database
-func (r *MessageRepository) CreateMessage(conversationID, senderID uuid.UUID, messageText string, imageURL *string) (*models.Message, error) {
-	// If there's an image but no text, set default text
-	if messageText == "" && imageURL != nil {
-		messageText = "📷 Image"
+// OutboxFunc builds the outbox entries announcing a change to a message, it runs inside the change's transaction
+type OutboxFunc func(message *models.Message) ([]models.OutboxEntry, error)
+
+// enqueueFor writes the entries outbox builds for message, a nil outbox writes nothing
+func enqueueFor(ctx context.Context, tx DBTX, outbox OutboxFunc, message *models.Message) error {
+	if outbox == nil {
+		return nil
+	}
+
+	entries, err := outbox(message)
+	if err != nil {
+		return err
+	}
+
+	return EnqueueOutbox(ctx, tx, entries...)
+}
+
+// AttachFunc resolves a new message's attachments to the sender's uploads inside the message's transaction,
+// rewriting their URLs, so the uploads are only marked in use if the message is saved
+type AttachFunc func(ctx context.Context, tx DBTX, attachments []models.AttachmentInput) error
+
+// CreateMessage saves a new message with its payload and attachments, a nil attach saves the attachments as they are
+func (r *MessageRepository) CreateMessage(ctx context.Context, conversationID, senderID uuid.UUID, messageType, messageText string, payload json.RawMessage, attachments []models.AttachmentInput, attach AttachFunc, outbox OutboxFunc) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.CreateMessage")
+	defer span.End()
+
+	return r.insertMessage(ctx, conversationID, senderID, messageType, messageText, payload, attachments, attach, outbox)
+}
+
+// CreateSystemMessage saves an API-written notice in a conversation, attributed to senderID
+func (r *MessageRepository) CreateSystemMessage(ctx context.Context, conversationID, senderID uuid.UUID, messageText string, outbox OutboxFunc) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.CreateSystemMessage")
+	defer span.End()
+
+	return r.insertMessage(ctx, conversationID, senderID, models.MessageTypeSystem, messageText, nil, nil, nil, outbox)
+}
+
+func (r *MessageRepository) insertMessage(ctx context.Context, conversationID, senderID uuid.UUID, messageType, messageText string, payload json.RawMessage, attachments []models.AttachmentInput, attach AttachFunc, outbox OutboxFunc) (*models.Message, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	message, err := insertMessageIn(ctx, tx, conversationID, senderID, messageType, messageText, payload, attachments, attach, outbox)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit message: %w", err)
+	}
+
+	return message, nil
+}
+
+// CreateProductSystemMessages posts a notice in every conversation about the product as part of tx, attributed to senderID
+func (r *MessageRepository) CreateProductSystemMessages(ctx context.Context, tx DBTX, productID, senderID uuid.UUID, messageText string, outbox OutboxFunc) error {
+	ctx, span := tracer.Start(ctx, "MessageRepository.CreateProductSystemMessages")
+	defer span.End()
+
+	conversationIDs, err := r.scanIDs(tx.QueryContext(ctx, `SELECT id FROM conversations WHERE related_product_id = $1`, productID))
+	if err != nil {
+		return fmt.Errorf("failed to get product conversations: %w", err)
+	}
+
+	for _, conversationID := range conversationIDs {
+		_, err := insertMessageIn(ctx, tx, conversationID, senderID, models.MessageTypeSystem, messageText, nil, nil, nil, outbox)
+		if err != nil {
+			return err
+		}
 	}
 
+	return nil
+}
+
+// insertMessageIn saves a message with its attachments and outbox entries as part of tx
+func insertMessageIn(ctx context.Context, tx DBTX, conversationID, senderID uuid.UUID, messageType, messageText string, payload json.RawMessage, attachments []models.AttachmentInput, attach AttachFunc, outbox OutboxFunc) (*models.Message, error) {
 	message := &models.Message{
 		ID:             uuid.New(),
 		ConversationID: conversationID,
 		SenderID:       senderID,
 		MessageText:    messageText,
-		ImageUrl:       imageURL, // Add this
+		MessageType:    messageType,
+		Payload:        payload,
+		Attachments:    []models.MessageAttachment{},
 		IsRead:         false,
 		CreatedAt:      time.Now(),
 	}
 
+	if attach != nil && len(attachments) > 0 {
+		if err := attach(ctx, tx, attachments); err != nil {
+			return nil, err
+		}
+	}
+
+	// clients that predate attachments still read image_url
+	for _, attachment := range attachments {
+		if attachment.ContentType == "image" {
+			message.ImageUrl = &attachment.URL
+			break
+		}
+	}
+
 	query := `
-        INSERT INTO messages (id, conversation_id, sender_id, message_text, image_url, is_read, created_at)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-        RETURNING id, conversation_id, sender_id, message_text, image_url, is_read, created_at
+        INSERT INTO messages (id, conversation_id, sender_id, message_text, image_url, message_type, payload, is_read, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
     `
 
-	err := r.db.QueryRow(query,
+	_, err := tx.ExecContext(ctx, query,
 		message.ID,
 		message.ConversationID,
 		message.SenderID,
 		message.MessageText,
-		message.ImageUrl, // Add this
+		message.ImageUrl,
+		message.MessageType,
+		nullJSON(message.Payload),
 		message.IsRead,
 		message.CreatedAt,
-	).Scan(
-		&message.ID,
-		&message.ConversationID,
-		&message.SenderID,
-		&message.MessageText,
-		&message.ImageUrl, // Add this
-		&message.IsRead,
-		&message.CreatedAt,
 	)
-
 	if err != nil {
 		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
 
+	for i, attachment := range attachments {
+		saved := models.MessageAttachment{
+			ID:           uuid.New(),
+			URL:          attachment.URL,
+			ContentType:  attachment.ContentType,
+			DisplayOrder: i,
+		}
+
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO message_attachments (id, message_id, url, content_type, display_order, created_at)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `, saved.ID, message.ID, saved.URL, saved.ContentType, saved.DisplayOrder, message.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save attachment: %w", err)
+		}
+
+		message.Attachments = append(message.Attachments, saved)
+	}
+
+	if err = enqueueFor(ctx, tx, outbox, message); err != nil {
+		return nil, err
+	}
+
 	return message, nil
 }
 
-// GetConversationMessages retrieves messages for a conversation with pagination
-func (r *MessageRepository) GetConversationMessages(conversationID uuid.UUID, limit, offset int) ([]models.MessageWithSender, error) {
-	messages := []models.MessageWithSender{}
+// nullJSON stores an empty payload as NULL rather than invalid JSON
+func nullJSON(payload json.RawMessage) any {
+	if len(payload) == 0 {
+		return nil
+	}
+	return string(payload)
+}
+
+// GetMessageAttachments returns the attachments of each message in display order
+func (r *MessageRepository) GetMessageAttachments(ctx context.Context, messageIDs []uuid.UUID) (map[uuid.UUID][]models.MessageAttachment, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetMessageAttachments")
+	defer span.End()
+
+	attachments := make(map[uuid.UUID][]models.MessageAttachment)
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
 
 	query := `
-        SELECT 
+        SELECT message_id, id, url, content_type, display_order
+        FROM message_attachments
+        WHERE message_id = ANY($1)
+        ORDER BY message_id, display_order
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(messageIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID uuid.UUID
+		var attachment models.MessageAttachment
+		if err := rows.Scan(&messageID, &attachment.ID, &attachment.URL, &attachment.ContentType, &attachment.DisplayOrder); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments[messageID] = append(attachments[messageID], attachment)
+	}
+
+	return attachments, rows.Err()
+}
+
+// withAttachments fills in the attachments of messages scanned by scanMessagesWithSender
+func (r *MessageRepository) withAttachments(ctx context.Context, messages []models.MessageWithSender) ([]models.MessageWithSender, error) {
+	messageIDs := make([]uuid.UUID, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+
+	attachments, err := r.GetMessageAttachments(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range messages {
+		messages[i].Attachments = attachments[messages[i].ID]
+		if messages[i].Attachments == nil {
+			messages[i].Attachments = []models.MessageAttachment{}
+		}
+	}
+
+	return messages, nil
+}
+
+// messageWithSenderColumns is the select list scanned by scanMessagesWithSender
+const messageWithSenderColumns = `
             m.id,
             m.conversation_id,
             m.sender_id,
             CONCAT(u.first_name, ' ', u.last_name) as sender_name,
             u.avatar_url as sender_avatar,
             m.message_text,
-			m.image_url,
+            m.image_url,
+            m.message_type,
+            m.payload,
             m.is_read,
             m.created_at,
-            m.deleted_at
+            m.edited_at,
+            m.deleted_at`
+
+// GetConversationMessages retrieves a page of messages for a conversation, newest first.
+// It fetches one row past the limit so the caller can tell whether there are more.
+func (r *MessageRepository) GetConversationMessages(ctx context.Context, conversationID uuid.UUID, page models.MessagePageQuery) ([]models.MessageWithSender, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetConversationMessages")
+	defer span.End()
+
+	query := `SELECT ` + messageWithSenderColumns + `
         FROM messages m
         INNER JOIN users u ON m.sender_id = u.user_id
-        WHERE m.conversation_id = $1 
-          AND m.deleted_at IS NULL
-        ORDER BY m.created_at DESC
-        LIMIT $2 OFFSET $3
-    `
+        WHERE m.conversation_id = $1
+          AND m.deleted_at IS NULL`
+
+	args := []any{conversationID}
 
-	rows, err := r.db.Query(query, conversationID, limit, offset)
+	switch {
+	case page.Before != nil:
+		query += ` AND (m.created_at, m.id) < ($2, $3) ORDER BY m.created_at DESC, m.id DESC LIMIT $4`
+		args = append(args, page.Before.CreatedAt, page.Before.ID, page.Limit+1)
+	case page.After != nil:
+		// walk forward from the cursor, flipped back to newest first below
+		query += ` AND (m.created_at, m.id) > ($2, $3) ORDER BY m.created_at ASC, m.id ASC LIMIT $4`
+		args = append(args, page.After.CreatedAt, page.After.ID, page.Limit+1)
+	default:
+		query += ` ORDER BY m.created_at DESC, m.id DESC LIMIT $2 OFFSET $3`
+		args = append(args, page.Limit+1, page.Offset)
+	}
+
+	messages, err := r.scanMessagesWithSender(r.db.QueryContext(ctx, query, args...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conversation messages: %w", err)
 	}
+
+	if page.After != nil {
+		slices.Reverse(messages)
+	}
+
+	return r.withAttachments(ctx, messages)
+}
+
+// Now returns the database clock, sync windows are measured with it rather than the app server's
+func (r *MessageRepository) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := r.db.QueryRowContext(ctx, `SELECT NOW()`).Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read database time: %w", err)
+	}
+	return now, nil
+}
+
+// GetMessagesChangedSince returns messages in the user's conversations created or edited after the after cursor
+// and no later than until, oldest change first with ties broken by id
+func (r *MessageRepository) GetMessagesChangedSince(ctx context.Context, userID uuid.UUID, after models.MessageCursor, until time.Time, limit int) ([]models.MessageWithSender, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetMessagesChangedSince")
+	defer span.End()
+
+	query := `SELECT ` + messageWithSenderColumns + `
+        FROM messages m
+        INNER JOIN users u ON m.sender_id = u.user_id
+        INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = $1
+        WHERE m.deleted_at IS NULL
+          AND (GREATEST(m.created_at, COALESCE(m.edited_at, m.created_at)), m.id) > ($2, $3)
+          AND GREATEST(m.created_at, COALESCE(m.edited_at, m.created_at)) <= $4
+        ORDER BY GREATEST(m.created_at, COALESCE(m.edited_at, m.created_at)) ASC, m.id ASC
+        LIMIT $5
+    `
+
+	messages, err := r.scanMessagesWithSender(r.db.QueryContext(ctx, query, userID, after.CreatedAt, after.ID, until, limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed messages: %w", err)
+	}
+
+	return r.withAttachments(ctx, messages)
+}
+
+// GetMessagesDeletedSince returns messages in the user's conversations deleted in (since, until]
+func (r *MessageRepository) GetMessagesDeletedSince(ctx context.Context, userID uuid.UUID, since, until time.Time) ([]models.DeletedMessage, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetMessagesDeletedSince")
+	defer span.End()
+
+	deleted := []models.DeletedMessage{}
+
+	query := `
+        SELECT m.id, m.conversation_id, m.deleted_at
+        FROM messages m
+        INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = $1
+        WHERE m.deleted_at > $2 AND m.deleted_at <= $3
+        ORDER BY m.deleted_at ASC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deleted messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg models.DeletedMessage
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.DeletedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted message: %w", err)
+		}
+		deleted = append(deleted, msg)
+	}
+
+	return deleted, rows.Err()
+}
+
+// GetReadStatesSince returns last_read_at changes in (since, until] for every participant of the user's conversations
+func (r *MessageRepository) GetReadStatesSince(ctx context.Context, userID uuid.UUID, since, until time.Time) ([]models.ReadState, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetReadStatesSince")
+	defer span.End()
+
+	states := []models.ReadState{}
+
+	query := `
+        SELECT other_cp.conversation_id, other_cp.user_id, other_cp.last_read_at
+        FROM conversation_participants cp
+        INNER JOIN conversation_participants other_cp ON other_cp.conversation_id = cp.conversation_id
+        WHERE cp.user_id = $1 AND other_cp.last_read_at > $2 AND other_cp.last_read_at <= $3
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read states: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var state models.ReadState
+		if err := rows.Scan(&state.ConversationID, &state.UserID, &state.LastReadAt); err != nil {
+			return nil, fmt.Errorf("failed to scan read state: %w", err)
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// GetReceiptsSince returns delivered/read receipts recorded in (since, until] in the user's conversations
+func (r *MessageRepository) GetReceiptsSince(ctx context.Context, userID uuid.UUID, since, until time.Time) ([]models.MessageReceipt, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetReceiptsSince")
+	defer span.End()
+
+	receipts := []models.MessageReceipt{}
+
+	query := `
+        SELECT mr.message_id, m.conversation_id, mr.user_id, mr.delivered_at, mr.read_at
+        FROM message_receipts mr
+        INNER JOIN messages m ON m.id = mr.message_id
+        INNER JOIN conversation_participants cp ON cp.conversation_id = m.conversation_id AND cp.user_id = $1
+        WHERE (mr.delivered_at > $2 AND mr.delivered_at <= $3) OR (mr.read_at > $2 AND mr.read_at <= $3)
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts: %w", err)
+	}
 	defer rows.Close()
 
+	for rows.Next() {
+		var receipt models.MessageReceipt
+		if err := rows.Scan(&receipt.MessageID, &receipt.ConversationID, &receipt.UserID, &receipt.DeliveredAt, &receipt.ReadAt); err != nil {
+			return nil, fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	return receipts, rows.Err()
+}
+
+// scanMessagesWithSender scans rows selected with messageWithSenderColumns
+func (r *MessageRepository) scanMessagesWithSender(rows *sql.Rows, err error) ([]models.MessageWithSender, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.MessageWithSender{}
+
 	for rows.Next() {
 		var msg models.MessageWithSender
 		err := rows.Scan(
@@ -165,8 +509,11 @@ func (r *MessageRepository) GetConversationMessages(conversationID uuid.UUID, li
 			&msg.SenderAvatar,
 			&msg.MessageText,
 			&msg.ImageUrl,
+			&msg.MessageType,
+			(*[]byte)(&msg.Payload),
 			&msg.IsRead,
 			&msg.CreatedAt,
+			&msg.EditedAt,
 			&msg.DeletedAt,
 		)
 		if err != nil {
@@ -183,7 +530,10 @@ func (r *MessageRepository) GetConversationMessages(conversationID uuid.UUID, li
 }
 
 // GetUserConversations retrieves all conversations for a user with details
-func (r *MessageRepository) GetUserConversations(userID uuid.UUID) ([]models.ConversationWithDetails, error) {
+func (r *MessageRepository) GetUserConversations(ctx context.Context, userID uuid.UUID) ([]models.ConversationWithDetails, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetUserConversations")
+	defer span.End()
+
 	conversations := []models.ConversationWithDetails{}
 
 	query := `
@@ -192,10 +542,16 @@ func (r *MessageRepository) GetUserConversations(userID uuid.UUID) ([]models.Con
             c.created_at,
             c.updated_at,
             c.last_message_at,
-            other_user.user_id as other_user_id,
-            CONCAT(other_user.first_name, ' ', other_user.last_name) as other_user_name,
-            other_user.avatar_url as other_user_avatar,
+            c.is_group,
+            c.title,
             last_msg.message_text as last_message_text,
+            last_msg.message_type as last_message_type,
+            last_msg.payload as last_message_payload,
+            last_msg.attachment_count as last_message_attachment_count,
+            p.product_id,
+            p.title as product_title,
+            p.status as product_status,
+            product_photo.image_url as product_image_url,
             COALESCE(
                 (SELECT COUNT(*) 
                  FROM messages m2 
@@ -207,62 +563,181 @@ func (r *MessageRepository) GetUserConversations(userID uuid.UUID) ([]models.Con
             ) as unread_count
         FROM conversations c
         INNER JOIN conversation_participants cp ON c.id = cp.conversation_id
-        INNER JOIN conversation_participants other_cp ON c.id = other_cp.conversation_id 
-            AND other_cp.user_id != $1
-        INNER JOIN users other_user ON other_cp.user_id = other_user.user_id
         LEFT JOIN LATERAL (
-            SELECT message_text 
+            SELECT message_text, message_type, payload,
+                (SELECT COUNT(*) FROM message_attachments a WHERE a.message_id = messages.id) as attachment_count
             FROM messages 
             WHERE conversation_id = c.id 
               AND deleted_at IS NULL
             ORDER BY created_at DESC 
             LIMIT 1
         ) last_msg ON true
+        LEFT JOIN products p ON p.product_id = c.related_product_id
+        LEFT JOIN LATERAL (
+            SELECT image_url
+            FROM product_photos
+            WHERE product_id = p.product_id
+            ORDER BY display_order
+            LIMIT 1
+        ) product_photo ON true
         WHERE cp.user_id = $1
         ORDER BY c.updated_at DESC
     `
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user conversations: %w", err)
 	}
 	defer rows.Close()
 
+	var conversationIDs []uuid.UUID
+
 	for rows.Next() {
 		var conv models.ConversationWithDetails
+		var productID *uuid.UUID
+		var productTitle, productStatus, productImage *string
+		var lastMessageType *string
+		var lastMessagePayload []byte
+		var lastMessageAttachments *int
 		err := rows.Scan(
 			&conv.ID,
 			&conv.CreatedAt,
 			&conv.UpdatedAt,
 			&conv.LastMessageAt,
-			&conv.OtherUserID,
-			&conv.OtherUserName,
-			&conv.OtherUserAvatar,
+			&conv.IsGroup,
+			&conv.Title,
 			&conv.LastMessageText,
+			&lastMessageType,
+			&lastMessagePayload,
+			&lastMessageAttachments,
+			&productID,
+			&productTitle,
+			&productStatus,
+			&productImage,
 			&conv.UnreadCount,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan conversation: %w", err)
 		}
+
+		if conv.LastMessageText != nil && lastMessageType != nil {
+			preview := models.MessagePreview(*lastMessageType, *conv.LastMessageText, lastMessagePayload, *lastMessageAttachments)
+			conv.LastMessageText = &preview
+		}
+
+		if productID != nil {
+			conv.Product = &models.ConversationProduct{
+				ProductID: *productID,
+				Title:     *productTitle,
+				Status:    *productStatus,
+				ImageUrl:  productImage,
+			}
+		}
+
 		conversations = append(conversations, conv)
+		conversationIDs = append(conversationIDs, conv.ID)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating conversations: %w", err)
 	}
 
+	participants, err := r.GetParticipants(ctx, conversationIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range conversations {
+		conv := &conversations[i]
+		conv.Participants = participants[conv.ID]
+
+		if conv.IsGroup {
+			continue
+		}
+
+		// one-to-one conversations keep the flat other_user fields
+		for _, participant := range conv.Participants {
+			if participant.UserID != userID {
+				conv.OtherUserID = participant.UserID
+				conv.OtherUserName = participant.Name
+				conv.OtherUserAvatar = participant.Avatar
+			}
+		}
+	}
+
 	return conversations, nil
 }
 
+// GetParticipants returns the members of each conversation with how many messages each has not read
+func (r *MessageRepository) GetParticipants(ctx context.Context, conversationIDs []uuid.UUID) (map[uuid.UUID][]models.ParticipantInfo, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetParticipants")
+	defer span.End()
+
+	participants := make(map[uuid.UUID][]models.ParticipantInfo)
+	if len(conversationIDs) == 0 {
+		return participants, nil
+	}
+
+	query := `
+        SELECT
+            cp.conversation_id,
+            u.user_id,
+            CONCAT(u.first_name, ' ', u.last_name) as name,
+            u.avatar_url,
+            cp.role,
+            cp.last_read_at,
+            (SELECT COUNT(*)
+             FROM messages m
+             WHERE m.conversation_id = cp.conversation_id
+               AND m.sender_id != cp.user_id
+               AND m.created_at > cp.last_read_at
+               AND m.deleted_at IS NULL
+            ) as unread_count
+        FROM conversation_participants cp
+        INNER JOIN users u ON u.user_id = cp.user_id
+        WHERE cp.conversation_id = ANY($1)
+        ORDER BY cp.joined_at ASC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(conversationIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get participants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var conversationID uuid.UUID
+		var participant models.ParticipantInfo
+		err := rows.Scan(
+			&conversationID,
+			&participant.UserID,
+			&participant.Name,
+			&participant.Avatar,
+			&participant.Role,
+			&participant.LastReadAt,
+			&participant.UnreadCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan participant: %w", err)
+		}
+		participants[conversationID] = append(participants[conversationID], participant)
+	}
+
+	return participants, rows.Err()
+}
+
 // MarkConversationAsRead updates the last_read_at timestamp for a user in a conversation
-func (r *MessageRepository) MarkConversationAsRead(conversationID, userID uuid.UUID) error {
+func (r *MessageRepository) MarkConversationAsRead(ctx context.Context, conversationID, userID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "MessageRepository.MarkConversationAsRead")
+	defer span.End()
+
 	query := `
         UPDATE conversation_participants
         SET last_read_at = NOW()
         WHERE conversation_id = $1 AND user_id = $2
     `
 
-	result, err := r.db.Exec(query, conversationID, userID)
+	result, err := r.db.ExecContext(ctx, query, conversationID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to mark conversation as read: %w", err)
 	}
@@ -273,32 +748,206 @@ func (r *MessageRepository) MarkConversationAsRead(conversationID, userID uuid.U
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("conversation participant not found")
+		return utils.NewNotFound("conversation participant not found")
 	}
 
 	return nil
 }
 
+// MarkMessagesRead records read receipts for userID on every message from others not yet read,
+// returns the newly read message ids
+func (r *MessageRepository) MarkMessagesRead(ctx context.Context, conversationID, userID uuid.UUID) ([]uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.MarkMessagesRead")
+	defer span.End()
+
+	query := `
+        INSERT INTO message_receipts (message_id, user_id, delivered_at, read_at)
+        SELECT m.id, $2, NOW(), NOW()
+        FROM messages m
+        WHERE m.conversation_id = $1
+          AND m.sender_id != $2
+          AND m.deleted_at IS NULL
+          AND NOT EXISTS (
+              SELECT 1 FROM message_receipts mr
+              WHERE mr.message_id = m.id AND mr.user_id = $2 AND mr.read_at IS NOT NULL
+          )
+        ON CONFLICT (message_id, user_id) DO UPDATE
+        SET read_at = EXCLUDED.read_at,
+            delivered_at = COALESCE(message_receipts.delivered_at, EXCLUDED.delivered_at)
+        RETURNING message_id
+    `
+
+	messageIDs, err := r.scanIDs(r.db.QueryContext(ctx, query, conversationID, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark messages as read: %w", err)
+	}
+
+	if len(messageIDs) > 0 {
+		// keep the legacy flag in step for clients still reading is_read
+		_, err = r.db.ExecContext(ctx, `UPDATE messages SET is_read = true WHERE id = ANY($1)`, pq.Array(messageIDs))
+		if err != nil {
+			return nil, fmt.Errorf("failed to update is_read: %w", err)
+		}
+	}
+
+	return messageIDs, nil
+}
+
+// MarkMessagesDelivered records delivered receipts for userID on the given messages from others,
+// returns the ids that had not been delivered before
+func (r *MessageRepository) MarkMessagesDelivered(ctx context.Context, conversationID, userID uuid.UUID, messageIDs []uuid.UUID) ([]uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.MarkMessagesDelivered")
+	defer span.End()
+
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        INSERT INTO message_receipts (message_id, user_id, delivered_at)
+        SELECT m.id, $2, NOW()
+        FROM messages m
+        WHERE m.conversation_id = $1
+          AND m.sender_id != $2
+          AND m.id = ANY($3)
+        ON CONFLICT (message_id, user_id) DO NOTHING
+        RETURNING message_id
+    `
+
+	delivered, err := r.scanIDs(r.db.QueryContext(ctx, query, conversationID, userID, pq.Array(messageIDs)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark messages as delivered: %w", err)
+	}
+
+	return delivered, nil
+}
+
+// scanIDs collects a single uuid column
+func (r *MessageRepository) scanIDs(rows *sql.Rows, err error) ([]uuid.UUID, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetUserConversationIDs returns the ids of every conversation the user is in
+func (r *MessageRepository) GetUserConversationIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetUserConversationIDs")
+	defer span.End()
+
+	ids, err := r.scanIDs(r.db.QueryContext(ctx, `SELECT conversation_id FROM conversation_participants WHERE user_id = $1`, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user conversations: %w", err)
+	}
+
+	return ids, nil
+}
+
+// GetProductSellerID returns who listed a product
+func (r *MessageRepository) GetProductSellerID(ctx context.Context, productID uuid.UUID) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetProductSellerID")
+	defer span.End()
+
+	var sellerID uuid.UUID
+	err := r.db.QueryRowContext(ctx, `SELECT seller_id FROM products WHERE product_id = $1`, productID).Scan(&sellerID)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, utils.NewNotFound("product not found")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	return sellerID, nil
+}
+
+// GetProductCard returns a listing's title, status and first photo along with its seller
+func (r *MessageRepository) GetProductCard(ctx context.Context, productID uuid.UUID) (*models.ProductCardPayload, uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetProductCard")
+	defer span.End()
+
+	card := &models.ProductCardPayload{ProductID: productID}
+	var sellerID uuid.UUID
+
+	query := `
+        SELECT p.seller_id, p.title, p.status,
+            (SELECT image_url FROM product_photos WHERE product_id = p.product_id ORDER BY display_order LIMIT 1)
+        FROM products p
+        WHERE p.product_id = $1
+    `
+
+	err := r.db.QueryRowContext(ctx, query, productID).Scan(&sellerID, &card.Title, &card.Status, &card.ImageUrl)
+	if err == sql.ErrNoRows {
+		return nil, uuid.Nil, utils.NewNotFound("product not found")
+	}
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	return card, sellerID, nil
+}
+
+// GetActiveProductSellers maps each listed, active product to its seller, missing products are left out
+func (r *MessageRepository) GetActiveProductSellers(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID]uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetActiveProductSellers")
+	defer span.End()
+
+	sellers := make(map[uuid.UUID]uuid.UUID)
+
+	rows, err := r.db.QueryContext(ctx, `SELECT product_id, seller_id FROM products WHERE product_id = ANY($1) AND status = 'active'`, pq.Array(productIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product sellers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var productID, sellerID uuid.UUID
+		if err := rows.Scan(&productID, &sellerID); err != nil {
+			return nil, fmt.Errorf("failed to scan product seller: %w", err)
+		}
+		sellers[productID] = sellerID
+	}
+
+	return sellers, rows.Err()
+}
+
 // GetConversationByID retrieves a conversation by ID
-func (r *MessageRepository) GetConversationByID(conversationID uuid.UUID) (*models.Conversation, error) {
+func (r *MessageRepository) GetConversationByID(ctx context.Context, conversationID uuid.UUID) (*models.Conversation, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetConversationByID")
+	defer span.End()
+
 	conversation := &models.Conversation{}
 
 	query := `
-        SELECT id, created_at, updated_at, last_message_at
+        SELECT id, created_at, updated_at, last_message_at, related_product_id, is_group, title, created_by
         FROM conversations
         WHERE id = $1
     `
 
-	err := r.db.QueryRow(query, conversationID).Scan(
+	err := r.db.QueryRowContext(ctx, query, conversationID).Scan(
 		&conversation.ID,
 		&conversation.CreatedAt,
 		&conversation.UpdatedAt,
 		&conversation.LastMessageAt,
+		&conversation.RelatedProductID,
+		&conversation.IsGroup,
+		&conversation.Title,
+		&conversation.CreatedBy,
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("conversation not found")
+			return nil, utils.NewNotFound("conversation not found")
 		}
 		return nil, fmt.Errorf("failed to get conversation: %w", err)
 	}
@@ -307,7 +956,10 @@ func (r *MessageRepository) GetConversationByID(conversationID uuid.UUID) (*mode
 }
 
 // VerifyUserInConversation checks if a user is a participant in a conversation
-func (r *MessageRepository) VerifyUserInConversation(conversationID, userID uuid.UUID) (bool, error) {
+func (r *MessageRepository) VerifyUserInConversation(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.VerifyUserInConversation")
+	defer span.End()
+
 	var exists bool
 
 	query := `
@@ -318,7 +970,7 @@ func (r *MessageRepository) VerifyUserInConversation(conversationID, userID uuid
         )
     `
 
-	err := r.db.QueryRow(query, conversationID, userID).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, query, conversationID, userID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to verify user in conversation: %w", err)
 	}
@@ -327,7 +979,10 @@ func (r *MessageRepository) VerifyUserInConversation(conversationID, userID uuid
 }
 
 // GetOtherParticipantID gets the other user's ID in a 1-on-1 conversation
-func (r *MessageRepository) GetOtherParticipantID(conversationID, currentUserID uuid.UUID) (uuid.UUID, error) {
+func (r *MessageRepository) GetOtherParticipantID(ctx context.Context, conversationID, currentUserID uuid.UUID) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetOtherParticipantID")
+	defer span.End()
+
 	var otherUserID uuid.UUID
 
 	query := `
@@ -337,10 +992,10 @@ func (r *MessageRepository) GetOtherParticipantID(conversationID, currentUserID
         LIMIT 1
     `
 
-	err := r.db.QueryRow(query, conversationID, currentUserID).Scan(&otherUserID)
+	err := r.db.QueryRowContext(ctx, query, conversationID, currentUserID).Scan(&otherUserID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return uuid.Nil, fmt.Errorf("other participant not found")
+			return uuid.Nil, utils.NewNotFound("other participant not found")
 		}
 		return uuid.Nil, fmt.Errorf("failed to get other participant: %w", err)
 	}
@@ -348,27 +1003,442 @@ func (r *MessageRepository) GetOtherParticipantID(conversationID, currentUserID
 	return otherUserID, nil
 }
 
-// DeleteMessage soft deletes a message (sets deleted_at)
-func (r *MessageRepository) DeleteMessage(messageID, userID uuid.UUID) error {
+// DeleteMessage soft deletes a message (sets deleted_at), returns its conversation
+func (r *MessageRepository) DeleteMessage(ctx context.Context, messageID, userID uuid.UUID, outbox OutboxFunc) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.DeleteMessage")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
         UPDATE messages
         SET deleted_at = NOW()
-        WHERE id = $1 AND sender_id = $2 AND deleted_at IS NULL
+        WHERE id = $1 AND sender_id = $2 AND deleted_at IS NULL AND message_type != 'system'
+        RETURNING conversation_id, deleted_at
     `
 
-	result, err := r.db.Exec(query, messageID, userID)
+	message := &models.Message{ID: messageID, SenderID: userID}
+	err = tx.QueryRowContext(ctx, query, messageID, userID).Scan(&message.ConversationID, &message.DeletedAt)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, utils.NewNotFound("message not found or already deleted")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to delete message: %w", err)
+		return uuid.Nil, fmt.Errorf("failed to delete message: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if err = enqueueFor(ctx, tx, outbox, message); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit delete: %w", err)
+	}
+
+	return message.ConversationID, nil
+}
+
+// GetMessageByID retrieves a message that has not been deleted
+func (r *MessageRepository) GetMessageByID(ctx context.Context, messageID uuid.UUID) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetMessageByID")
+	defer span.End()
+
+	message := &models.Message{}
+
+	query := `
+        SELECT id, conversation_id, sender_id, message_text, image_url, message_type, payload, is_read, created_at, edited_at
+        FROM messages
+        WHERE id = $1 AND deleted_at IS NULL
+    `
+
+	err := r.db.QueryRowContext(ctx, query, messageID).Scan(
+		&message.ID,
+		&message.ConversationID,
+		&message.SenderID,
+		&message.MessageText,
+		&message.ImageUrl,
+		&message.MessageType,
+		(*[]byte)(&message.Payload),
+		&message.IsRead,
+		&message.CreatedAt,
+		&message.EditedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("message not found")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("message not found or already deleted")
+	if err := r.loadAttachments(ctx, message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// loadAttachments fills in a single message's attachments
+func (r *MessageRepository) loadAttachments(ctx context.Context, message *models.Message) error {
+	attachments, err := r.GetMessageAttachments(ctx, []uuid.UUID{message.ID})
+	if err != nil {
+		return err
 	}
 
+	message.Attachments = attachments[message.ID]
+	if message.Attachments == nil {
+		message.Attachments = []models.MessageAttachment{}
+	}
 	return nil
 }
+
+// EditMessage replaces a message's text and keeps the old text in message_edits
+func (r *MessageRepository) EditMessage(ctx context.Context, messageID uuid.UUID, messageText string, outbox OutboxFunc) (*models.Message, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.EditMessage")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previousText string
+	err = tx.QueryRowContext(ctx, `SELECT message_text FROM messages WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, messageID).Scan(&previousText)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("message not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock message: %w", err)
+	}
+
+	editedAt := time.Now()
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO message_edits (id, message_id, previous_text, edited_at)
+        VALUES ($1, $2, $3, $4)
+    `, uuid.New(), messageID, previousText, editedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save edit history: %w", err)
+	}
+
+	message := &models.Message{}
+
+	query := `
+        UPDATE messages
+        SET message_text = $1, edited_at = $2
+        WHERE id = $3
+        RETURNING id, conversation_id, sender_id, message_text, image_url, message_type, payload, is_read, created_at, edited_at
+    `
+
+	err = tx.QueryRowContext(ctx, query, messageText, editedAt, messageID).Scan(
+		&message.ID,
+		&message.ConversationID,
+		&message.SenderID,
+		&message.MessageText,
+		&message.ImageUrl,
+		&message.MessageType,
+		(*[]byte)(&message.Payload),
+		&message.IsRead,
+		&message.CreatedAt,
+		&message.EditedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	if err = enqueueFor(ctx, tx, outbox, message); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit edit: %w", err)
+	}
+
+	if err := r.loadAttachments(ctx, message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// GetMessageEdits returns a message's previous versions, oldest first
+func (r *MessageRepository) GetMessageEdits(ctx context.Context, messageID uuid.UUID) ([]models.MessageEdit, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetMessageEdits")
+	defer span.End()
+
+	edits := []models.MessageEdit{}
+
+	query := `
+        SELECT id, message_id, previous_text, edited_at
+        FROM message_edits
+        WHERE message_id = $1
+        ORDER BY edited_at ASC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message edits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var edit models.MessageEdit
+		if err := rows.Scan(&edit.ID, &edit.MessageID, &edit.PreviousText, &edit.EditedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message edit: %w", err)
+		}
+		edits = append(edits, edit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating message edits: %w", err)
+	}
+
+	return edits, nil
+}
+
+// CreateGroupConversation creates a titled group with ownerID as owner and everyone else as members
+func (r *MessageRepository) CreateGroupConversation(ctx context.Context, ownerID uuid.UUID, title string, memberIDs []uuid.UUID, productID *uuid.UUID) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.CreateGroupConversation")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var conversationID uuid.UUID
+
+	query := `
+        INSERT INTO conversations (is_group, title, created_by, related_product_id)
+        VALUES (true, $1, $2, $3)
+        RETURNING id
+    `
+
+	err = tx.QueryRowContext(ctx, query, title, ownerID, productID).Scan(&conversationID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create group conversation: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO conversation_participants (id, conversation_id, user_id, role)
+        VALUES (gen_random_uuid(), $1, $2, $3)
+    `, conversationID, ownerID, models.ParticipantRoleOwner)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to add group owner: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+        INSERT INTO conversation_participants (id, conversation_id, user_id, role)
+        SELECT gen_random_uuid(), $1, u.user_id, $3
+        FROM users u
+        WHERE u.user_id = ANY($2) AND u.user_id != $4
+        ON CONFLICT (conversation_id, user_id) DO NOTHING
+    `, conversationID, pq.Array(memberIDs), models.ParticipantRoleMember, ownerID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to add group members: %w", err)
+	}
+
+	added, err := result.RowsAffected()
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if int(added) != len(memberIDs) {
+		return uuid.Nil, utils.NewBadRequest("every participant must be an existing user other than yourself, listed once")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit group conversation: %w", err)
+	}
+
+	return conversationID, nil
+}
+
+// GetParticipantRole returns the user's role in a conversation
+func (r *MessageRepository) GetParticipantRole(ctx context.Context, conversationID, userID uuid.UUID) (string, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetParticipantRole")
+	defer span.End()
+
+	var role string
+	err := r.db.QueryRowContext(ctx, `
+        SELECT role FROM conversation_participants
+        WHERE conversation_id = $1 AND user_id = $2
+    `, conversationID, userID).Scan(&role)
+
+	if err == sql.ErrNoRows {
+		return "", utils.NewNotFound("participant not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get participant role: %w", err)
+	}
+
+	return role, nil
+}
+
+// AddParticipants adds existing users as members, returns the ones who were not already in the conversation.
+// The conversation row is locked while adding so concurrent adds can't take it past maxParticipants.
+func (r *MessageRepository) AddParticipants(ctx context.Context, conversationID uuid.UUID, userIDs []uuid.UUID, maxParticipants int) ([]uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.AddParticipants")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	err = tx.QueryRowContext(ctx, `SELECT id FROM conversations WHERE id = $1 FOR UPDATE`, conversationID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("conversation not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock conversation: %w", err)
+	}
+
+	query := `
+        INSERT INTO conversation_participants (id, conversation_id, user_id, role)
+        SELECT gen_random_uuid(), $1, u.user_id, $3
+        FROM users u
+        WHERE u.user_id = ANY($2)
+        ON CONFLICT (conversation_id, user_id) DO NOTHING
+        RETURNING user_id
+    `
+
+	added, err := r.scanIDs(tx.QueryContext(ctx, query, conversationID, pq.Array(userIDs), models.ParticipantRoleMember))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add participants: %w", err)
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM conversation_participants WHERE conversation_id = $1`, conversationID).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count participants: %w", err)
+	}
+	if count > maxParticipants {
+		return nil, utils.NewBadRequest(fmt.Sprintf("groups are limited to %d participants", maxParticipants))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit participants: %w", err)
+	}
+
+	return added, nil
+}
+
+// RemoveParticipant takes targetID out of a conversation for actorID. authorize gets both roles, empty for
+// someone who isn't a participant, and can refuse the removal. When the owner is removed the longest standing
+// admin, or member if there are no admins, takes over. The conversation row stays locked until then so
+// concurrent membership changes see the roles as they end up. Returns the new owner, uuid.Nil if there is none.
+func (r *MessageRepository) RemoveParticipant(ctx context.Context, conversationID, actorID, targetID uuid.UUID, authorize func(actorRole, targetRole string) error) (uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.RemoveParticipant")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked uuid.UUID
+	err = tx.QueryRowContext(ctx, `SELECT id FROM conversations WHERE id = $1 FOR UPDATE`, conversationID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, utils.NewNotFound("conversation not found")
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to lock conversation: %w", err)
+	}
+
+	roles := map[uuid.UUID]string{}
+	rows, err := tx.QueryContext(ctx, `
+        SELECT user_id, role FROM conversation_participants
+        WHERE conversation_id = $1 AND user_id = ANY($2)
+    `, conversationID, pq.Array([]uuid.UUID{actorID, targetID}))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get participant roles: %w", err)
+	}
+	for rows.Next() {
+		var userID uuid.UUID
+		var role string
+		if err := rows.Scan(&userID, &role); err != nil {
+			rows.Close()
+			return uuid.Nil, fmt.Errorf("failed to scan participant role: %w", err)
+		}
+		roles[userID] = role
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get participant roles: %w", err)
+	}
+
+	if err := authorize(roles[actorID], roles[targetID]); err != nil {
+		return uuid.Nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM conversation_participants
+        WHERE conversation_id = $1 AND user_id = $2
+    `, conversationID, targetID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to remove participant: %w", err)
+	}
+
+	var ownerID uuid.UUID
+	if roles[targetID] == models.ParticipantRoleOwner {
+		err = tx.QueryRowContext(ctx, `
+            UPDATE conversation_participants
+            SET role = $2
+            WHERE id = (
+                SELECT id FROM conversation_participants
+                WHERE conversation_id = $1
+                ORDER BY (role = $3) DESC, joined_at ASC
+                LIMIT 1
+            )
+            RETURNING user_id
+        `, conversationID, models.ParticipantRoleOwner, models.ParticipantRoleAdmin).Scan(&ownerID)
+		if err != nil && err != sql.ErrNoRows {
+			return uuid.Nil, fmt.Errorf("failed to promote new owner: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit participant removal: %w", err)
+	}
+
+	return ownerID, nil
+}
+
+// GetUserNames returns display names for the given users
+func (r *MessageRepository) GetUserNames(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	ctx, span := tracer.Start(ctx, "MessageRepository.GetUserNames")
+	defer span.End()
+
+	query := `
+        SELECT user_id, COALESCE(NULLIF(TRIM(CONCAT(first_name, ' ', last_name)), ''), username)
+        FROM users
+        WHERE user_id = ANY($1)
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user names: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var id uuid.UUID
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan user name: %w", err)
+		}
+		names[id] = name
+	}
+
+	return names, rows.Err()
+}
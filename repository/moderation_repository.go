@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"postswapapi/models"
+	"postswapapi/utils"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type ModerationRepository struct {
+	db *sql.DB
+}
+
+func NewModerationRepository(db *sql.DB) *ModerationRepository {
+	return &ModerationRepository{db: db}
+}
+
+// hashDistance is the number of differing bits between two perceptual hashes
+func hashDistance(a, b string) string {
+	return `bit_count((` + a + ` # ` + b + `)::bit(64))`
+}
+
+// hashBands split the 64 bits of a perceptual hash into bands indexed by migration 020. Two hashes that differ
+// in fewer bits than there are bands agree on at least one band, so only hashes sharing a band are compared.
+var hashBands = []struct{ shift, mask int }{
+	{0, 511}, {9, 511}, {18, 511}, {27, 511}, {36, 511}, {45, 511}, {54, 1023},
+}
+
+// hashBand is band i of a hash column, written the way the band indexes are
+func hashBand(column string, i int) string {
+	return fmt.Sprintf("((%s >> %d) & %d)", column, hashBands[i].shift, hashBands[i].mask)
+}
+
+// hashCandidate is true when two hashes share a band, the condition the band indexes can answer
+func hashCandidate(a, b string) string {
+	bands := make([]string, len(hashBands))
+	for i := range hashBands {
+		bands[i] = hashBand(a, i) + " = " + hashBand(b, i)
+	}
+	return "(" + strings.Join(bands, " OR ") + ")"
+}
+
+// checkHashDistance rejects distances the band lookup can miss matches at
+func checkHashDistance(maxDistance int) error {
+	if maxDistance < 0 || maxDistance >= len(hashBands) {
+		return fmt.Errorf("hash bands only find hashes within %d bits, not %d", len(hashBands)-1, maxDistance)
+	}
+	return nil
+}
+
+// FlagProductPhotos puts photos of the product in the moderation queue when they are within maxDistance of a
+// photo of another seller's listing or of a blocked image, each photo is flagged with its closest match.
+// Candidates are found through the hash band indexes, so maxDistance has to be below the number of bands.
+// Returns how many flags were added.
+func (r *ModerationRepository) FlagProductPhotos(ctx context.Context, productID uuid.UUID, maxDistance int) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.FlagProductPhotos")
+	defer span.End()
+
+	if err := checkHashDistance(maxDistance); err != nil {
+		return 0, err
+	}
+
+	query := `
+        WITH photos AS (
+            SELECT pp.photo_id, pp.phash, p.seller_id
+            FROM product_photos pp
+            JOIN products p ON p.product_id = pp.product_id
+            WHERE pp.product_id = $1 AND pp.phash IS NOT NULL
+        ),
+        duplicates AS (
+            SELECT DISTINCT ON (photos.photo_id)
+                photos.photo_id, other.photo_id AS matched_photo_id, NULL::uuid AS matched_hash_id,
+                ` + hashDistance("photos.phash", "other.phash") + ` AS distance, '` + models.FlagReasonDuplicatePhoto + `' AS reason
+            FROM photos
+            JOIN product_photos other ON other.phash IS NOT NULL AND ` + hashCandidate("other.phash", "photos.phash") + `
+                AND other.product_id <> $1
+            JOIN products op ON op.product_id = other.product_id AND op.seller_id <> photos.seller_id
+            WHERE ` + hashDistance("photos.phash", "other.phash") + ` <= $2
+            ORDER BY photos.photo_id, distance, other.created_at
+        ),
+        blocked AS (
+            SELECT DISTINCT ON (photos.photo_id)
+                photos.photo_id, NULL::uuid AS matched_photo_id, b.hash_id AS matched_hash_id,
+                ` + hashDistance("photos.phash", "b.phash") + ` AS distance, '` + models.FlagReasonBlockedPhoto + `' AS reason
+            FROM photos
+            JOIN blocked_image_hashes b ON b.phash IS NOT NULL AND ` + hashCandidate("b.phash", "photos.phash") + `
+                AND ` + hashDistance("photos.phash", "b.phash") + ` <= $2
+            ORDER BY photos.photo_id, distance
+        )
+        INSERT INTO moderation_flags (flag_id, product_id, photo_id, reason, matched_photo_id, matched_hash_id, distance)
+        SELECT gen_random_uuid(), $1, photo_id, reason, matched_photo_id, matched_hash_id, distance
+        FROM (SELECT * FROM duplicates UNION ALL SELECT * FROM blocked) matches
+        ON CONFLICT (photo_id, reason) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, productID, maxDistance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to flag product photos: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// FlagBlockedPhotos flags photos of active listings within maxDistance of a blocked image
+func (r *ModerationRepository) FlagBlockedPhotos(ctx context.Context, hashID uuid.UUID, maxDistance int) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.FlagBlockedPhotos")
+	defer span.End()
+
+	if err := checkHashDistance(maxDistance); err != nil {
+		return 0, err
+	}
+
+	query := `
+        INSERT INTO moderation_flags (flag_id, product_id, photo_id, reason, matched_hash_id, distance)
+        SELECT gen_random_uuid(), pp.product_id, pp.photo_id, '` + models.FlagReasonBlockedPhoto + `', b.hash_id,
+            ` + hashDistance("pp.phash", "b.phash") + `
+        FROM blocked_image_hashes b
+        JOIN product_photos pp ON pp.phash IS NOT NULL AND ` + hashCandidate("pp.phash", "b.phash") + `
+            AND ` + hashDistance("pp.phash", "b.phash") + ` <= $2
+        JOIN products p ON p.product_id = pp.product_id AND p.status = 'active'
+        WHERE b.hash_id = $1
+        ON CONFLICT (photo_id, reason) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, hashID, maxDistance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to flag blocked photos: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// ListFlags returns a page of flags with the given status, oldest first so the queue is worked in order
+func (r *ModerationRepository) ListFlags(ctx context.Context, status string, limit, offset int) ([]models.ModerationFlag, error) {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.ListFlags")
+	defer span.End()
+
+	query := `
+        SELECT f.flag_id, f.product_id, p.title, p.status, p.seller_id, f.photo_id, pp.image_url, f.reason,
+            f.matched_photo_id, mp.image_url, mp.product_id, mprod.seller_id, f.matched_hash_id,
+            f.distance, f.status, f.resolved_by, f.resolved_at, f.created_at
+        FROM moderation_flags f
+        JOIN products p ON p.product_id = f.product_id
+        JOIN product_photos pp ON pp.photo_id = f.photo_id
+        LEFT JOIN product_photos mp ON mp.photo_id = f.matched_photo_id
+        LEFT JOIN products mprod ON mprod.product_id = mp.product_id
+        WHERE f.status = $1
+        ORDER BY f.created_at, f.flag_id
+        LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list moderation flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := []models.ModerationFlag{}
+	for rows.Next() {
+		var flag models.ModerationFlag
+		err := rows.Scan(
+			&flag.FlagID,
+			&flag.ProductID,
+			&flag.ProductTitle,
+			&flag.ProductStatus,
+			&flag.SellerID,
+			&flag.PhotoID,
+			&flag.PhotoURL,
+			&flag.Reason,
+			&flag.MatchedPhotoID,
+			&flag.MatchedPhotoURL,
+			&flag.MatchedProductID,
+			&flag.MatchedSellerID,
+			&flag.MatchedHashID,
+			&flag.Distance,
+			&flag.Status,
+			&flag.ResolvedBy,
+			&flag.ResolvedAt,
+			&flag.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan moderation flag: %w", err)
+		}
+		flags = append(flags, flag)
+	}
+
+	return flags, rows.Err()
+}
+
+// ResolveFlag closes a flag. Removing the listing deactivates the product and closes all of its flags.
+func (r *ModerationRepository) ResolveFlag(ctx context.Context, flagID, moderatorID uuid.UUID, removeListing bool) error {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.ResolveFlag")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var productID uuid.UUID
+	err = tx.QueryRowContext(ctx, `SELECT product_id FROM moderation_flags WHERE flag_id = $1 FOR UPDATE`, flagID).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return utils.NewNotFound("flag not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get moderation flag: %w", err)
+	}
+
+	if !removeListing {
+		_, err = tx.ExecContext(ctx, `
+            UPDATE moderation_flags SET status = $2, resolved_by = $3, resolved_at = NOW()
+            WHERE flag_id = $1`, flagID, models.FlagStatusDismissed, moderatorID)
+		if err != nil {
+			return fmt.Errorf("failed to dismiss moderation flag: %w", err)
+		}
+		return tx.Commit()
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE products SET status = 'inactive', updated_at = NOW() WHERE product_id = $1`, productID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate product: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE moderation_flags SET status = $3, resolved_by = $4, resolved_at = NOW()
+        WHERE flag_id = $1 OR (product_id = $2 AND status = $5)`,
+		flagID, productID, models.FlagStatusActioned, moderatorID, models.FlagStatusOpen)
+	if err != nil {
+		return fmt.Errorf("failed to resolve moderation flags: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetPhotoHash returns the perceptual hash of a listing photo, nil when it has none
+func (r *ModerationRepository) GetPhotoHash(ctx context.Context, photoID uuid.UUID) (*int64, error) {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.GetPhotoHash")
+	defer span.End()
+
+	var hash *int64
+	err := r.db.QueryRowContext(ctx, `SELECT phash FROM product_photos WHERE photo_id = $1`, photoID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("photo not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photo hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// GetMediaHash returns the perceptual hash of an upload, nil when it has none
+func (r *ModerationRepository) GetMediaHash(ctx context.Context, mediaID uuid.UUID) (*int64, error) {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.GetMediaHash")
+	defer span.End()
+
+	var hash *int64
+	err := r.db.QueryRowContext(ctx, `SELECT phash FROM media WHERE media_id = $1`, mediaID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("media not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// BlockHash adds a hash to the blocklist, blocking it again updates the reason
+func (r *ModerationRepository) BlockHash(ctx context.Context, hash int64, reason string, moderatorID uuid.UUID) (*models.BlockedImageHash, error) {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.BlockHash")
+	defer span.End()
+
+	query := `
+        INSERT INTO blocked_image_hashes (hash_id, phash, reason, created_by)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (phash) DO UPDATE SET reason = CASE WHEN EXCLUDED.reason <> '' THEN EXCLUDED.reason ELSE blocked_image_hashes.reason END
+        RETURNING hash_id, phash, reason, created_by, created_at`
+
+	blocked, err := scanBlockedHash(r.db.QueryRowContext(ctx, query, uuid.New(), hash, reason, moderatorID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to block image: %w", err)
+	}
+
+	return blocked, nil
+}
+
+// ListBlockedHashes returns the blocklist, newest first
+func (r *ModerationRepository) ListBlockedHashes(ctx context.Context) ([]models.BlockedImageHash, error) {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.ListBlockedHashes")
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT hash_id, phash, reason, created_by, created_at
+        FROM blocked_image_hashes
+        ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blocked images: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := []models.BlockedImageHash{}
+	for rows.Next() {
+		blocked, err := scanBlockedHash(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan blocked image: %w", err)
+		}
+		hashes = append(hashes, *blocked)
+	}
+
+	return hashes, rows.Err()
+}
+
+// DeleteBlockedHash removes a hash from the blocklist, flags it raised stay in the queue
+func (r *ModerationRepository) DeleteBlockedHash(ctx context.Context, hashID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "ModerationRepository.DeleteBlockedHash")
+	defer span.End()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM blocked_image_hashes WHERE hash_id = $1`, hashID)
+	if err != nil {
+		return fmt.Errorf("failed to unblock image: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to unblock image: %w", err)
+	}
+	if deleted == 0 {
+		return utils.NewNotFound("blocked image not found")
+	}
+
+	return nil
+}
+
+func scanBlockedHash(row interface{ Scan(...any) error }) (*models.BlockedImageHash, error) {
+	var blocked models.BlockedImageHash
+	var hash int64
+	if err := row.Scan(&blocked.HashID, &hash, &blocked.Reason, &blocked.CreatedBy, &blocked.CreatedAt); err != nil {
+		return nil, err
+	}
+	blocked.Hash = fmt.Sprintf("%016x", uint64(hash))
+	return &blocked, nil
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"postswapapi/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// inQuietHours is true while the user owning userColumn is inside their quiet hours, in their own timezone.
+// Windows where start is after end wrap past midnight.
+func inQuietHours(userColumn string) string {
+	return fmt.Sprintf(`EXISTS (
+            SELECT 1 FROM notification_settings qs
+            WHERE qs.user_id = %s AND qs.quiet_hours_start IS NOT NULL AND qs.quiet_hours_end IS NOT NULL
+            AND CASE WHEN qs.quiet_hours_start <= qs.quiet_hours_end
+                THEN (NOW() AT TIME ZONE qs.timezone)::time >= qs.quiet_hours_start AND (NOW() AT TIME ZONE qs.timezone)::time < qs.quiet_hours_end
+                ELSE (NOW() AT TIME ZONE qs.timezone)::time >= qs.quiet_hours_start OR (NOW() AT TIME ZONE qs.timezone)::time < qs.quiet_hours_end
+            END
+        )`, userColumn)
+}
+
+// channelEnabled is true unless the user owning userColumn turned the channel off for the preference type in typeParam
+func channelEnabled(userColumn, channel, typeParam string) string {
+	return fmt.Sprintf(`COALESCE((
+            SELECT np.%s FROM notification_preferences np
+            WHERE np.user_id = %s AND np.notification_type = %s
+        ), true)`, channel, userColumn, typeParam)
+}
+
+type NotificationPreferenceRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationPreferenceRepository(db *sql.DB) *NotificationPreferenceRepository {
+	return &NotificationPreferenceRepository{db: db}
+}
+
+// GetSettings returns the user's notification settings with a preference for every type, defaults filling the gaps
+func (r *NotificationPreferenceRepository) GetSettings(ctx context.Context, userID uuid.UUID) (*models.NotificationSettings, error) {
+	ctx, span := tracer.Start(ctx, "NotificationPreferenceRepository.GetSettings")
+	defer span.End()
+
+	settings := &models.NotificationSettings{
+		Timezone:        models.DefaultTimezone,
+		DigestFrequency: models.DigestWeekly,
+	}
+
+	err := r.db.QueryRowContext(ctx, `
+        SELECT timezone, to_char(quiet_hours_start, 'HH24:MI'), to_char(quiet_hours_end, 'HH24:MI'), digest_frequency
+        FROM notification_settings
+        WHERE user_id = $1
+    `, userID).Scan(&settings.Timezone, &settings.QuietHoursStart, &settings.QuietHoursEnd, &settings.DigestFrequency)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get notification settings: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT notification_type, in_app, push, email
+        FROM notification_preferences
+        WHERE user_id = $1
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+	defer rows.Close()
+
+	saved := make(map[string]models.ChannelPreference)
+	for rows.Next() {
+		var pref models.ChannelPreference
+		if err := rows.Scan(&pref.NotificationType, &pref.InApp, &pref.Push, &pref.Email); err != nil {
+			return nil, fmt.Errorf("failed to scan notification preference: %w", err)
+		}
+		saved[pref.NotificationType] = pref
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	for _, notificationType := range models.PreferenceTypes {
+		pref, ok := saved[notificationType]
+		if !ok {
+			pref = models.DefaultChannelPreference(notificationType)
+		}
+		settings.Preferences = append(settings.Preferences, pref)
+	}
+
+	return settings, nil
+}
+
+// SaveSettings stores the user's settings and the given preferences together
+func (r *NotificationPreferenceRepository) SaveSettings(ctx context.Context, userID uuid.UUID, settings *models.NotificationSettings, changed []models.ChannelPreference) error {
+	ctx, span := tracer.Start(ctx, "NotificationPreferenceRepository.SaveSettings")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO notification_settings (user_id, timezone, quiet_hours_start, quiet_hours_end, digest_frequency)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id) DO UPDATE
+        SET timezone = EXCLUDED.timezone, quiet_hours_start = EXCLUDED.quiet_hours_start,
+            quiet_hours_end = EXCLUDED.quiet_hours_end, digest_frequency = EXCLUDED.digest_frequency, updated_at = NOW()
+    `, userID, settings.Timezone, settings.QuietHoursStart, settings.QuietHoursEnd, settings.DigestFrequency)
+	if err != nil {
+		return fmt.Errorf("failed to save notification settings: %w", err)
+	}
+
+	for _, pref := range changed {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO notification_preferences (user_id, notification_type, in_app, push, email)
+            VALUES ($1, $2, $3, $4, $5)
+            ON CONFLICT (user_id, notification_type) DO UPDATE
+            SET in_app = EXCLUDED.in_app, push = EXCLUDED.push, email = EXCLUDED.email, updated_at = NOW()
+        `, userID, pref.NotificationType, pref.InApp, pref.Push, pref.Email)
+		if err != nil {
+			return fmt.Errorf("failed to save notification preference: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notification settings: %w", err)
+	}
+
+	return nil
+}
+
+// GetChannelPreference returns where the user wants notifications of one preference type delivered
+func (r *NotificationPreferenceRepository) GetChannelPreference(ctx context.Context, userID uuid.UUID, notificationType string) (models.ChannelPreference, error) {
+	ctx, span := tracer.Start(ctx, "NotificationPreferenceRepository.GetChannelPreference")
+	defer span.End()
+
+	pref := models.DefaultChannelPreference(notificationType)
+
+	err := r.db.QueryRowContext(ctx, `
+        SELECT in_app, push, email
+        FROM notification_preferences
+        WHERE user_id = $1 AND notification_type = $2
+    `, userID, notificationType).Scan(&pref.InApp, &pref.Push, &pref.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return pref, fmt.Errorf("failed to get notification preference: %w", err)
+	}
+
+	return pref, nil
+}
+
+// ClaimDueDigests claims up to limit users whose digest is due: 08:00 in their timezone, every day or on Mondays.
+// Claiming stamps last_digest_at, the re-checked condition keeps two instances from claiming the same user.
+func (r *NotificationPreferenceRepository) ClaimDueDigests(ctx context.Context, limit int) ([]models.DigestRecipient, error) {
+	ctx, span := tracer.Start(ctx, "NotificationPreferenceRepository.ClaimDueDigests")
+	defer span.End()
+
+	query := `
+        WITH due AS (
+            SELECT u.user_id
+            FROM users u
+            LEFT JOIN notification_settings s ON s.user_id = u.user_id
+            WHERE COALESCE(s.digest_frequency, 'weekly') != 'off'
+            AND u.email IS NOT NULL AND u.email != ''
+            AND EXTRACT(HOUR FROM NOW() AT TIME ZONE COALESCE(s.timezone, 'UTC')) = 8
+            AND (COALESCE(s.digest_frequency, 'weekly') = 'daily' OR EXTRACT(ISODOW FROM NOW() AT TIME ZONE COALESCE(s.timezone, 'UTC')) = 1)
+            AND (s.last_digest_at IS NULL OR s.last_digest_at < NOW() - INTERVAL '20 hours')
+            LIMIT $1
+        ), claimed AS (
+            INSERT INTO notification_settings (user_id, last_digest_at)
+            SELECT user_id, NOW() FROM due
+            ON CONFLICT (user_id) DO UPDATE
+            SET last_digest_at = EXCLUDED.last_digest_at
+            WHERE notification_settings.last_digest_at IS NULL OR notification_settings.last_digest_at < NOW() - INTERVAL '20 hours'
+            RETURNING user_id, digest_frequency
+        )
+        SELECT c.user_id, u.email, u.username, c.digest_frequency, u.preferred_language
+        FROM claimed c
+        INNER JOIN users u ON u.user_id = c.user_id
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim digests: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	recipients := []models.DigestRecipient{}
+	for rows.Next() {
+		var recipient models.DigestRecipient
+		if err := rows.Scan(&recipient.UserID, &recipient.Email, &recipient.Username, &recipient.Frequency, &recipient.Language); err != nil {
+			return nil, fmt.Errorf("failed to scan digest recipient: %w", err)
+		}
+
+		recipient.Since = now.AddDate(0, 0, -7)
+		if recipient.Frequency == models.DigestDaily {
+			recipient.Since = now.AddDate(0, 0, -1)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
+// GetUnreadSince returns the user's unread notifications created after since, newest first
+func (r *NotificationPreferenceRepository) GetUnreadSince(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.Notifications, error) {
+	ctx, span := tracer.Start(ctx, "NotificationPreferenceRepository.GetUnreadSince")
+	defer span.End()
+
+	query := `
+        SELECT notification_id, notification_type, title, message, params, related_product_id, related_user_id, created_at
+        FROM notifications
+        WHERE user_id = $1 AND is_read = false AND created_at > $2
+        ORDER BY created_at DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unread notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notifications{}
+	for rows.Next() {
+		n := models.Notifications{User_ID: userID}
+		err := rows.Scan(&n.Notification_ID, &n.Notification_type, &n.Title, &n.Message, &n.Params,
+			&n.Related_product_ID, &n.Related_user_ID, &n.Created_at)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// ArchiveReadBefore moves up to limit read notifications created before before into notifications_archive
+func (r *NotificationRepository) ArchiveReadBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	ctx, span := tracer.Start(ctx, "NotificationRepository.ArchiveReadBefore")
+	defer span.End()
+
+	query := `
+        WITH archived AS (
+            DELETE FROM notifications
+            WHERE notification_id IN (
+                SELECT notification_id FROM notifications
+                WHERE is_read = true AND created_at < $1
+                LIMIT $2
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING notification_id, user_id, notification_type, title, message, params, related_conversation_id,
+                related_product_id, related_user_id, created_at, read_at
+        )
+        INSERT INTO notifications_archive (notification_id, user_id, notification_type, title, message, params, related_conversation_id,
+            related_product_id, related_user_id, created_at, read_at)
+        SELECT * FROM archived
+        ON CONFLICT (notification_id) DO NOTHING
+    `
+
+	result, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive notifications: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"postswapapi/models"
+	"slices"
+	"time"
+)
+
+// DBTX is satisfied by *sql.DB and *sql.Tx, so outbox entries can be written inside the caller's transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// EnqueueOutbox writes entries to the outbox, pass the transaction that makes the change they announce
+func EnqueueOutbox(ctx context.Context, db DBTX, entries ...models.OutboxEntry) error {
+	ctx, span := tracer.Start(ctx, "EnqueueOutbox")
+	defer span.End()
+
+	query := `
+        INSERT INTO outbox (idempotency_key, destination, channel, user_id, event_type, payload)
+        VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)
+    `
+
+	for _, entry := range entries {
+		_, err := db.ExecContext(ctx, query, entry.IdempotencyKey, entry.Destination, entry.Channel, entry.UserID, entry.EventType, string(entry.Payload))
+		if err != nil {
+			return fmt.Errorf("failed to enqueue %s: %w", entry.EventType, err)
+		}
+	}
+
+	return nil
+}
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// ClaimDue takes up to limit pending entries that are due, oldest first, and counts the attempt.
+// Claimed entries are pushed back by lease so other instances skip them while this one delivers.
+func (r *OutboxRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.ClaimDue")
+	defer span.End()
+
+	query := `
+        UPDATE outbox
+        SET attempts = attempts + 1,
+            next_attempt_at = NOW() + ($2 * INTERVAL '1 second')
+        WHERE id IN (
+            SELECT id FROM outbox
+            WHERE status = 'pending' AND next_attempt_at <= NOW()
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, idempotency_key, destination, COALESCE(channel, ''), user_id, event_type, payload, status, attempts, created_at
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.OutboxEntry{}
+	for rows.Next() {
+		var entry models.OutboxEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.IdempotencyKey,
+			&entry.Destination,
+			&entry.Channel,
+			&entry.UserID,
+			&entry.EventType,
+			&entry.Payload,
+			&entry.Status,
+			&entry.Attempts,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox entries: %w", err)
+	}
+
+	// RETURNING has no order, deliver in the order the entries were written
+	slices.SortFunc(entries, func(a, b models.OutboxEntry) int { return cmp.Compare(a.ID, b.ID) })
+
+	return entries, nil
+}
+
+// MarkDispatched records a successful delivery
+func (r *OutboxRepository) MarkDispatched(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.MarkDispatched")
+	defer span.End()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox SET status = 'dispatched', dispatched_at = NOW(), last_error = NULL WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry dispatched: %w", err)
+	}
+	return nil
+}
+
+// MarkRetry schedules another attempt after a failed delivery
+func (r *OutboxRepository) MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.MarkRetry")
+	defer span.End()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox SET next_attempt_at = $2, last_error = $3 WHERE id = $1`, id, nextAttemptAt, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox entry: %w", err)
+	}
+	return nil
+}
+
+// MarkDead dead-letters an entry that ran out of attempts
+func (r *OutboxRepository) MarkDead(ctx context.Context, id int64, lastError string) error {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.MarkDead")
+	defer span.End()
+
+	_, err := r.db.ExecContext(ctx, `UPDATE outbox SET status = 'dead', last_error = $2 WHERE id = $1`, id, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter outbox entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteDispatchedBefore prunes delivered entries, dead entries are left for inspection
+func (r *OutboxRepository) DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.DeleteDispatchedBefore")
+	defer span.End()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM outbox WHERE status = 'dispatched' AND dispatched_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox: %w", err)
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PresenceRepository struct {
+	db *sql.DB
+}
+
+func NewPresenceRepository(db *sql.DB) *PresenceRepository {
+	return &PresenceRepository{db: db}
+}
+
+// TouchOnline marks the user online and bumps last_seen, reports whether they were offline before
+func (r *PresenceRepository) TouchOnline(ctx context.Context, userID uuid.UUID) (bool, error) {
+	ctx, span := tracer.Start(ctx, "PresenceRepository.TouchOnline")
+	defer span.End()
+
+	// the FROM subquery reads the row as it was before this update
+	query := `
+        UPDATE users u
+        SET is_online = true, last_seen = NOW()
+        FROM (SELECT is_online FROM users WHERE user_id = $1) prev
+        WHERE u.user_id = $1
+        RETURNING prev.is_online
+    `
+
+	var wasOnline bool
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&wasOnline)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark user online: %w", err)
+	}
+
+	return !wasOnline, nil
+}
+
+// ExpireOnline marks users offline whose last_seen is older than before, returns who went offline
+func (r *PresenceRepository) ExpireOnline(ctx context.Context, before time.Time) (map[uuid.UUID]time.Time, error) {
+	ctx, span := tracer.Start(ctx, "PresenceRepository.ExpireOnline")
+	defer span.End()
+
+	query := `
+        UPDATE users
+        SET is_online = false
+        WHERE is_online = true AND (last_seen IS NULL OR last_seen < $1)
+        RETURNING user_id, COALESCE(last_seen, NOW())
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire online users: %w", err)
+	}
+	defer rows.Close()
+
+	expired := make(map[uuid.UUID]time.Time)
+	for rows.Next() {
+		var userID uuid.UUID
+		var lastSeen time.Time
+		if err := rows.Scan(&userID, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan expired user: %w", err)
+		}
+		expired[userID] = lastSeen
+	}
+
+	return expired, rows.Err()
+}
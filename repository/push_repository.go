@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"postswapapi/models"
+	"postswapapi/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type PushRepository struct {
+	db *sql.DB
+}
+
+func NewPushRepository(db *sql.DB) *PushRepository {
+	return &PushRepository{db: db}
+}
+
+// RegisterDevice saves a push token for the user, moving it over if another account had it,
+// and drops the user's least recently seen devices past MaxDevicesPerUser
+func (r *PushRepository) RegisterDevice(ctx context.Context, userID uuid.UUID, token, platform string) (*models.Device, error) {
+	ctx, span := tracer.Start(ctx, "PushRepository.RegisterDevice")
+	defer span.End()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	device := &models.Device{}
+
+	query := `
+        INSERT INTO user_devices (device_id, user_id, token, platform)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (token) DO UPDATE
+        SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, last_seen_at = NOW()
+        RETURNING device_id, user_id, token, platform, created_at, last_seen_at
+    `
+
+	err = tx.QueryRowContext(ctx, query, uuid.New(), userID, token, platform).Scan(
+		&device.DeviceID,
+		&device.UserID,
+		&device.Token,
+		&device.Platform,
+		&device.CreatedAt,
+		&device.LastSeenAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM user_devices
+        WHERE user_id = $1 AND device_id NOT IN (
+            SELECT device_id FROM user_devices WHERE user_id = $1 ORDER BY last_seen_at DESC LIMIT $2
+        )
+    `, userID, models.MaxDevicesPerUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prune devices: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit device: %w", err)
+	}
+
+	return device, nil
+}
+
+// UnregisterDevice removes one of the user's push tokens
+func (r *PushRepository) UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error {
+	ctx, span := tracer.Start(ctx, "PushRepository.UnregisterDevice")
+	defer span.End()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_devices WHERE user_id = $1 AND token = $2`, userID, token)
+	if err != nil {
+		return fmt.Errorf("failed to unregister device: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to unregister device: %w", err)
+	}
+	if removed == 0 {
+		return utils.NewNotFound("device not found")
+	}
+
+	return nil
+}
+
+// GetDevices returns the registered devices of each user
+func (r *PushRepository) GetDevices(ctx context.Context, userIDs []uuid.UUID) (map[uuid.UUID][]models.Device, error) {
+	ctx, span := tracer.Start(ctx, "PushRepository.GetDevices")
+	defer span.End()
+
+	devices := make(map[uuid.UUID][]models.Device)
+	if len(userIDs) == 0 {
+		return devices, nil
+	}
+
+	query := `
+        SELECT device_id, user_id, token, platform, created_at, last_seen_at
+        FROM user_devices
+        WHERE user_id = ANY($1)
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var device models.Device
+		if err := rows.Scan(&device.DeviceID, &device.UserID, &device.Token, &device.Platform, &device.CreatedAt, &device.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices[device.UserID] = append(devices[device.UserID], device)
+	}
+
+	return devices, rows.Err()
+}
+
+// DeleteTokens removes tokens the push provider reported as no longer valid
+func (r *PushRepository) DeleteTokens(ctx context.Context, tokens []string) error {
+	ctx, span := tracer.Start(ctx, "PushRepository.DeleteTokens")
+	defer span.End()
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx, `DELETE FROM user_devices WHERE token = ANY($1)`, pq.Array(tokens))
+	if err != nil {
+		return fmt.Errorf("failed to delete device tokens: %w", err)
+	}
+	return nil
+}
+
+// ClaimUnpushedNotifications marks up to limit notifications created after since as pushed and returns them, oldest first,
+// with their recipient's language. Claiming before sending means a crash loses a push rather than sending it twice.
+// Notifications for users inside their quiet hours wait until the quiet hours end.
+func (r *PushRepository) ClaimUnpushedNotifications(ctx context.Context, since time.Time, limit int) ([]models.NotificationDelivery, error) {
+	ctx, span := tracer.Start(ctx, "PushRepository.ClaimUnpushedNotifications")
+	defer span.End()
+
+	query := `
+        UPDATE notifications n
+        SET is_pushed = true
+        FROM users u
+        WHERE u.user_id = n.user_id AND n.notification_id IN (
+            SELECT p.notification_id FROM notifications p
+            WHERE p.is_pushed = false AND p.created_at > $1
+            AND NOT ` + inQuietHours("p.user_id") + `
+            ORDER BY p.created_at
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING n.notification_id, n.user_id, n.notification_type, n.title, n.message, n.params, n.related_conversation_id,
+            n.related_product_id, n.related_user_id, n.created_at, u.preferred_language
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notifications: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.NotificationDelivery{}
+	for rows.Next() {
+		var d models.NotificationDelivery
+		err := rows.Scan(&d.Notification_ID, &d.User_ID, &d.Notification_type, &d.Title, &d.Message, &d.Params,
+			&d.Related_conversation_ID, &d.Related_product_ID, &d.Related_user_ID, &d.Created_at, &d.Language)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		d.Is_Pushed = true
+		d.Deep_link = models.NotificationDeepLink(d.Notifications)
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetOfflineParticipants returns the conversation's participants other than excludeUserID who are not online
+// and want message pushes right now, i.e. have them turned on and are outside their quiet hours
+func (r *PushRepository) GetOfflineParticipants(ctx context.Context, conversationID, excludeUserID uuid.UUID) ([]uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "PushRepository.GetOfflineParticipants")
+	defer span.End()
+
+	query := `
+        SELECT u.user_id
+        FROM conversation_participants cp
+        INNER JOIN users u ON u.user_id = cp.user_id
+        WHERE cp.conversation_id = $1 AND cp.user_id != $2 AND NOT COALESCE(u.is_online, false)
+        AND ` + channelEnabled("u.user_id", "push", "$3") + `
+        AND NOT ` + inQuietHours("u.user_id") + `
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, conversationID, excludeUserID, models.PreferenceMessages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get offline participants: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan participant: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetSenderName returns how a message sender is shown in a push
+func (r *PushRepository) GetSenderName(ctx context.Context, userID uuid.UUID) (string, error) {
+	ctx, span := tracer.Start(ctx, "PushRepository.GetSenderName")
+	defer span.End()
+
+	var name string
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COALESCE(NULLIF(TRIM(CONCAT(first_name, ' ', last_name)), ''), username, 'Someone')
+        FROM users WHERE user_id = $1
+    `, userID).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get sender name: %w", err)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"postswapapi/models"
+	"postswapapi/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SavedSearchRepository struct {
+	db *sql.DB
+}
+
+func NewSavedSearchRepository(db *sql.DB) *SavedSearchRepository {
+	return &SavedSearchRepository{db: db}
+}
+
+const savedSearchColumns = `s.search_id, s.user_id, s.query, s.category, s.size, s.radius_km, s.frequency, s.last_checked_at,
+    s.created_at, s.updated_at`
+
+// savedSearchMatches is true when product p of seller u is a result of saved search s of searcher su.
+// Every word of the query has to appear in the title or category, a radius means the seller's location is the searcher's.
+const savedSearchMatches = `
+    p.status = 'active' AND p.seller_id <> s.user_id
+    AND (s.category IS NULL OR p.category = s.category)
+    AND (s.size IS NULL OR LOWER(p.estimated_size) = LOWER(s.size))
+    AND NOT EXISTS (
+        SELECT 1 FROM regexp_split_to_table(LOWER(s.query), '\s+') AS term
+        WHERE term <> '' AND STRPOS(LOWER(p.title || ' ' || p.category), term) = 0
+    )
+    AND (s.radius_km IS NULL OR (
+        TRIM(COALESCE(su.location, '')) <> '' AND LOWER(TRIM(u.location)) = LOWER(TRIM(su.location))
+    ))`
+
+// how long until a search is next checked after frequency, instant searches are checked on every run
+func savedSearchInterval(frequency string) string {
+	return `CASE ` + frequency + ` WHEN 'daily' THEN INTERVAL '1 day' WHEN 'weekly' THEN INTERVAL '7 days' ELSE INTERVAL '0' END`
+}
+
+func scanSavedSearch(row interface{ Scan(...any) error }, search *models.SavedSearch) error {
+	err := row.Scan(
+		&search.SearchID,
+		&search.UserID,
+		&search.Query,
+		&search.Category,
+		&search.Size,
+		&search.RadiusKm,
+		&search.Frequency,
+		&search.LastCheckedAt,
+		&search.CreatedAt,
+		&search.UpdatedAt,
+	)
+	search.Label = search.Describe()
+	return err
+}
+
+func (r *SavedSearchRepository) CountSavedSearches(ctx context.Context, userID uuid.UUID) (int, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.CountSavedSearches")
+	defer span.End()
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM saved_searches WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count saved searches: %w", err)
+	}
+	return count, nil
+}
+
+// CreateSavedSearch saves a search, it only alerts about listings created from now on
+func (r *SavedSearchRepository) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) (*models.SavedSearch, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.CreateSavedSearch")
+	defer span.End()
+
+	query := `
+        INSERT INTO saved_searches AS s (search_id, user_id, query, category, size, radius_km, frequency, next_check_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NOW() + ` + savedSearchInterval("$7") + `)
+        RETURNING ` + savedSearchColumns
+
+	created := &models.SavedSearch{}
+	err := scanSavedSearch(r.db.QueryRowContext(ctx, query,
+		search.SearchID,
+		search.UserID,
+		search.Query,
+		search.Category,
+		search.Size,
+		search.RadiusKm,
+		search.Frequency,
+	), created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create saved search: %w", err)
+	}
+
+	return created, nil
+}
+
+// ListSavedSearches returns the user's saved searches, oldest first
+func (r *SavedSearchRepository) ListSavedSearches(ctx context.Context, userID uuid.UUID) ([]models.SavedSearch, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.ListSavedSearches")
+	defer span.End()
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+savedSearchColumns+`
+        FROM saved_searches s
+        WHERE s.user_id = $1
+        ORDER BY s.created_at, s.search_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		var search models.SavedSearch
+		if err := scanSavedSearch(rows, &search); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %w", err)
+		}
+		searches = append(searches, search)
+	}
+
+	return searches, rows.Err()
+}
+
+// GetSavedSearch returns one of the user's saved searches
+func (r *SavedSearchRepository) GetSavedSearch(ctx context.Context, searchID, userID uuid.UUID) (*models.SavedSearch, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.GetSavedSearch")
+	defer span.End()
+
+	search := &models.SavedSearch{}
+	err := scanSavedSearch(r.db.QueryRowContext(ctx, `
+        SELECT `+savedSearchColumns+`
+        FROM saved_searches s
+        WHERE s.search_id = $1 AND s.user_id = $2`, searchID, userID), search)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("saved search not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved search: %w", err)
+	}
+
+	return search, nil
+}
+
+// UpdateSavedSearch stores the filters and frequency of search. A new frequency applies from the last check,
+// a search turned back on from off starts over from now so it doesn't alert about everything it missed.
+func (r *SavedSearchRepository) UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) (*models.SavedSearch, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.UpdateSavedSearch")
+	defer span.End()
+
+	query := `
+        UPDATE saved_searches AS s
+        SET query = $3, category = $4, size = $5, radius_km = $6, frequency = $7,
+            last_checked_at = CASE WHEN s.frequency = 'off' AND $7 <> 'off' THEN NOW() ELSE s.last_checked_at END,
+            next_check_at = CASE WHEN s.frequency = 'off' AND $7 <> 'off' THEN NOW() ELSE s.last_checked_at END + ` + savedSearchInterval("$7") + `,
+            updated_at = NOW()
+        WHERE s.search_id = $1 AND s.user_id = $2
+        RETURNING ` + savedSearchColumns
+
+	updated := &models.SavedSearch{}
+	err := scanSavedSearch(r.db.QueryRowContext(ctx, query,
+		search.SearchID,
+		search.UserID,
+		search.Query,
+		search.Category,
+		search.Size,
+		search.RadiusKm,
+		search.Frequency,
+	), updated)
+	if err == sql.ErrNoRows {
+		return nil, utils.NewNotFound("saved search not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update saved search: %w", err)
+	}
+
+	return updated, nil
+}
+
+func (r *SavedSearchRepository) DeleteSavedSearch(ctx context.Context, searchID, userID uuid.UUID) error {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.DeleteSavedSearch")
+	defer span.End()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM saved_searches WHERE search_id = $1 AND user_id = $2`, searchID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %w", err)
+	}
+	if deleted == 0 {
+		return utils.NewNotFound("saved search not found")
+	}
+
+	return nil
+}
+
+// SearchResults returns the active listings matching a saved search, newest first
+func (r *SavedSearchRepository) SearchResults(ctx context.Context, searchID uuid.UUID, limit, offset int) ([]models.FeedItem, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.SearchResults")
+	defer span.End()
+
+	query := `
+        SELECT p.product_id, p.title, p.estimated_size, p.created_at, COALESCE(pp.feed_url, pp.image_url)
+        FROM saved_searches s
+        JOIN users su ON su.user_id = s.user_id
+        CROSS JOIN products p
+        JOIN users u ON u.user_id = p.seller_id
+        LEFT JOIN product_photos pp ON pp.product_id = p.product_id AND pp.display_order = 1
+        WHERE s.search_id = $1 AND ` + savedSearchMatches + `
+        ORDER BY p.created_at DESC, p.product_id
+        LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, searchID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved search results: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.FeedItem{}
+	for rows.Next() {
+		var item models.FeedItem
+		if err := rows.Scan(&item.Product_ID, &item.Title, &item.Estimated_size, &item.Created_at, &item.Image_Url); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search result: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// ClaimDueSearches claims up to limit searches whose next check has passed. Each comes back with the window of
+// listings to check, from its last check until until, and is scheduled for its next check. Claimed searches
+// aren't retried, a failed check skips the listings in its window.
+func (r *SavedSearchRepository) ClaimDueSearches(ctx context.Context, until time.Time, limit int) ([]models.SavedSearchDue, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.ClaimDueSearches")
+	defer span.End()
+
+	query := `
+        WITH due AS (
+            SELECT search_id, last_checked_at
+            FROM saved_searches
+            WHERE frequency <> 'off' AND next_check_at <= NOW()
+            ORDER BY next_check_at
+            LIMIT $2
+            FOR UPDATE SKIP LOCKED
+        )
+        UPDATE saved_searches AS s
+        SET last_checked_at = GREATEST(s.last_checked_at, $1), next_check_at = NOW() + ` + savedSearchInterval("s.frequency") + `
+        FROM due
+        WHERE s.search_id = due.search_id
+        RETURNING ` + savedSearchColumns + `, due.last_checked_at`
+
+	rows, err := r.db.QueryContext(ctx, query, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim saved searches: %w", err)
+	}
+	defer rows.Close()
+
+	claimed := []models.SavedSearchDue{}
+	for rows.Next() {
+		var due models.SavedSearchDue
+		search := &due.Search
+		if err := rows.Scan(&search.SearchID, &search.UserID, &search.Query, &search.Category, &search.Size, &search.RadiusKm,
+			&search.Frequency, &search.LastCheckedAt, &search.CreatedAt, &search.UpdatedAt, &due.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan saved search: %w", err)
+		}
+		search.Label = search.Describe()
+		due.Until = until
+		claimed = append(claimed, due)
+	}
+
+	return claimed, rows.Err()
+}
+
+// CountNewMatches counts the listings matching a saved search created in (since, until] and returns the newest of them
+func (r *SavedSearchRepository) CountNewMatches(ctx context.Context, searchID uuid.UUID, since, until time.Time) (int, *uuid.UUID, error) {
+	ctx, span := tracer.Start(ctx, "SavedSearchRepository.CountNewMatches")
+	defer span.End()
+
+	query := `
+        SELECT COUNT(*), (ARRAY_AGG(p.product_id ORDER BY p.created_at DESC))[1]
+        FROM saved_searches s
+        JOIN users su ON su.user_id = s.user_id
+        JOIN products p ON p.created_at > $2 AND p.created_at <= $3
+        JOIN users u ON u.user_id = p.seller_id
+        WHERE s.search_id = $1 AND ` + savedSearchMatches
+
+	var count int
+	var newest *uuid.UUID
+	if err := r.db.QueryRowContext(ctx, query, searchID, since, until).Scan(&count, &newest); err != nil {
+		return 0, nil, fmt.Errorf("failed to count saved search matches: %w", err)
+	}
+
+	return count, newest, nil
+}
@@ -0,0 +1,507 @@
+// The contract test boots the router against a test database, drives every
+// documented route with real requests and validates each response against the
+// generated OpenAPI spec, so docs/swagger.json cannot drift from the handlers.
+//
+// It is skipped unless TEST_DB_NAME names a disposable database with the schema
+// applied, the other DB_* settings come from the environment or .env:
+//
+//	TEST_DB_NAME=pointswap_test JWT_SECRET=test go test ./routes -run TestContract
+//
+// Realtime events go to the in-memory publisher and uploads to a local media
+// store in a temporary directory.
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"postswapapi/config"
+	"postswapapi/handlers"
+	"postswapapi/repository"
+	"postswapapi/routes"
+	"postswapapi/services"
+	"postswapapi/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-openapi/loads"
+	"github.com/go-openapi/spec"
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/validate"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+
+	_ "github.com/lib/pq"
+)
+
+const (
+	basePath = "/pointSwapApi/v1"
+	specPath = "../docs/swagger.json"
+)
+
+type checker struct {
+	t       *testing.T
+	router  *gin.Engine
+	swagger *spec.Swagger
+	checked int
+
+	// screening runs from the outbox, the check screens listings itself before reading the queue
+	moderation *services.ModerationService
+}
+
+func TestContract(t *testing.T) {
+	dbName := os.Getenv("TEST_DB_NAME")
+	if dbName == "" {
+		t.Skip("TEST_DB_NAME not set, the contract test needs a disposable database with the schema applied")
+	}
+
+	_ = godotenv.Load("../.env")
+	t.Setenv("DB_NAME", dbName)
+	gin.SetMode(gin.TestMode)
+	slog.SetDefault(utils.NewLogger(os.Stderr, "warn", "text"))
+
+	doc, err := loads.Spec(specPath)
+	if err != nil {
+		t.Fatalf("failed to load spec: %v", err)
+	}
+	if err := validate.Spec(doc, strfmt.Default); err != nil {
+		t.Fatalf("spec is invalid: %v", err)
+	}
+	expanded, err := doc.Expanded()
+	if err != nil {
+		t.Fatalf("failed to expand spec: %v", err)
+	}
+
+	config.ConnectToDb()
+
+	// in-memory realtime so the check never needs Ably
+	publisher := services.NewMemoryPublisher()
+	messageRepo := repository.NewMessageRepository(config.DB)
+	messageService := services.NewMessageService(messageRepo, publisher)
+	presenceService := services.NewPresenceService(repository.NewPresenceRepository(config.DB), messageRepo, publisher)
+	eventService := services.NewEventService(repository.NewEventRepository(config.DB))
+	handlers.SetEventService(eventService)
+	handlers.SetMessageService(messageService)
+	outbox := services.NewOutboxDispatcher(repository.NewOutboxRepository(config.DB), publisher, eventService)
+	messageService.SetOutbox(outbox)
+	handlers.SetOutbox(outbox)
+	pushService := services.NewPushService(repository.NewPushRepository(config.DB), services.NewMemoryPushSender())
+	outbox.SetPush(pushService)
+	handlers.SetNotificationPreferenceService(services.NewNotificationPreferenceService(repository.NewNotificationPreferenceRepository(config.DB)))
+	mediaStore, err := services.NewLocalMediaStore(t.TempDir(), "http://contractcheck", "contractcheck")
+	if err != nil {
+		t.Fatalf("failed to create media store: %v", err)
+	}
+	mediaService := services.NewMediaService(repository.NewMediaRepository(config.DB), mediaStore)
+	handlers.SetMediaService(mediaService)
+	messageService.SetMedia(mediaService)
+	moderationService := services.NewModerationService(repository.NewModerationRepository(config.DB))
+	outbox.SetModeration(moderationService)
+	outbox.SetNotifier(handlers.DeliverNotification)
+	handlers.SetFeedService(services.NewFeedService(repository.NewFeedRepository(config.DB), services.DefaultFeedWeights()))
+	savedSearchService := services.NewSavedSearchService(repository.NewSavedSearchRepository(config.DB))
+	savedSearchService.SetNotifier(handlers.CreateNotification)
+	outboxCtx, stopOutbox := context.WithCancel(context.Background())
+	defer stopOutbox()
+	go outbox.Run(outboxCtx)
+
+	router := routes.SetupRouter(
+		handlers.NewMessageHandler(messageService),
+		handlers.NewRealtimeHandler(publisher, presenceService),
+		handlers.NewEventHandler(eventService, presenceService),
+		handlers.NewDeviceHandler(pushService),
+		handlers.NewMediaHandler(mediaService),
+		handlers.NewUploadHandler(mediaService),
+		handlers.NewModerationHandler(moderationService),
+		handlers.NewSavedSearchHandler(savedSearchService),
+	)
+
+	c := &checker{
+		t:       t,
+		router:  router,
+		swagger: expanded.Spec(),
+
+		moderation: moderationService,
+	}
+
+	c.run()
+
+	t.Logf("checked %d responses against the spec", c.checked)
+}
+
+// run walks through the API the way the app does, checking every response on the way
+func (c *checker) run() {
+	password := "contract-check-password"
+	seller := c.register(password)
+	buyer := c.register(password)
+
+	c.call("POST", "/profileSetUp", "/profileSetUp", seller, map[string]any{
+		"first_name": "Contract", "last_name": "Seller", "avatar_url": c.uploadMedia(seller, "avatar"),
+	})
+	c.call("POST", "/profileSetUp", "/profileSetUp", buyer, map[string]any{
+		"first_name": "Contract", "last_name": "Buyer", "avatar_url": "https://example.com/avatar.jpg",
+	})
+	c.call("GET", "/userProfile", "/userProfile", seller, nil)
+	c.call("GET", "/me", "/me", seller, nil)
+	c.call("POST", "/location", "/location", seller, map[string]any{"location": "Lagos"})
+	c.call("PUT", "/users/status", "/users/status", seller, map[string]any{"is_online": true})
+
+	sellerProfile := c.call("GET", "/me", "/me", seller, nil)
+	sellerID := stringAt(sellerProfile, "data", "user_id")
+	c.call("GET", "/users/"+sellerID+"/status", "/users/{user_id}/status", "", nil)
+
+	created := c.call("POST", "/products", "/products", seller, map[string]any{
+		"category": "shoes", "image_urls": []string{c.uploadMedia(seller, "product")},
+		"title": "Contract check sneakers", "estimated_size": "42",
+	})
+	productID := stringAt(created, "data", "product_id")
+
+	c.call("GET", "/products", "/products", buyer, nil)
+	c.call("GET", "/products?category=shoes", "/products", "", nil)
+	c.call("GET", "/products/"+productID, "/products/{product_id}", "", nil)
+	c.call("GET", "/products/me", "/products/me", seller, nil)
+
+	savePath := "/products/" + productID + "/save"
+	c.call("POST", savePath, "/products/{product_id}/save", buyer, nil)
+	c.call("POST", savePath, "/products/{product_id}/save", seller, nil)
+	c.call("GET", "/me/saved", "/me/saved", buyer, nil)
+	c.call("GET", "/me/saved?limit=1&offset=0", "/me/saved", buyer, nil)
+	c.call("GET", "/products/"+productID, "/products/{product_id}", seller, nil)
+
+	search := c.call("POST", "/saved-searches", "/saved-searches", buyer, map[string]any{
+		"query": "sneakers", "category": "shoes", "size": "42", "radius_km": 10, "frequency": "instant",
+	})
+	c.call("POST", "/saved-searches", "/saved-searches", buyer, map[string]any{"radius_km": 10})
+	searchPath := "/saved-searches/" + stringAt(search, "data", "search_id")
+	c.call("GET", "/saved-searches", "/saved-searches", buyer, nil)
+	c.call("PATCH", searchPath, "/saved-searches/{search_id}", buyer, map[string]any{"frequency": "weekly", "radius_km": 0})
+	c.call("GET", searchPath+"/results", "/saved-searches/{search_id}/results", buyer, nil)
+	c.call("GET", searchPath+"/results", "/saved-searches/{search_id}/results", seller, nil)
+	c.call("DELETE", searchPath, "/saved-searches/{search_id}", buyer, nil)
+
+	// the buyer lists the seller's photo as their own, it lands in the moderation queue
+	moderator := c.register(password)
+	if _, err := config.DB.Exec(`UPDATE users SET is_moderator = true WHERE user_id = $1`,
+		stringAt(c.call("GET", "/me", "/me", moderator, nil), "data", "user_id")); err != nil {
+		c.t.Fatalf("failed to make a moderator: %v", err)
+	}
+	reposted := c.call("POST", "/products", "/products", buyer, map[string]any{
+		"category": "shoes", "image_urls": []string{c.uploadMedia(buyer, "product")},
+		"title": "Definitely my sneakers", "estimated_size": "42",
+	})
+	repostedID, err := uuid.Parse(stringAt(reposted, "data", "product_id"))
+	if err != nil {
+		c.t.Fatalf("reposted listing has no product_id: %v", err)
+	}
+	if err := c.moderation.ScreenProduct(context.Background(), repostedID); err != nil {
+		c.t.Fatalf("failed to screen the reposted listing: %v", err)
+	}
+	c.call("GET", "/moderation/flags", "/moderation/flags", buyer, nil)
+	flags := c.call("GET", "/moderation/flags?status=open", "/moderation/flags", moderator, nil)
+	flag := firstItem(flags, "data", "items")
+	if stringAt(flag, "flag_id") == "" {
+		c.t.Fatalf("the reposted photo was not flagged")
+	}
+	blocked := c.call("POST", "/moderation/blocked-images", "/moderation/blocked-images", moderator, map[string]any{
+		"photo_id": stringAt(flag, "photo_id"), "reason": "stolen listing photo",
+	})
+	c.call("GET", "/moderation/blocked-images", "/moderation/blocked-images", moderator, nil)
+	c.call("POST", "/moderation/flags/"+stringAt(flag, "flag_id")+"/resolve", "/moderation/flags/{flag_id}/resolve", moderator,
+		map[string]any{"action": "remove_listing"})
+	c.call("DELETE", "/moderation/blocked-images/"+stringAt(blocked, "data", "hash_id"), "/moderation/blocked-images/{hash_id}", moderator, nil)
+
+	wantPath := "/product_wants/" + productID + "/want"
+	c.call("POST", wantPath, "/product_wants/{product_id}/want", seller, map[string]any{
+		"wanted_category": "bags", "wanted_size": "M",
+	})
+	c.call("GET", wantPath, "/product_wants/{product_id}/want", seller, nil)
+	c.call("PUT", wantPath, "/product_wants/{product_id}/want", seller, map[string]any{
+		"wanted_category": "bags", "wanted_size": "L",
+	})
+	c.call("GET", "/products?mode=for_you", "/products", buyer, nil)
+	c.call("GET", "/products?mode=for_you&category=shoes&limit=1&offset=0", "/products", "", nil)
+	forYou := c.call("GET", "/products?mode=for_you&limit=1", "/products", buyer, nil)
+	if cursor := stringAt(forYou, "data", "next_cursor"); cursor != "" {
+		c.call("GET", "/products?mode=for_you&limit=1&cursor="+url.QueryEscape(cursor), "/products", buyer, nil)
+	}
+	c.call("GET", "/products?mode=for_you&cursor=nope", "/products", buyer, nil)
+	c.call("GET", "/products?mode=popular", "/products", buyer, nil)
+
+	c.call("GET", "/notifications", "/notifications", seller, nil)
+	c.call("PATCH", "/notifications/"+uuid.NewString(), "/notifications/{notification_id}", seller, nil)
+
+	conversation := c.call("POST", "/conversations", "/conversations", buyer, map[string]any{"recipient_id": sellerID})
+	conversationID := stringAt(conversation, "data", "conversation_id")
+	c.call("POST", "/conversations/"+conversationID+"/messages", "/conversations/{conversation_id}/messages", buyer,
+		map[string]any{"message_text": "Is this still available?"})
+	c.call("POST", "/conversations/"+conversationID+"/messages", "/conversations/{conversation_id}/messages", buyer,
+		map[string]any{"attachments": []map[string]any{{"url": c.uploadMedia(buyer, "chat")}, {"url": c.uploadMedia(buyer, "chat")}}})
+	c.call("POST", "/conversations/"+conversationID+"/messages", "/conversations/{conversation_id}/messages", buyer,
+		map[string]any{"attachments": []map[string]any{{"url": "https://example.com/a.jpg"}}})
+	c.call("POST", "/conversations/"+conversationID+"/messages", "/conversations/{conversation_id}/messages", seller,
+		map[string]any{"message_type": "product_card", "payload": map[string]any{"product_id": productID}})
+	c.call("POST", "/conversations/"+conversationID+"/messages", "/conversations/{conversation_id}/messages", buyer,
+		map[string]any{"message_type": "meeting_location", "payload": map[string]any{"name": "Ikeja City Mall", "latitude": 6.6018, "longitude": 3.3515}})
+	c.call("GET", "/conversations/"+conversationID+"/messages", "/conversations/{conversation_id}/messages", seller, nil)
+	c.call("GET", "/conversations", "/conversations", seller, nil)
+	c.call("GET", "/conversations/sync?since="+url.QueryEscape(time.Now().Add(-time.Hour).Format(time.RFC3339)), "/conversations/sync", seller, nil)
+	c.call("PUT", "/conversations/"+conversationID+"/read", "/conversations/{conversation_id}/read", seller, nil)
+	c.call("POST", "/conversations/"+conversationID+"/typing", "/conversations/{conversation_id}/typing", buyer,
+		map[string]any{"is_typing": true})
+	c.call("POST", "/realtime/heartbeat", "/realtime/heartbeat", buyer, nil)
+
+	deviceToken := "contract-check-" + uuid.NewString()
+	c.call("POST", "/devices", "/devices", buyer, map[string]any{"token": deviceToken, "platform": "android"})
+	c.call("DELETE", "/devices", "/devices", buyer, map[string]any{"token": deviceToken})
+	c.call("DELETE", "/devices", "/devices", buyer, map[string]any{"token": deviceToken})
+
+	c.call("PUT", "/users/language", "/users/language", buyer, map[string]any{"preferred_language": "es"})
+	c.call("PUT", "/users/language", "/users/language", buyer, map[string]any{"preferred_language": "klingon"})
+	c.call("GET", "/notifications/preferences", "/notifications/preferences", buyer, nil)
+	c.call("PUT", "/notifications/preferences", "/notifications/preferences", buyer, map[string]any{
+		"timezone": "Europe/Berlin", "quiet_hours_start": "22:00", "quiet_hours_end": "07:00", "digest_frequency": "daily",
+		"preferences": []map[string]any{{"notification_type": "matches", "push": false}},
+	})
+	c.call("PUT", "/notifications/preferences", "/notifications/preferences", buyer, map[string]any{"timezone": "Mars/Olympus"})
+	c.call("GET", "/notifications?type=matches", "/notifications", buyer, nil)
+	c.call("GET", "/notifications/groups?unread_only=true", "/notifications/groups", buyer, nil)
+	c.call("GET", "/notifications/unread-count", "/notifications/unread-count", buyer, nil)
+	c.call("POST", "/notifications/read", "/notifications/read", buyer, map[string]any{"notification_ids": []string{uuid.NewString()}})
+	c.call("POST", "/notifications/read-all?type=matches", "/notifications/read-all", buyer, nil)
+	c.call("DELETE", "/notifications", "/notifications", buyer, map[string]any{"notification_ids": []string{}})
+	c.call("PATCH", "/notifications/"+uuid.NewString(), "/notifications/{notification_id}", buyer, nil)
+
+	signed := c.call("POST", "/media/uploads", "/media/uploads", buyer, map[string]any{"purpose": "chat", "content_type": "image/png"})
+	storageKey := stringAt(signed, "data", "storage_key")
+	c.upload(stringAt(signed, "data", "upload_url"), testPNG())
+	c.call("POST", "/media", "/media", buyer, map[string]any{"storage_key": storageKey, "purpose": "chat"})
+	c.call("POST", "/media", "/media", seller, map[string]any{"storage_key": storageKey, "purpose": "chat"})
+	c.call("POST", "/media", "/media", buyer, map[string]any{"storage_key": storageKey + "x", "purpose": "chat"})
+
+	buyerProfile := c.call("GET", "/me", "/me", buyer, nil)
+	sent := c.call("POST", "/messages", "/messages", seller, map[string]any{
+		"recipient_id": stringAt(buyerProfile, "data", "user_id"), "message_text": "Yes it is",
+	})
+	messageID := stringAt(sent, "data", "message", "id")
+	c.call("PATCH", "/messages/"+messageID, "/messages/{message_id}", seller, map[string]any{"message_text": "Yes it still is"})
+	c.call("GET", "/messages/"+messageID+"/edits", "/messages/{message_id}/edits", buyer, nil)
+	c.call("DELETE", "/messages/"+messageID, "/messages/{message_id}", seller, nil)
+
+	third := c.register(password)
+	thirdID := stringAt(c.call("GET", "/me", "/me", third, nil), "data", "user_id")
+	buyerID := stringAt(buyerProfile, "data", "user_id")
+	group := c.call("POST", "/conversations/groups", "/conversations/groups", seller, map[string]any{
+		"title": "Three way swap", "participant_ids": []string{buyerID, thirdID}, "product_id": productID,
+	})
+	groupID := stringAt(group, "data", "conversation_id")
+	participantsPath := "/conversations/" + groupID + "/participants"
+	c.call("DELETE", participantsPath+"/"+thirdID, "/conversations/{conversation_id}/participants/{user_id}", buyer, nil)
+	c.call("DELETE", participantsPath+"/"+thirdID, "/conversations/{conversation_id}/participants/{user_id}", seller, nil)
+	c.call("POST", participantsPath, "/conversations/{conversation_id}/participants", seller,
+		map[string]any{"user_ids": []string{thirdID}})
+	c.call("GET", "/conversations", "/conversations", third, nil)
+
+	c.call("POST", "/products/"+productID+"/conversation", "/products/{product_id}/conversation", buyer, nil)
+	c.call("GET", "/products/"+productID, "/products/{product_id}", buyer, nil)
+	c.call("POST", "/products/"+productID, "/products/{product_id}", seller, map[string]any{"status": "swapped"})
+	c.call("DELETE", savePath, "/products/{product_id}/save", buyer, nil)
+	c.call("DELETE", "/products/"+productID, "/products/{product_id}", seller, nil)
+
+	// error paths must match the documented error envelope too
+	c.call("POST", "/register", "/register", "", map[string]any{"email": "not-an-email"})
+	c.call("GET", "/products/"+uuid.NewString(), "/products/{product_id}", "", nil)
+	c.call("GET", "/conversations", "/conversations", "", nil)
+	c.call("GET", "/conversations/"+conversationID+"/messages", "/conversations/{conversation_id}/messages", "", nil)
+}
+
+func (c *checker) register(password string) string {
+	body := c.call("POST", "/register", "/register", "", map[string]any{
+		"email":    fmt.Sprintf("contract-%s@example.com", uuid.NewString()[:8]),
+		"password": password,
+	})
+	token := stringAt(body, "data", "token")
+	if token == "" {
+		c.t.Fatalf("could not register a test user, is the database reachable?")
+	}
+	return token
+}
+
+// call performs one request and validates the response against the operation documented for pathTemplate
+func (c *checker) call(method, path, pathTemplate, token string, body any) map[string]any {
+	var reader *bytes.Reader
+	if body != nil {
+		raw, _ := json.Marshal(body)
+		reader = bytes.NewReader(raw)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, basePath+path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+
+	label := fmt.Sprintf("%s %s -> %d", method, pathTemplate, rec.Code)
+	c.checked++
+
+	var decoded map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &decoded); err != nil {
+		c.t.Errorf("%s: response is not JSON: %q", label, rec.Body.String())
+		return nil
+	}
+
+	schema, err := c.responseSchema(method, pathTemplate, rec.Code)
+	if err != nil {
+		c.t.Errorf("%s: %v", label, err)
+		return decoded
+	}
+
+	if err := validate.AgainstSchema(schema, stripNulls(decoded), strfmt.Default); err != nil {
+		c.t.Errorf("%s: %v", label, err)
+	}
+
+	return decoded
+}
+
+// uploadMedia uploads a test image for the user through a signed upload and returns its media ID
+func (c *checker) uploadMedia(token, purpose string) string {
+	signed := c.call("POST", "/media/uploads", "/media/uploads", token, map[string]any{"purpose": purpose, "content_type": "image/png"})
+	storageKey := stringAt(signed, "data", "storage_key")
+	c.upload(stringAt(signed, "data", "upload_url"), testPNG())
+	confirmed := c.call("POST", "/media", "/media", token, map[string]any{"storage_key": storageKey, "purpose": purpose})
+	return stringAt(confirmed, "data", "media_id")
+}
+
+// upload sends a file to a signed local upload URL, the route is not part of the documented API
+func (c *checker) upload(uploadURL string, file []byte) {
+	parsed, err := url.Parse(uploadURL)
+	if err != nil {
+		c.t.Errorf("signed upload url %q: %v", uploadURL, err)
+		return
+	}
+
+	req := httptest.NewRequest(http.MethodPut, parsed.RequestURI(), bytes.NewReader(file))
+	rec := httptest.NewRecorder()
+	c.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		c.t.Errorf("PUT signed upload -> %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// testPNG is a small gradient for the upload checks, every call returns the same picture
+func testPNG() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8((x * y) % 256), B: uint8(y * 4), A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func (c *checker) responseSchema(method, pathTemplate string, status int) (*spec.Schema, error) {
+	item, ok := c.swagger.Paths.Paths[pathTemplate]
+	if !ok {
+		return nil, fmt.Errorf("path is not documented")
+	}
+
+	var op *spec.Operation
+	switch method {
+	case http.MethodGet:
+		op = item.Get
+	case http.MethodPost:
+		op = item.Post
+	case http.MethodPut:
+		op = item.Put
+	case http.MethodPatch:
+		op = item.Patch
+	case http.MethodDelete:
+		op = item.Delete
+	}
+	if op == nil {
+		return nil, fmt.Errorf("method is not documented")
+	}
+
+	resp, ok := op.Responses.StatusCodeResponses[status]
+	if !ok {
+		return nil, fmt.Errorf("status is not documented")
+	}
+	if resp.Schema == nil {
+		return nil, fmt.Errorf("response has no schema")
+	}
+
+	return resp.Schema, nil
+}
+
+// stripNulls drops null values, swagger 2.0 cannot mark a property nullable so
+// a null is treated the same as the optional property being absent
+func stripNulls(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			if item != nil {
+				out[k] = stripNulls(item)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, 0, len(val))
+		for _, item := range val {
+			out = append(out, stripNulls(item))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func stringAt(body map[string]any, keys ...string) string {
+	var current any = body
+	for _, key := range keys {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return ""
+		}
+		current = m[key]
+	}
+	s, _ := current.(string)
+	return strings.TrimSpace(s)
+}
+
+// firstItem returns the first object of the array at keys, empty when there is none
+func firstItem(body map[string]any, keys ...string) map[string]any {
+	var current any = body
+	for _, key := range keys {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return map[string]any{}
+		}
+		current = m[key]
+	}
+	if items, ok := current.([]any); ok && len(items) > 0 {
+		if item, ok := items[0].(map[string]any); ok {
+			return item
+		}
+	}
+	return map[string]any{}
+}
@@ -1,19 +1,28 @@
 package routes
 
 import (
-	"log"
+	"net/http"
+	_ "postswapapi/docs"
 	"postswapapi/handlers"
 	"postswapapi/middleware"
 
 	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func SetupRouter(messageHandler *handlers.MessageHandler) *gin.Engine {
-	r := gin.Default()
-	uploadHandler, err := handlers.NewUploadHandler()
-	if err != nil {
-		log.Fatal("Failed to initialize upload handler:", err)
-	}
+func SetupRouter(messageHandler *handlers.MessageHandler, realtimeHandler *handlers.RealtimeHandler, eventHandler *handlers.EventHandler,
+	deviceHandler *handlers.DeviceHandler, mediaHandler *handlers.MediaHandler, uploadHandler *handlers.UploadHandler,
+	moderationHandler *handlers.ModerationHandler, savedSearchHandler *handlers.SavedSearchHandler) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery(), otelgin.Middleware("pointswap-api"), middleware.RequestID(), middleware.RequestLogger(), middleware.Metrics())
+
+	//API docs, regenerate with: swag init --overridesFile .swaggo
+	r.GET("/docs", func(ctx *gin.Context) {
+		ctx.Redirect(http.StatusMovedPermanently, "/docs/index.html")
+	})
+	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	//User authentication
 	api := r.Group("/pointSwapApi/v1")
@@ -23,6 +32,7 @@ func SetupRouter(messageHandler *handlers.MessageHandler) *gin.Engine {
 	api.POST("/login", handlers.Login)
 	api.POST("/location", middleware.AuthMiddleWare(), handlers.GetLocation)
 	api.PUT("/users/status", middleware.AuthMiddleWare(), handlers.UpdateOnlineStatus)
+	api.PUT("/users/language", middleware.AuthMiddleWare(), handlers.UpdateLanguage)
 	api.GET("/users/:user_id/status", middleware.OptionalAuthMiddleWare(), handlers.GetUserStatus)
 
 	//Product and feed
@@ -34,6 +44,9 @@ func SetupRouter(messageHandler *handlers.MessageHandler) *gin.Engine {
 		product.GET("/:product_id", middleware.OptionalAuthMiddleWare(), handlers.GetProductById)
 		product.POST("/:product_id", middleware.AuthMiddleWare(), handlers.UpdateProductStatus)
 		product.DELETE("/:product_id", middleware.AuthMiddleWare(), handlers.DeleteProduct)
+		product.POST("/:product_id/conversation", middleware.AuthMiddleWare(), messageHandler.MessageSeller)
+		product.POST("/:product_id/save", middleware.AuthMiddleWare(), handlers.SaveProduct)
+		product.DELETE("/:product_id/save", middleware.AuthMiddleWare(), handlers.UnsaveProduct)
 
 	}
 
@@ -47,14 +60,27 @@ func SetupRouter(messageHandler *handlers.MessageHandler) *gin.Engine {
 	notification := api.Group("/notifications")
 	{
 		notification.GET("", middleware.OptionalAuthMiddleWare(), handlers.GetMyNotifications)
+		notification.GET("/groups", middleware.AuthMiddleWare(), handlers.GetNotificationGroups)
+		notification.GET("/unread-count", middleware.AuthMiddleWare(), handlers.GetUnreadNotificationCount)
+		notification.POST("/read", middleware.AuthMiddleWare(), handlers.MarkNotificationsAsRead)
+		notification.POST("/read-all", middleware.AuthMiddleWare(), handlers.MarkAllNotificationsAsRead)
+		notification.DELETE("", middleware.AuthMiddleWare(), handlers.DeleteNotifications)
+		notification.GET("/preferences", middleware.AuthMiddleWare(), handlers.GetNotificationPreferences)
+		notification.PUT("/preferences", middleware.AuthMiddleWare(), handlers.UpdateNotificationPreferences)
 		notification.PATCH("/:notification_id", middleware.AuthMiddleWare(), handlers.MarkNotifcationAsRead)
 	}
 
+	// Push notification devices
+	api.POST("/devices", middleware.AuthMiddleWare(), deviceHandler.RegisterDevice)
+	api.DELETE("/devices", middleware.AuthMiddleWare(), deviceHandler.UnregisterDevice)
+
 	// Message routes
 	messages := api.Group("/messages")
 	{
 		messages.POST("", middleware.AuthMiddleWare(), messageHandler.SendMessage)
 		messages.GET("/ably-token", middleware.AuthMiddleWare(), messageHandler.GetAblyToken)
+		messages.PATCH("/:message_id", middleware.AuthMiddleWare(), messageHandler.EditMessage)
+		messages.GET("/:message_id/edits", middleware.AuthMiddleWare(), messageHandler.GetMessageEdits)
 		messages.DELETE("/:message_id", middleware.AuthMiddleWare(), messageHandler.DeleteMessage)
 	}
 
@@ -62,14 +88,53 @@ func SetupRouter(messageHandler *handlers.MessageHandler) *gin.Engine {
 	{
 		conversations.POST("", middleware.AuthMiddleWare(), messageHandler.CreateConversation)
 		conversations.GET("", middleware.AuthMiddleWare(), messageHandler.GetUserConversations)
+		conversations.GET("/sync", middleware.AuthMiddleWare(), messageHandler.SyncConversations)
+		conversations.POST("/groups", middleware.AuthMiddleWare(), messageHandler.CreateGroupConversation)
+		conversations.POST("/:conversation_id/participants", middleware.AuthMiddleWare(), messageHandler.AddParticipants)
+		conversations.DELETE("/:conversation_id/participants/:user_id", middleware.AuthMiddleWare(), messageHandler.RemoveParticipant)
 		conversations.POST("/:conversation_id/messages", middleware.AuthMiddleWare(), messageHandler.SendMessageToConversation)
 		conversations.GET("/:conversation_id/messages", middleware.AuthMiddleWare(), messageHandler.GetConversationMessages)
 		conversations.PUT("/:conversation_id/read", middleware.AuthMiddleWare(), messageHandler.MarkConversationAsRead)
+		conversations.POST("/:conversation_id/typing", middleware.AuthMiddleWare(), messageHandler.SetTyping)
 	}
 
+	// Realtime WebSocket (authenticates itself, browsers can't send headers on the handshake)
+	api.GET("/realtime/ws", realtimeHandler.Connect)
+	api.POST("/realtime/heartbeat", middleware.AuthMiddleWare(), realtimeHandler.Heartbeat)
+
+	// Server-Sent Events for notifications and feed updates
+	api.GET("/events", middleware.AuthMiddleWare(), eventHandler.Stream)
+
 	api.POST("/upload/image", middleware.AuthMiddleWare(), uploadHandler.UploadImage)
 
-	api.GET("/me", middleware.AuthMiddleWare())
+	// Direct uploads: sign, upload to storage, confirm. The local store receives and serves files itself.
+	api.POST("/media/uploads", middleware.AuthMiddleWare(), mediaHandler.SignUpload)
+	api.POST("/media", middleware.AuthMiddleWare(), mediaHandler.ConfirmUpload)
+	api.PUT("/media/local/*key", mediaHandler.ReceiveLocalUpload)
+	api.GET("/media/files/*key", mediaHandler.ServeLocalFile)
+
+	// Moderation queue and image blocklist, moderators only
+	moderation := api.Group("/moderation", middleware.AuthMiddleWare(), middleware.RequireModerator())
+	{
+		moderation.GET("/flags", moderationHandler.ListFlags)
+		moderation.POST("/flags/:flag_id/resolve", moderationHandler.ResolveFlag)
+		moderation.GET("/blocked-images", moderationHandler.ListBlockedImages)
+		moderation.POST("/blocked-images", moderationHandler.BlockImage)
+		moderation.DELETE("/blocked-images/:hash_id", moderationHandler.UnblockImage)
+	}
+
+	// Saved searches alert their owner about new matching listings
+	savedSearches := api.Group("/saved-searches", middleware.AuthMiddleWare())
+	{
+		savedSearches.POST("", savedSearchHandler.CreateSavedSearch)
+		savedSearches.GET("", savedSearchHandler.ListSavedSearches)
+		savedSearches.PATCH("/:search_id", savedSearchHandler.UpdateSavedSearch)
+		savedSearches.DELETE("/:search_id", savedSearchHandler.DeleteSavedSearch)
+		savedSearches.GET("/:search_id/results", savedSearchHandler.GetSavedSearchResults)
+	}
+
+	api.GET("/me", middleware.AuthMiddleWare(), handlers.GetUser)
+	api.GET("/me/saved", middleware.AuthMiddleWare(), handlers.GetSavedProducts)
 
 	return r
 
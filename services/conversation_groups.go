@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/utils"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ChannelEvicter is implemented by backends that hold server side subscriptions (the WebSocket hub),
+// so a user removed from a conversation stops receiving its events straight away
+type ChannelEvicter interface {
+	Evict(channel string, userID uuid.UUID)
+}
+
+var errNotGroup = utils.NewBadRequest("only group conversations have managed participants")
+
+// CreateGroupConversation starts a group owned by ownerID, optionally about a product one of them listed
+func (s *MessageService) CreateGroupConversation(ctx context.Context, ownerID uuid.UUID, title string, memberIDs []uuid.UUID, productID *uuid.UUID) (uuid.UUID, error) {
+	if productID != nil {
+		sellerID, err := s.repo.GetProductSellerID(ctx, *productID)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to get product: %w", err)
+		}
+		if sellerID != ownerID && !slices.Contains(memberIDs, sellerID) {
+			return uuid.Nil, utils.NewBadRequest("product must belong to one of the participants")
+		}
+	}
+
+	conversationID, err := s.repo.CreateGroupConversation(ctx, ownerID, title, memberIDs, productID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create group conversation: %w", err)
+	}
+
+	s.postMembershipNotice(ctx, conversationID, ownerID, func(names map[uuid.UUID]string) string {
+		return fmt.Sprintf("%s created the group \"%s\"", names[ownerID], title)
+	}, ownerID)
+
+	return conversationID, nil
+}
+
+// AddParticipants lets a group owner or admin add members, returns who was newly added
+func (s *MessageService) AddParticipants(ctx context.Context, conversationID, actorID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if err := s.requireGroup(ctx, conversationID); err != nil {
+		return nil, err
+	}
+
+	role, err := s.participantRole(ctx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role != models.ParticipantRoleOwner && role != models.ParticipantRoleAdmin {
+		return nil, utils.NewForbidden("only the group owner or an admin can add participants")
+	}
+
+	added, err := s.repo.AddParticipants(ctx, conversationID, userIDs, models.MaxGroupParticipants)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(added) == 0 {
+		return added, nil
+	}
+
+	s.postMembershipNotice(ctx, conversationID, actorID, func(names map[uuid.UUID]string) string {
+		addedNames := make([]string, 0, len(added))
+		for _, id := range added {
+			addedNames = append(addedNames, names[id])
+		}
+		return fmt.Sprintf("%s added %s", names[actorID], strings.Join(addedNames, ", "))
+	}, append([]uuid.UUID{actorID}, added...)...)
+
+	return added, nil
+}
+
+// RemoveParticipant removes a member from a group. Anyone can leave, the owner and admins can remove
+// members, and only the owner can remove admins. When the owner leaves the next admin or member takes over.
+func (s *MessageService) RemoveParticipant(ctx context.Context, conversationID, actorID, targetID uuid.UUID) error {
+	if err := s.requireGroup(ctx, conversationID); err != nil {
+		return err
+	}
+
+	// look the names up before the target leaves the conversation
+	names, err := s.repo.GetUserNames(ctx, []uuid.UUID{actorID, targetID})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load names for membership notice", "conversation_id", conversationID, "error", err)
+	}
+
+	leaving := actorID == targetID
+	newOwnerID, err := s.repo.RemoveParticipant(ctx, conversationID, actorID, targetID, func(actorRole, targetRole string) error {
+		switch {
+		case actorRole == "":
+			return errNotParticipant
+		case targetRole == "":
+			return utils.NewNotFound("participant not found")
+		case leaving:
+			return nil
+		case targetRole == models.ParticipantRoleOwner:
+			return utils.NewForbidden("the group owner cannot be removed")
+		case targetRole == models.ParticipantRoleAdmin && actorRole != models.ParticipantRoleOwner:
+			return utils.NewForbidden("only the group owner can remove an admin")
+		case actorRole == models.ParticipantRoleMember:
+			return utils.NewForbidden("only the group owner or an admin can remove participants")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if evicter, ok := s.publisher.(ChannelEvicter); ok {
+		evicter.Evict(ConversationChannel(conversationID), targetID)
+	}
+
+	notice := fmt.Sprintf("%s removed %s", names[actorID], names[targetID])
+	if leaving {
+		notice = fmt.Sprintf("%s left the group", names[targetID])
+	}
+
+	if newOwnerID != uuid.Nil {
+		if newOwnerNames, err := s.repo.GetUserNames(ctx, []uuid.UUID{newOwnerID}); err == nil {
+			notice += fmt.Sprintf(", %s is now the owner", newOwnerNames[newOwnerID])
+		}
+	}
+
+	s.postMembershipNotice(ctx, conversationID, actorID, func(map[uuid.UUID]string) string { return notice })
+
+	return nil
+}
+
+func (s *MessageService) requireGroup(ctx context.Context, conversationID uuid.UUID) error {
+	conversation, err := s.repo.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	if !conversation.IsGroup {
+		return errNotGroup
+	}
+	return nil
+}
+
+// participantRole is GetParticipantRole with non-members reported as forbidden rather than not found
+func (s *MessageService) participantRole(ctx context.Context, conversationID, userID uuid.UUID) (string, error) {
+	role, err := s.repo.GetParticipantRole(ctx, conversationID, userID)
+	if utils.IsCode(err, utils.CodeNotFound) {
+		return "", errNotParticipant
+	}
+	return role, err
+}
+
+// postMembershipNotice writes a system message about a membership change and tells clients to refresh the participant list.
+// Failures are logged, the membership change itself has already happened.
+func (s *MessageService) postMembershipNotice(ctx context.Context, conversationID, actorID uuid.UUID, text func(names map[uuid.UUID]string) string, nameIDs ...uuid.UUID) {
+	names := map[uuid.UUID]string{}
+	if len(nameIDs) > 0 {
+		var err error
+		if names, err = s.repo.GetUserNames(ctx, nameIDs); err != nil {
+			slog.WarnContext(ctx, "failed to load names for membership notice", "conversation_id", conversationID, "error", err)
+			return
+		}
+	}
+
+	_, err := s.repo.CreateSystemMessage(ctx, conversationID, actorID, text(names), func(message *models.Message) ([]models.OutboxEntry, error) {
+		entries, err := newMessageOutbox(message)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := models.NewRealtimeOutboxEntry(ConversationChannel(conversationID), "participants_updated", map[string]interface{}{
+			"conversation_id": conversationID.String(),
+		})
+		return append(entries, updated), err
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to create membership notice", "conversation_id", conversationID, "error", err)
+		return
+	}
+	s.outbox.Notify()
+}
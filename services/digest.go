@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"time"
+)
+
+const (
+	digestPollInterval = 15 * time.Minute
+	digestBatchSize    = 100
+	// at most this many notifications are listed, the rest are only counted
+	digestMaxListed = 10
+)
+
+// DigestService emails users a summary of unread notifications and new matches, daily or weekly at 08:00 their time.
+// A digest with nothing in it is not sent.
+type DigestService struct {
+	repo   *repository.NotificationPreferenceRepository
+	sender EmailSender
+}
+
+func NewDigestService(repo *repository.NotificationPreferenceRepository, sender EmailSender) *DigestService {
+	return &DigestService{repo: repo, sender: sender}
+}
+
+// Run sends due digests until ctx is cancelled
+func (s *DigestService) Run(ctx context.Context) {
+	ticker := time.NewTicker(digestPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.sendDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *DigestService) sendDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		recipients, err := s.repo.ClaimDueDigests(ctx, digestBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to claim digests", "error", err)
+			return
+		}
+
+		for _, recipient := range recipients {
+			// claimed digests are not retried, a failure skips this period
+			if err := s.send(ctx, recipient); err != nil {
+				slog.WarnContext(ctx, "failed to send digest", "user_id", recipient.UserID, "error", err)
+			}
+		}
+
+		if len(recipients) < digestBatchSize {
+			return
+		}
+	}
+}
+
+func (s *DigestService) send(ctx context.Context, recipient models.DigestRecipient) error {
+	digest, err := s.buildDigest(ctx, recipient)
+	if err != nil || digest.UnreadCount == 0 {
+		return err
+	}
+
+	subject, body, err := RenderDigest(recipient, digest)
+	if err != nil {
+		return err
+	}
+
+	return s.sender.Send(ctx, EmailMessage{
+		To:      recipient.Email,
+		Subject: subject,
+		Body:    body,
+	})
+}
+
+// buildDigest collects the unread notifications of the period whose type the user wants by email
+func (s *DigestService) buildDigest(ctx context.Context, recipient models.DigestRecipient) (*models.Digest, error) {
+	settings, err := s.repo.GetSettings(ctx, recipient.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	emailed := make(map[string]bool)
+	for _, pref := range settings.Preferences {
+		emailed[pref.NotificationType] = pref.Email
+	}
+
+	notifications, err := s.repo.GetUnreadSince(ctx, recipient.UserID, recipient.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := &models.Digest{}
+	for _, notification := range notifications {
+		preferenceType := models.PreferenceTypeFor(notification.Notification_type)
+		if wanted, ok := emailed[preferenceType]; ok && !wanted {
+			continue
+		}
+
+		LocalizeNotification(&notification, recipient.Language)
+
+		digest.UnreadCount++
+		if preferenceType == models.PreferenceMatches {
+			digest.NewMatchCount++
+		}
+		if len(digest.Notifications) < digestMaxListed {
+			digest.Notifications = append(digest.Notifications, notification)
+		}
+	}
+
+	return digest, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmailMessage is one plain text email
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// EmailSender delivers an email to a single recipient
+type EmailSender interface {
+	Send(ctx context.Context, message EmailMessage) error
+}
+
+// NewEmailSender builds the sender named by EMAIL_BACKEND: "smtp" or "memory".
+// When unset it uses SMTP if SMTP_HOST is configured and the in-memory sender otherwise.
+func NewEmailSender() (EmailSender, error) {
+	backend := strings.ToLower(os.Getenv("EMAIL_BACKEND"))
+	if backend == "" {
+		backend = "memory"
+		if os.Getenv("SMTP_HOST") != "" {
+			backend = "smtp"
+		}
+	}
+
+	switch backend {
+	case "smtp":
+		return NewSMTPSender(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("EMAIL_FROM"))
+	case "memory":
+		return NewMemoryEmailSender(), nil
+	default:
+		return nil, fmt.Errorf("unknown EMAIL_BACKEND %q", backend)
+	}
+}
+
+// SMTPSender sends through an SMTP relay, upgrading to TLS when the server offers it
+type SMTPSender struct {
+	addr string
+	host string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPSender(host, port, username, password, from string) (*SMTPSender, error) {
+	if host == "" || from == "" {
+		return nil, fmt.Errorf("SMTP needs SMTP_HOST and EMAIL_FROM")
+	}
+	if port == "" {
+		port = "587"
+	}
+
+	sender := &SMTPSender{addr: net.JoinHostPort(host, port), host: host, from: from}
+	if username != "" {
+		sender.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return sender, nil
+}
+
+func (s *SMTPSender) Send(ctx context.Context, message EmailMessage) error {
+	body := strings.Join([]string{
+		"From: " + s.from,
+		"To: " + message.To,
+		"Subject: " + message.Subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		message.Body,
+	}, "\r\n")
+
+	// net/smtp takes no context, the caller's deadline is not applied to the exchange
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{message.To}, []byte(body)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// MemoryEmailSender logs emails and keeps them in memory, for tests and local runs without SMTP
+type MemoryEmailSender struct {
+	mu   sync.Mutex
+	sent []EmailMessage
+}
+
+func NewMemoryEmailSender() *MemoryEmailSender {
+	return &MemoryEmailSender{}
+}
+
+func (s *MemoryEmailSender) Send(ctx context.Context, message EmailMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slog.DebugContext(ctx, "email sent", "to", message.To, "subject", message.Subject)
+	s.sent = append(s.sent, message)
+	return nil
+}
+
+// Sent returns a copy of every email sent so far
+func (s *MemoryEmailSender) Sent() []EmailMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]EmailMessage(nil), s.sent...)
+}
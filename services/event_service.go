@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// eventPageSize is how many events a stream reads from the log at a time
+	eventPageSize = 500
+	// events newer than this may have ids below ones already committed, streams re-read them until they are older
+	eventCommitLag = 5 * time.Second
+)
+
+// EventService records per-user events in the event log and wakes the user's open /events streams on this instance.
+// The log is the source of truth, streams read it whenever they are woken and on a timer for events logged by
+// other instances, and clients resume with Last-Event-ID.
+type EventService struct {
+	repo *repository.EventRepository
+
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+func NewEventService(repo *repository.EventRepository) *EventService {
+	return &EventService{
+		repo:        repo,
+		subscribers: make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// Publish appends an event to the user's log and pushes it to their open streams
+func (s *EventService) Publish(ctx context.Context, userID uuid.UUID, eventType string, payload any) error {
+	ctx, span := tracer.Start(ctx, "EventService.Publish")
+	defer span.End()
+
+	event, err := s.repo.AppendEvent(ctx, userID, eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	s.wake(event.UserID)
+	return nil
+}
+
+// PublishOnce is Publish for events the outbox may deliver more than once, repeats of idempotencyKey are dropped
+func (s *EventService) PublishOnce(ctx context.Context, idempotencyKey, userID uuid.UUID, eventType string, payload any) error {
+	ctx, span := tracer.Start(ctx, "EventService.PublishOnce")
+	defer span.End()
+
+	event, err := s.repo.AppendEventOnce(ctx, idempotencyKey, userID, eventType, payload)
+	if err != nil || event == nil {
+		return err
+	}
+
+	s.wake(event.UserID)
+	return nil
+}
+
+// wake tells the user's open streams there is something new in the log. A stream that hasn't read since
+// its last wakeup already has one pending, which covers this event too.
+func (s *EventService) wake(userID uuid.UUID) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for ch := range s.subscribers[userID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// EnqueueToProductConversations queues the event for everyone in a conversation about the product except excludeUserID.
+// The entries are written as part of tx, the outbox delivers them once it commits.
+func (s *EventService) EnqueueToProductConversations(ctx context.Context, tx repository.DBTX, productID, excludeUserID uuid.UUID, eventType string, payload any) error {
+	userIDs, err := s.repo.GetProductConversationUserIDs(ctx, tx, productID, excludeUserID)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]models.OutboxEntry, 0, len(userIDs))
+	for _, userID := range userIDs {
+		entry, err := models.NewUserEventOutboxEntry(userID, eventType, payload)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	return repository.EnqueueOutbox(ctx, tx, entries...)
+}
+
+// Subscribe registers a live stream for the user, the channel receives a wakeup whenever an event is logged
+// for them. Call the returned func when the stream ends.
+func (s *EventService) Subscribe(userID uuid.UUID) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	if s.subscribers[userID] == nil {
+		s.subscribers[userID] = make(map[chan struct{}]struct{})
+	}
+	s.subscribers[userID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.subscribers[userID], ch)
+		if len(s.subscribers[userID]) == 0 {
+			delete(s.subscribers, userID)
+		}
+	}
+}
+
+// EventsAfter returns the next page of events logged after lastEventID, oldest first. It is empty once the
+// stream has caught up.
+func (s *EventService) EventsAfter(ctx context.Context, userID uuid.UUID, lastEventID int64) ([]models.UserEvent, error) {
+	return s.repo.GetEventsAfter(ctx, userID, lastEventID, eventPageSize)
+}
+
+// EventCursor is how far a stream has read the user's log. Event ids are taken before their transaction commits
+// so a lower id can show up after a higher one was sent, ids logged within eventCommitLag are read again until they
+// are older and the ones already sent are skipped.
+type EventCursor struct {
+	settled      int64
+	sent         map[int64]struct{}
+	settleBefore time.Time
+}
+
+// NewEventCursor starts a cursor after lastEventID, the id a client resumes from
+func NewEventCursor(lastEventID int64) *EventCursor {
+	return &EventCursor{settled: lastEventID, sent: make(map[int64]struct{})}
+}
+
+// Rewind starts another read of the log and returns the id to read after, the oldest event that may still be missing
+func (s *EventService) Rewind(ctx context.Context, cursor *EventCursor) (int64, error) {
+	now, err := s.repo.Now(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor.settleBefore = now.Add(-eventCommitLag)
+	return cursor.settled, nil
+}
+
+// Unsent returns the events not sent yet and records them as sent. Events logged before the commit lag
+// move the cursor past them, nothing below their id is read again.
+func (c *EventCursor) Unsent(events []models.UserEvent) []models.UserEvent {
+	unsent := make([]models.UserEvent, 0, len(events))
+
+	for _, event := range events {
+		if _, ok := c.sent[event.ID]; !ok && event.ID > c.settled {
+			unsent = append(unsent, event)
+			c.sent[event.ID] = struct{}{}
+		}
+		if event.CreatedAt.Before(c.settleBefore) && event.ID > c.settled {
+			c.settled = event.ID
+		}
+	}
+
+	for id := range c.sent {
+		if id <= c.settled {
+			delete(c.sent, id)
+		}
+	}
+
+	return unsent
+}
+
+// RunRetention prunes events older than maxAge once an hour until ctx is cancelled
+func (s *EventService) RunRetention(ctx context.Context, maxAge time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		removed, err := s.repo.DeleteEventsBefore(ctx, time.Now().Add(-maxAge))
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to prune event log", "error", err)
+		} else if removed > 0 {
+			slog.InfoContext(ctx, "pruned event log", "removed", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"log/slog"
+	"math"
+	"os"
+	"postswapapi/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FeedWeights tune the For You ranking. Each matching signal adds its weight to a product's score and
+// freshness adds up to Freshness, halving every FreshnessHalfLife. MaxSellerRun caps how many products
+// of one seller can follow each other.
+type FeedWeights struct {
+	Want              float64
+	Wishlist          float64
+	Reciprocal        float64
+	Nearby            float64
+	Freshness         float64
+	FreshnessHalfLife time.Duration
+	MaxSellerRun      int
+}
+
+// DefaultFeedWeights favour what the viewer asked for over what is merely close or new
+func DefaultFeedWeights() FeedWeights {
+	return FeedWeights{
+		Want:              3,
+		Wishlist:          2,
+		Reciprocal:        2,
+		Nearby:            1.5,
+		Freshness:         2,
+		FreshnessHalfLife: 72 * time.Hour,
+		MaxSellerRun:      2,
+	}
+}
+
+// FeedWeightsFromEnv starts from the defaults and applies FEED_WEIGHT_WANT, FEED_WEIGHT_WISHLIST,
+// FEED_WEIGHT_RECIPROCAL, FEED_WEIGHT_NEARBY, FEED_WEIGHT_FRESHNESS, FEED_FRESHNESS_HALF_LIFE (a duration)
+// and FEED_MAX_SELLER_RUN. Invalid values are logged and ignored.
+func FeedWeightsFromEnv() FeedWeights {
+	weights := DefaultFeedWeights()
+
+	for name, weight := range map[string]*float64{
+		"FEED_WEIGHT_WANT":       &weights.Want,
+		"FEED_WEIGHT_WISHLIST":   &weights.Wishlist,
+		"FEED_WEIGHT_RECIPROCAL": &weights.Reciprocal,
+		"FEED_WEIGHT_NEARBY":     &weights.Nearby,
+		"FEED_WEIGHT_FRESHNESS":  &weights.Freshness,
+	} {
+		raw := os.Getenv(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || math.IsInf(parsed, 0) {
+			slog.Warn("invalid feed weight, using the default", "name", name, "value", raw, "default", *weight)
+			continue
+		}
+		*weight = parsed
+	}
+
+	if raw := os.Getenv("FEED_FRESHNESS_HALF_LIFE"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			slog.Warn("invalid FEED_FRESHNESS_HALF_LIFE, using the default", "value", raw, "default", weights.FreshnessHalfLife)
+		} else {
+			weights.FreshnessHalfLife = parsed
+		}
+	}
+
+	if raw := os.Getenv("FEED_MAX_SELLER_RUN"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			slog.Warn("invalid FEED_MAX_SELLER_RUN, using the default", "value", raw, "default", weights.MaxSellerRun)
+		} else {
+			weights.MaxSellerRun = parsed
+		}
+	}
+
+	return weights
+}
+
+// ScoreFeedCandidate scores one candidate and fills in the reasons it was boosted
+func ScoreFeedCandidate(candidate *models.FeedCandidate, weights FeedWeights, now time.Time) {
+	score := 0.0
+	reasons := []string{}
+
+	if candidate.MatchesWant {
+		score += weights.Want
+		reasons = append(reasons, models.FeedReasonMatchesWants)
+	}
+	if candidate.MatchesWishlist {
+		score += weights.Wishlist
+		reasons = append(reasons, models.FeedReasonWishlist)
+	}
+	if candidate.SellerWantsViewerCategory {
+		score += weights.Reciprocal
+		reasons = append(reasons, models.FeedReasonReciprocal)
+	}
+	if candidate.Nearby {
+		score += weights.Nearby
+		reasons = append(reasons, models.FeedReasonNearby)
+	}
+
+	if weights.FreshnessHalfLife > 0 {
+		age := max(now.Sub(candidate.Item.Created_at), 0)
+		score += weights.Freshness * math.Exp2(-float64(age)/float64(weights.FreshnessHalfLife))
+	}
+
+	candidate.Score = score
+	candidate.Item.Reasons = reasons
+}
+
+// RankFeed scores candidates and orders them best first, then spreads sellers out so no more than
+// weights.MaxSellerRun products of one seller follow each other while anyone else's are left.
+// It only depends on its arguments: the same candidates, weights and now always give the same order.
+func RankFeed(candidates []models.FeedCandidate, weights FeedWeights, now time.Time) []models.FeedCandidate {
+	scored := make([]models.FeedCandidate, len(candidates))
+	copy(scored, candidates)
+	for i := range scored {
+		ScoreFeedCandidate(&scored[i], weights, now)
+	}
+
+	// ties go to the newer product, then the product ID so the order never depends on the input order
+	sort.SliceStable(scored, func(i, j int) bool {
+		a, b := scored[i], scored[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Item.Created_at.Equal(b.Item.Created_at) {
+			return a.Item.Created_at.After(b.Item.Created_at)
+		}
+		return a.Item.Product_ID.String() < b.Item.Product_ID.String()
+	})
+
+	if weights.MaxSellerRun < 1 {
+		return scored
+	}
+
+	ranked := make([]models.FeedCandidate, 0, len(scored))
+	remaining := scored
+	var lastSeller uuid.UUID
+	run := 0
+
+	for len(remaining) > 0 {
+		// the best candidate that doesn't extend the current seller's run too far, or the best overall if only they are left
+		pick := 0
+		if run >= weights.MaxSellerRun {
+			for i, candidate := range remaining {
+				if candidate.SellerID != lastSeller {
+					pick = i
+					break
+				}
+			}
+		}
+
+		chosen := remaining[pick]
+		remaining = append(remaining[:pick:pick], remaining[pick+1:]...)
+		ranked = append(ranked, chosen)
+
+		if chosen.SellerID == lastSeller {
+			run++
+		} else {
+			lastSeller, run = chosen.SellerID, 1
+		}
+	}
+
+	return ranked
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"postswapapi/models"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// rankingFixture is testdata/ranking.json: candidates with the signals they match and the order RankFeed
+// must put them in. Fixtures name products and sellers, names are turned into stable IDs.
+type rankingFixture struct {
+	Now     time.Time      `json:"now"`
+	Weights fixtureWeights `json:"weights"`
+	Cases   []rankingCase  `json:"cases"`
+}
+
+// fixtureWeights override the default weights, fields left out keep the default
+type fixtureWeights struct {
+	Want              *float64 `json:"want"`
+	Wishlist          *float64 `json:"wishlist"`
+	Reciprocal        *float64 `json:"reciprocal"`
+	Nearby            *float64 `json:"nearby"`
+	Freshness         *float64 `json:"freshness"`
+	FreshnessHalfLife string   `json:"freshness_half_life"`
+	MaxSellerRun      *int     `json:"max_seller_run"`
+}
+
+type rankingCase struct {
+	Name       string             `json:"name"`
+	Weights    fixtureWeights     `json:"weights"`
+	Candidates []fixtureCandidate `json:"candidates"`
+	// product names best first
+	Expected []string `json:"expected"`
+	// reasons a product must be given, products left out aren't checked
+	ExpectedReasons map[string][]string `json:"expected_reasons"`
+}
+
+type fixtureCandidate struct {
+	Product string `json:"product"`
+	Seller  string `json:"seller"`
+	// how long before now the product was listed, e.g. "36h"
+	Age                       string `json:"age"`
+	MatchesWant               bool   `json:"matches_want"`
+	MatchesWishlist           bool   `json:"matches_wishlist"`
+	SellerWantsViewerCategory bool   `json:"seller_wants_viewer_category"`
+	Nearby                    bool   `json:"nearby"`
+}
+
+func TestRankFeed(t *testing.T) {
+	raw, err := os.ReadFile("testdata/ranking.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	var fixture rankingFixture
+	if err := json.Unmarshal(raw, &fixture); err != nil {
+		t.Fatalf("failed to parse fixture: %v", err)
+	}
+
+	base, err := fixture.Weights.apply(DefaultFeedWeights())
+	if err != nil {
+		t.Fatalf("invalid fixture weights: %v", err)
+	}
+
+	for _, tc := range fixture.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			weights, err := tc.Weights.apply(base)
+			if err != nil {
+				t.Fatalf("invalid weights: %v", err)
+			}
+
+			candidates, names, err := tc.candidates(fixture.Now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ranked := RankFeed(candidates, weights, fixture.Now)
+
+			got := make([]string, len(ranked))
+			for i, candidate := range ranked {
+				got[i] = names[candidate.Item.Product_ID]
+			}
+			if len(tc.Expected) > 0 && !slices.Equal(got, tc.Expected) {
+				t.Errorf("order %v, expected %v", got, tc.Expected)
+			}
+
+			for _, candidate := range ranked {
+				name := names[candidate.Item.Product_ID]
+				expected, ok := tc.ExpectedReasons[name]
+				if ok && !slices.Equal(candidate.Item.Reasons, expected) {
+					t.Errorf("%s reasons %v, expected %v", name, candidate.Item.Reasons, expected)
+				}
+			}
+
+			if t.Failed() {
+				for i, candidate := range ranked {
+					t.Logf("%2d. %-12s seller=%-10s score=%.3f reasons=%s", i+1, names[candidate.Item.Product_ID],
+						names[candidate.SellerID], candidate.Score, strings.Join(candidate.Item.Reasons, ","))
+				}
+			}
+		})
+	}
+}
+
+func (w fixtureWeights) apply(weights FeedWeights) (FeedWeights, error) {
+	for _, field := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{w.Want, &weights.Want},
+		{w.Wishlist, &weights.Wishlist},
+		{w.Reciprocal, &weights.Reciprocal},
+		{w.Nearby, &weights.Nearby},
+		{w.Freshness, &weights.Freshness},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+
+	if w.FreshnessHalfLife != "" {
+		halfLife, err := time.ParseDuration(w.FreshnessHalfLife)
+		if err != nil {
+			return weights, err
+		}
+		weights.FreshnessHalfLife = halfLife
+	}
+
+	if w.MaxSellerRun != nil {
+		weights.MaxSellerRun = *w.MaxSellerRun
+	}
+
+	return weights, nil
+}
+
+// candidates builds the ranking input and a lookup from generated IDs back to fixture names
+func (c rankingCase) candidates(now time.Time) ([]models.FeedCandidate, map[uuid.UUID]string, error) {
+	names := map[uuid.UUID]string{}
+	candidates := make([]models.FeedCandidate, 0, len(c.Candidates))
+
+	for _, fc := range c.Candidates {
+		age, err := time.ParseDuration(fc.Age)
+		if err != nil {
+			return nil, nil, fmt.Errorf("product %s: invalid age: %w", fc.Product, err)
+		}
+
+		productID, sellerID := nameID("product", fc.Product), nameID("seller", fc.Seller)
+		names[productID], names[sellerID] = fc.Product, fc.Seller
+
+		candidates = append(candidates, models.FeedCandidate{
+			Item: models.FeedItem{
+				Product_ID: productID,
+				Title:      fc.Product,
+				Created_at: now.Add(-age),
+			},
+			SellerID:                  sellerID,
+			MatchesWant:               fc.MatchesWant,
+			MatchesWishlist:           fc.MatchesWishlist,
+			SellerWantsViewerCategory: fc.SellerWantsViewerCategory,
+			Nearby:                    fc.Nearby,
+		})
+	}
+
+	return candidates, names, nil
+}
+
+// nameID turns a fixture name into the same UUID on every run
+func nameID(kind, name string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(kind+":"+name))
+}
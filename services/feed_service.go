@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// how many of the newest active products are ranked for each For You request, older products only show up in the latest feed
+const feedCandidatePool = 500
+
+// FeedService ranks the For You feed. Candidates come from the database with the signals they match and are
+// ranked in memory by RankFeed, so the same data and weights always give the same pages.
+type FeedService struct {
+	repo    *repository.FeedRepository
+	weights FeedWeights
+	now     func() time.Time
+}
+
+func NewFeedService(repo *repository.FeedRepository, weights FeedWeights) *FeedService {
+	return &FeedService{repo: repo, weights: weights, now: time.Now}
+}
+
+// ForYou returns a page of the viewer's ranked feed and the cursor of the next page, nil on the last one.
+// Without a cursor a new ranking starts now at offset, with one the page comes from the ranking the cursor pins,
+// so pages neither repeat nor skip products as time passes and new products are listed. A product taken down
+// in between shifts the ones after it by one. viewerID can be uuid.Nil for anonymous viewers, whose feed is
+// ranked by freshness with sellers spread out.
+func (s *FeedService) ForYou(ctx context.Context, viewerID uuid.UUID, category string, limit, offset int, cursor *models.FeedCursor) ([]models.FeedItem, *models.FeedCursor, error) {
+	session := models.FeedCursor{RankedAt: s.now(), Offset: offset}
+	if cursor != nil {
+		session = *cursor
+	}
+
+	candidates, err := s.repo.GetFeedCandidates(ctx, viewerID, category, session.Oldest, session.RankedAt, feedCandidatePool)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a full pool is cut off at its oldest product, so later pages rank the same products
+	if cursor == nil && len(candidates) == feedCandidatePool {
+		session.Oldest = candidates[len(candidates)-1].Item.Created_at
+	}
+
+	ranked := RankFeed(candidates, s.weights, session.RankedAt)
+
+	items := []models.FeedItem{}
+	if session.Offset >= len(ranked) {
+		return items, nil, nil
+	}
+
+	end := min(session.Offset+limit, len(ranked))
+	for _, candidate := range ranked[session.Offset:end] {
+		items = append(items, candidate.Item)
+	}
+
+	if end >= len(ranked) {
+		return items, nil, nil
+	}
+
+	next := session
+	next.Offset = end
+	return items, &next, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// how long an upload can wait to be attached to a product, avatar or message
+	unattachedMediaTTL = 24 * time.Hour
+	mediaCleanupBatch  = 100
+)
+
+// MediaCleanup deletes uploads that were never attached to anything within a day, confirmed or not
+type MediaCleanup struct {
+	media *MediaService
+}
+
+func NewMediaCleanup(media *MediaService) *MediaCleanup {
+	return &MediaCleanup{media: media}
+}
+
+// Run cleans up once an hour until ctx is cancelled
+func (c *MediaCleanup) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		c.cleanup(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *MediaCleanup) cleanup(ctx context.Context) {
+	var total int
+	for ctx.Err() == nil {
+		// rows go first so nothing can attach media whose files are being deleted
+		deleted, err := c.media.repo.DeleteUnattachedBefore(ctx, time.Now().Add(-unattachedMediaTTL), mediaCleanupBatch)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete unattached media", "error", err)
+			break
+		}
+
+		for _, media := range deleted {
+			if err := c.media.deleteStored(ctx, media.StorageKey); err != nil {
+				slog.WarnContext(ctx, "failed to delete unattached media from storage", "storage_key", media.StorageKey, "error", err)
+			}
+		}
+
+		total += len(deleted)
+		if len(deleted) < mediaCleanupBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		slog.InfoContext(ctx, "deleted unattached media", "deleted", total)
+	}
+
+	c.cleanupPending(ctx)
+}
+
+// cleanupPending deletes files uploaded under signed keys that were never confirmed,
+// they are the raw upload with its metadata still in it
+func (c *MediaCleanup) cleanupPending(ctx context.Context) {
+	var total int
+	for ctx.Err() == nil {
+		keys, err := c.media.repo.DeletePendingUploadsBefore(ctx, time.Now().Add(-unattachedMediaTTL), mediaCleanupBatch)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete unconfirmed uploads", "error", err)
+			break
+		}
+
+		for _, key := range keys {
+			if err := c.media.deleteStored(ctx, key); err != nil {
+				slog.WarnContext(ctx, "failed to delete unconfirmed upload from storage", "storage_key", key, "error", err)
+			}
+		}
+
+		total += len(keys)
+		if len(keys) < mediaCleanupBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		slog.InfoContext(ctx, "deleted unconfirmed uploads", "deleted", total)
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"postswapapi/metrics"
+	"postswapapi/models"
+	"strconv"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/admin"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const cloudinaryUploadEndpoint = "https://api.cloudinary.com/v1_1/%s/image/upload"
+
+// CloudinaryStore keeps media in Cloudinary, the storage key is the public ID
+type CloudinaryStore struct {
+	cld       *cloudinary.Cloudinary
+	cloudName string
+	apiKey    string
+	apiSecret string
+}
+
+func NewCloudinaryStore(cloudName, apiKey, apiSecret string) (*CloudinaryStore, error) {
+	if cloudName == "" || apiKey == "" || apiSecret == "" {
+		return nil, fmt.Errorf("cloudinary credentials not configured")
+	}
+
+	cld, err := cloudinary.NewFromParams(cloudName, apiKey, apiSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize cloudinary: %w", err)
+	}
+
+	return &CloudinaryStore{cld: cld, cloudName: cloudName, apiKey: apiKey, apiSecret: apiSecret}, nil
+}
+
+func (s *CloudinaryStore) Name() string {
+	return "cloudinary"
+}
+
+// SignUpload signs a Cloudinary upload pinned to the key that can't overwrite it, Cloudinary accepts the
+// signature for an hour
+func (s *CloudinaryStore) SignUpload(ctx context.Context, key, contentType string, expiresAt time.Time) (*models.SignedUpload, error) {
+	timestamp := strconv.FormatInt(expiresAt.Add(-mediaUploadTTL).Unix(), 10)
+
+	params := url.Values{
+		"overwrite": {"false"},
+		"public_id": {key},
+		"timestamp": {timestamp},
+	}
+	signature, err := api.SignParameters(params, s.apiSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign upload: %w", err)
+	}
+
+	return &models.SignedUpload{
+		StorageKey: key,
+		UploadURL:  fmt.Sprintf(cloudinaryUploadEndpoint, s.cloudName),
+		Method:     "POST",
+		FileField:  "file",
+		Fields: map[string]string{
+			"api_key":   s.apiKey,
+			"overwrite": "false",
+			"public_id": key,
+			"timestamp": timestamp,
+			"signature": signature,
+		},
+		MaxBytes:  models.MaxMediaBytes,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+func (s *CloudinaryStore) Put(ctx context.Context, key, contentType string, body io.Reader) (*StoredObject, error) {
+	spanCtx, span := otel.Tracer("postswapapi/services").Start(ctx, "cloudinary.upload",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("media.key", key)))
+	defer span.End()
+
+	start := time.Now()
+	result, err := s.cld.Upload.Upload(spanCtx, body, uploader.UploadParams{
+		PublicID:       key,
+		ResourceType:   "image",
+		Transformation: "q_auto,f_auto", // Auto quality and format
+		Overwrite:      api.Bool(true),
+		Invalidate:     api.Bool(true),
+	})
+	if err == nil && result.Error.Message != "" {
+		err = fmt.Errorf("cloudinary: %s", result.Error.Message)
+	}
+	if err != nil {
+		metrics.CloudinaryUploadDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "cloudinary upload failed")
+		return nil, fmt.Errorf("failed to upload to cloudinary: %w", err)
+	}
+	metrics.CloudinaryUploadDuration.WithLabelValues("success").Observe(time.Since(start).Seconds())
+
+	return &StoredObject{
+		URL:         result.SecureURL,
+		ContentType: cloudinaryContentType(result.Format),
+		Bytes:       int64(result.Bytes),
+		Width:       result.Width,
+		Height:      result.Height,
+	}, nil
+}
+
+func (s *CloudinaryStore) Stat(ctx context.Context, key string) (*StoredObject, error) {
+	result, err := s.cld.Admin.Asset(ctx, admin.AssetParams{PublicID: key})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up cloudinary asset: %w", err)
+	}
+	if result.Error.Message != "" {
+		return nil, ErrMediaNotFound
+	}
+
+	return &StoredObject{
+		URL:         result.SecureURL,
+		ContentType: cloudinaryContentType(result.Format),
+		Bytes:       int64(result.Bytes),
+		Width:       result.Width,
+		Height:      result.Height,
+	}, nil
+}
+
+// Get downloads the asset from its delivery URL
+func (s *CloudinaryStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, object.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build cloudinary download: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from cloudinary: %w", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrMediaNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download from cloudinary: status %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+func (s *CloudinaryStore) Delete(ctx context.Context, key string) error {
+	result, err := s.cld.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: key})
+	if err != nil {
+		return fmt.Errorf("failed to delete cloudinary asset: %w", err)
+	}
+	if result.Error.Message != "" {
+		return fmt.Errorf("failed to delete cloudinary asset: %s", result.Error.Message)
+	}
+	return nil
+}
+
+// cloudinaryContentType maps a Cloudinary format to a content type
+func cloudinaryContentType(format string) string {
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	return "image/" + format
+}
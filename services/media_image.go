@@ -0,0 +1,293 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"postswapapi/models"
+	"runtime"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gen2brain/heic"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	originalJPEGQuality = 90
+	variantJPEGQuality  = 82
+)
+
+// decoding a large image takes a few hundred MB, this keeps concurrent uploads from exhausting memory
+var imageProcessing = make(chan struct{}, runtime.NumCPU())
+
+type imageCodec struct {
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+}
+
+// codecs for the types in models.AllowedMediaTypes
+var imageCodecs = map[string]imageCodec{
+	"image/jpeg": {jpeg.Decode, jpeg.DecodeConfig},
+	"image/png":  {png.Decode, png.DecodeConfig},
+	"image/webp": {webp.Decode, webp.DecodeConfig},
+	"image/heic": {heic.Decode, heic.DecodeConfig},
+}
+
+// ProcessedImage is an upload that decoded as an image within the limits. Data is the image re-encoded without
+// its metadata, EXIF location included, and Variants holds a JPEG for each of models.MediaVariantSizes.
+// Hash is a perceptual hash of the picture, zero when it has too little detail to compare.
+type ProcessedImage struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	Variants    map[string][]byte
+	Hash        uint64
+}
+
+// processImage checks an upload by its content and decodes it, rejecting anything that isn't an allowed image.
+// JPEG, WebP and HEIC images are re-encoded as JPEG, PNG images and images with transparency as PNG.
+func processImage(data []byte) (*ProcessedImage, error) {
+	if len(data) > models.MaxMediaBytes {
+		return nil, invalidMedia("file too large, max 5MB")
+	}
+
+	contentType := sniffImageType(data)
+	codec, ok := imageCodecs[contentType]
+	if !ok {
+		return nil, invalidMedia("image must be a JPEG, PNG, WebP or HEIC")
+	}
+
+	// check the dimensions from the header before decoding so huge images aren't allocated
+	config, err := codec.decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalidMedia("image could not be read, it may be corrupt")
+	}
+	if err := checkImageDimensions(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	imageProcessing <- struct{}{}
+	defer func() { <-imageProcessing }()
+
+	img, err := codec.decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalidMedia("image could not be read, it may be corrupt")
+	}
+
+	// re-encoding drops the EXIF orientation along with everything else, so apply it to the pixels first
+	if contentType == "image/jpeg" {
+		img = orientImage(img, jpegOrientation(data))
+	}
+
+	processed := &ProcessedImage{
+		Width:    img.Bounds().Dx(),
+		Height:   img.Bounds().Dy(),
+		Variants: make(map[string][]byte, len(models.MediaVariantSizes)),
+	}
+
+	var buf bytes.Buffer
+	if contentType == "image/png" || !isOpaque(img) {
+		processed.ContentType = "image/png"
+		err = png.Encode(&buf, img)
+	} else {
+		processed.ContentType = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: originalJPEGQuality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	processed.Data = buf.Bytes()
+
+	for variant, size := range models.MediaVariantSizes {
+		resized := scaleDown(img, size)
+		if variant == models.MediaVariantThumbnail {
+			processed.Hash = differenceHash(resized)
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: variantJPEGQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode image variant: %w", err)
+		}
+		processed.Variants[variant] = buf.Bytes()
+	}
+
+	return processed, nil
+}
+
+// sniffImageType returns the content type of data from its bytes, HEIF is reported as HEIC
+func sniffImageType(data []byte) string {
+	detected := mimetype.Detect(data)
+	switch {
+	case detected.Is("image/heic"), detected.Is("image/heif"):
+		return "image/heic"
+	default:
+		return detected.String()
+	}
+}
+
+func checkImageDimensions(width, height int) error {
+	switch {
+	case width < models.MinImageDimension || height < models.MinImageDimension:
+		return invalidMedia(fmt.Sprintf("image too small, min %dx%d pixels", models.MinImageDimension, models.MinImageDimension))
+	case width > models.MaxImageDimension || height > models.MaxImageDimension || width*height > models.MaxImagePixels:
+		return invalidMedia(fmt.Sprintf("image too large, max %d pixels on a side and %d megapixels", models.MaxImageDimension, models.MaxImagePixels/1_000_000))
+	}
+	return nil
+}
+
+// scaleDown scales img down so its longest edge is at most size, on a white background
+func scaleDown(img image.Image, size int) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if longest := max(width, height); longest > size {
+		width, height = max(1, width*size/longest), max(1, height*size/longest)
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(resized, resized.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
+	return resized
+}
+
+// differenceHash is a 64 bit dHash: the image shrunk to 9x8 in grayscale, one bit per pair of neighbouring pixels
+// set when the left one is brighter. Re-encoded, resized or slightly edited copies of a photo differ in a few bits.
+func differenceHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.CatmullRom.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := range 8 {
+		for x := range 8 {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func isOpaque(img image.Image) bool {
+	opaque, ok := img.(interface{ Opaque() bool })
+	return ok && opaque.Opaque()
+}
+
+// jpegOrientation reads the EXIF orientation of a JPEG, 1 (as stored) when it has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		// EXIF is in the header, once the image data starts there is none
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		i += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation finds the orientation tag in the first IFD of EXIF data
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := range entries {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 1
+		}
+	}
+
+	return 1
+}
+
+// orientImage flips and rotates img so it displays upright without an EXIF orientation
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	// orientations 5 to 8 turn the image on its side
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := range height {
+		for x := range width {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = width-1-x, y
+			case 3: // upside down
+				dx, dy = width-1-x, height-1-y
+			case 4: // upside down and mirrored
+				dx, dy = x, height-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotate clockwise
+				dx, dy = height-1-y, x
+			case 7: // transversed
+				dx, dy = height-1-y, width-1-x
+			case 8: // rotate counterclockwise
+				dx, dy = y, width-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
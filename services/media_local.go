@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"postswapapi/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMediaDir       = "media"
+	defaultMediaPublicURL = "http://localhost:8080"
+	// routes the API serves local uploads and files on
+	localUploadPath = "/pointSwapApi/v1/media/local/"
+	localFilesPath  = "/pointSwapApi/v1/media/files/"
+)
+
+// ErrInvalidUploadSignature is returned for local uploads whose signature is wrong or expired
+var ErrInvalidUploadSignature = errors.New("upload signature is invalid or expired")
+
+// LocalMediaStore keeps media on the local filesystem for development and single instance deployments.
+// Clients PUT the file to the API's signed upload URL, files are served back from publicURL.
+type LocalMediaStore struct {
+	dir       string
+	publicURL string
+	secret    []byte
+}
+
+func NewLocalMediaStore(dir, publicURL, secret string) (*LocalMediaStore, error) {
+	if dir == "" {
+		dir = defaultMediaDir
+	}
+	if publicURL == "" {
+		publicURL = defaultMediaPublicURL
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	key := []byte(secret)
+	if secret == "" {
+		// uploads signed before a restart stop working, set MEDIA_SIGNING_SECRET to keep them
+		slog.Warn("MEDIA_SIGNING_SECRET not set, using a random secret")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate media signing secret: %w", err)
+		}
+	}
+
+	return &LocalMediaStore{dir: dir, publicURL: strings.TrimRight(publicURL, "/"), secret: key}, nil
+}
+
+func (s *LocalMediaStore) Name() string {
+	return "local"
+}
+
+func (s *LocalMediaStore) SignUpload(ctx context.Context, key, contentType string, expiresAt time.Time) (*models.SignedUpload, error) {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{
+		"content_type": {contentType},
+		"expires":      {expires},
+		"signature":    {s.sign(key, contentType, expires)},
+	}
+
+	return &models.SignedUpload{
+		StorageKey: key,
+		UploadURL:  s.publicURL + localUploadPath + key + "?" + query.Encode(),
+		Method:     "PUT",
+		Fields:     map[string]string{},
+		MaxBytes:   models.MaxMediaBytes,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// ReceiveUpload stores the body of a signed upload, rejecting bad signatures, other content types and oversized files.
+// It never replaces a file, ErrMediaExists is returned once the key has been uploaded to.
+func (s *LocalMediaStore) ReceiveUpload(ctx context.Context, key, contentType, expires, signature string, body io.Reader) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrInvalidUploadSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, contentType, expires))) {
+		return ErrInvalidUploadSignature
+	}
+
+	_, err = s.write(key, body, false)
+	return err
+}
+
+func (s *LocalMediaStore) Put(ctx context.Context, key, contentType string, body io.Reader) (*StoredObject, error) {
+	if _, err := s.write(key, body, true); err != nil {
+		return nil, err
+	}
+	return s.Stat(ctx, key)
+}
+
+func (s *LocalMediaStore) Stat(ctx context.Context, key string) (*StoredObject, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat media: %w", err)
+	}
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+
+	object := &StoredObject{
+		URL:         s.publicURL + localFilesPath + key,
+		ContentType: http.DetectContentType(head[:n]),
+		Bytes:       info.Size(),
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err == nil {
+		if config, _, err := image.DecodeConfig(file); err == nil {
+			object.Width, object.Height = config.Width, config.Height
+		}
+	}
+
+	return object, nil
+}
+
+func (s *LocalMediaStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete media: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalMediaStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.Open(key)
+}
+
+// Open returns a stored file for serving
+func (s *LocalMediaStore) Open(key string) (*os.File, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMediaNotFound
+	}
+	return file, err
+}
+
+// write stores body under key, keeping at most MaxMediaBytes. Without replace it fails with ErrMediaExists when
+// there is a file under key already.
+func (s *LocalMediaStore) write(key string, body io.Reader, replace bool) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create media file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	written, err := io.Copy(file, io.LimitReader(body, models.MaxMediaBytes+1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to write media: %w", err)
+	}
+	if written > models.MaxMediaBytes {
+		return 0, ErrMediaTooLarge
+	}
+
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write media: %w", err)
+	}
+	if replace {
+		err = os.Rename(file.Name(), path)
+	} else {
+		// a link is created only if nothing is at path, unlike a rename
+		err = os.Link(file.Name(), path)
+		if errors.Is(err, os.ErrExist) {
+			return 0, ErrMediaExists
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to store media: %w", err)
+	}
+
+	return written, nil
+}
+
+// path maps a key to a file, only keys the API hands out and their variants are accepted so nothing escapes dir
+func (s *LocalMediaStore) path(key string) (string, error) {
+	if !mediaObjectPattern.MatchString(key) {
+		return "", ErrMediaNotFound
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalMediaStore) sign(key, contentType, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + contentType + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
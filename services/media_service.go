@@ -0,0 +1,238 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"postswapapi/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MediaService hands out signed uploads and records files once their uploader confirms them
+type MediaService struct {
+	repo  *repository.MediaRepository
+	store MediaStore
+}
+
+func NewMediaService(repo *repository.MediaRepository, store MediaStore) *MediaService {
+	return &MediaService{repo: repo, store: store}
+}
+
+// Store returns the backend files are kept in
+func (s *MediaService) Store() MediaStore {
+	return s.store
+}
+
+// SignUpload returns parameters for uploading one file straight to storage, under a key owned by the user.
+// The key is recorded as pending so a file uploaded under it is deleted if it is never confirmed.
+func (s *MediaService) SignUpload(ctx context.Context, userID uuid.UUID, purpose, contentType string) (*models.SignedUpload, error) {
+	key := newMediaKey(purpose, userID)
+	if err := s.repo.CreatePendingUpload(ctx, key, userID); err != nil {
+		return nil, utils.NewInternal("failed to sign upload", err)
+	}
+
+	upload, err := s.store.SignUpload(ctx, key, contentType, time.Now().Add(mediaUploadTTL))
+	if err != nil {
+		return nil, utils.NewInternal("failed to sign upload", err)
+	}
+	return upload, nil
+}
+
+// Confirm records a file the user uploaded with SignUpload. The key must be one handed to this user for the purpose.
+// The file is checked and processed like Upload and the stripped copy is stored where the client can't write,
+// the upload itself is replaced with it too so its metadata isn't kept. Files that aren't an allowed image are
+// deleted from storage instead.
+func (s *MediaService) Confirm(ctx context.Context, userID uuid.UUID, storageKey, purpose string) (*models.Media, error) {
+	folder, ownerID, ok := parseMediaKey(storageKey)
+	if !ok {
+		return nil, utils.NewValidation("invalid storage key", utils.FieldError{
+			Field: "storage_key", Rule: "storage_key", Message: "use the storage_key returned when the upload was signed",
+		})
+	}
+	if ownerID != userID || folder != models.MediaFolder(purpose) {
+		return nil, utils.NewForbidden("this upload was not signed for you")
+	}
+
+	existing, err := s.repo.GetMediaByKey(ctx, storageKey)
+	if err == nil {
+		return existing, nil
+	}
+	if !utils.IsCode(err, utils.CodeNotFound) {
+		return nil, err
+	}
+
+	object, err := s.store.Stat(ctx, storageKey)
+	if errors.Is(err, ErrMediaNotFound) {
+		return nil, utils.NewNotFound("upload not found, upload the file before confirming it")
+	}
+	if err != nil {
+		return nil, utils.NewInternal("failed to check upload", err)
+	}
+	if object.Bytes > models.MaxMediaBytes {
+		s.deleteRejected(ctx, storageKey)
+		return nil, invalidMedia("file too large, max 5MB")
+	}
+
+	file, err := s.store.Get(ctx, storageKey)
+	if err != nil {
+		return nil, utils.NewInternal("failed to read upload", err)
+	}
+	defer file.Close()
+
+	data, err := readMedia(file)
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := processImage(data)
+	if err != nil {
+		if utils.IsCode(err, utils.CodeValidation) {
+			s.deleteRejected(ctx, storageKey)
+		}
+		return nil, err
+	}
+
+	media, err := s.save(ctx, userID, storageKey, purpose, processed)
+	if err != nil {
+		return nil, err
+	}
+
+	// the signed key keeps a file so the store refuses uploads to it until the signature expires
+	if _, err := s.store.Put(ctx, storageKey, processed.ContentType, bytes.NewReader(processed.Data)); err != nil {
+		slog.WarnContext(ctx, "failed to strip confirmed upload", "storage_key", storageKey, "error", err)
+	}
+
+	return media, nil
+}
+
+// Upload checks a file the API received itself, stores it with its variants and records it
+func (s *MediaService) Upload(ctx context.Context, userID uuid.UUID, purpose string, body io.Reader) (*models.Media, error) {
+	data, err := readMedia(body)
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := processImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(ctx, userID, newMediaKey(purpose, userID), purpose, processed)
+}
+
+// Attach resolves refs to the user's uploads and marks them in use so they aren't garbage collected.
+// Each ref is a media ID or the URL of an upload, the result is in the same order. Anything that isn't
+// one of the user's uploads, external URLs included, is rejected with a validation error on field.
+// tx is the transaction saving what the uploads are attached to.
+func (s *MediaService) Attach(ctx context.Context, tx repository.DBTX, userID uuid.UUID, field string, refs []string) ([]models.Media, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	attached, err := s.repo.AttachMedia(ctx, tx, userID, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	byRef := make(map[string]models.Media, len(attached)*2)
+	for _, media := range attached {
+		byRef[media.MediaID.String()] = media
+		byRef[media.URL] = media
+	}
+
+	resolved := make([]models.Media, 0, len(refs))
+	for _, ref := range refs {
+		media, ok := byRef[ref]
+		if !ok {
+			message := "must be the media_id or url of one of your uploads"
+			return nil, utils.NewValidation(field+" "+message, utils.FieldError{Field: field, Rule: "owned_media", Message: message})
+		}
+		resolved = append(resolved, media)
+	}
+
+	return resolved, nil
+}
+
+// deleteStored removes the upload under storageKey and its variants from storage
+func (s *MediaService) deleteStored(ctx context.Context, storageKey string) error {
+	keys := []string{storageKey, mediaVariantKey(storageKey, mediaProcessedVariant)}
+	for variant := range models.MediaVariantSizes {
+		keys = append(keys, mediaVariantKey(storageKey, variant))
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// save stores a processed image and its variants next to key and records it under key
+func (s *MediaService) save(ctx context.Context, userID uuid.UUID, storageKey, purpose string, processed *ProcessedImage) (*models.Media, error) {
+	object, err := s.store.Put(ctx, mediaVariantKey(storageKey, mediaProcessedVariant), processed.ContentType, bytes.NewReader(processed.Data))
+	if err != nil {
+		return nil, utils.NewInternal("failed to store image", err)
+	}
+
+	variantURLs := make(map[string]string, len(processed.Variants))
+	for variant, data := range processed.Variants {
+		stored, err := s.store.Put(ctx, mediaVariantKey(storageKey, variant), "image/jpeg", bytes.NewReader(data))
+		if err != nil {
+			return nil, utils.NewInternal("failed to store image variant", err)
+		}
+		variantURLs[variant] = stored.URL
+	}
+
+	// the hash is stored as a signed BIGINT, a flat image hashes to zero and would match every other flat image
+	var hash *int64
+	if processed.Hash != 0 {
+		signed := int64(processed.Hash)
+		hash = &signed
+	}
+
+	return s.repo.CreateMedia(ctx, &models.Media{
+		MediaID:        uuid.New(),
+		OwnerID:        userID,
+		Purpose:        purpose,
+		StorageBackend: s.store.Name(),
+		StorageKey:     storageKey,
+		URL:            object.URL,
+		ContentType:    processed.ContentType,
+		Bytes:          int64(len(processed.Data)),
+		Width:          processed.Width,
+		Height:         processed.Height,
+		ThumbnailURL:   variantURLs[models.MediaVariantThumbnail],
+		FeedURL:        variantURLs[models.MediaVariantFeed],
+		PerceptualHash: hash,
+	})
+}
+
+func (s *MediaService) deleteRejected(ctx context.Context, storageKey string) {
+	if err := s.store.Delete(ctx, storageKey); err != nil {
+		slog.WarnContext(ctx, "failed to delete rejected upload", "storage_key", storageKey, "error", err)
+	}
+}
+
+// readMedia reads an upload, rejecting it once it goes over models.MaxMediaBytes
+func readMedia(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, models.MaxMediaBytes+1))
+	if err != nil {
+		return nil, utils.NewInternal("failed to read upload", err)
+	}
+	if len(data) > models.MaxMediaBytes {
+		return nil, invalidMedia("file too large, max 5MB")
+	}
+	return data, nil
+}
+
+func invalidMedia(message string) error {
+	return utils.NewValidation(message, utils.FieldError{Field: "file", Rule: "media", Message: message})
+}
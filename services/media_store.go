@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"postswapapi/models"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrMediaNotFound is returned by a MediaStore when no object is stored under the key
+var ErrMediaNotFound = errors.New("media not found")
+
+// ErrMediaTooLarge is returned when an upload is over models.MaxMediaBytes
+var ErrMediaTooLarge = errors.New("media is too large")
+
+// ErrMediaExists is returned for a signed upload to a key that already holds a file
+var ErrMediaExists = errors.New("a file was already uploaded under this key")
+
+// how long a signed upload can be used for
+const mediaUploadTTL = time.Hour
+
+// StoredObject describes an object in a MediaStore
+type StoredObject struct {
+	URL         string
+	ContentType string
+	Bytes       int64
+	Width       int
+	Height      int
+}
+
+// MediaStore keeps uploaded files. Clients upload straight to it with the parameters from SignUpload,
+// the API only checks the result with Stat when the upload is confirmed.
+type MediaStore interface {
+	// Name identifies the backend in the media table
+	Name() string
+	// SignUpload returns what a client needs to upload one file of contentType under key before expiresAt.
+	// The upload can't replace an object already under key.
+	SignUpload(ctx context.Context, key, contentType string, expiresAt time.Time) (*models.SignedUpload, error)
+	// Put stores a file the API received itself, replacing any object under key
+	Put(ctx context.Context, key, contentType string, body io.Reader) (*StoredObject, error)
+	// Stat describes the object under key, ErrMediaNotFound when there is none
+	Stat(ctx context.Context, key string) (*StoredObject, error)
+	// Get returns the content of the object under key, ErrMediaNotFound when there is none
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewMediaStore builds the store named by MEDIA_BACKEND: "cloudinary" or "local".
+// When unset it uses Cloudinary if its credentials are configured and the local filesystem otherwise.
+func NewMediaStore() (MediaStore, error) {
+	backend := strings.ToLower(os.Getenv("MEDIA_BACKEND"))
+	if backend == "" {
+		backend = "local"
+		if os.Getenv("CLOUDINARY_CLOUD_NAME") != "" {
+			backend = "cloudinary"
+		}
+	}
+
+	switch backend {
+	case "cloudinary":
+		return NewCloudinaryStore(os.Getenv("CLOUDINARY_CLOUD_NAME"), os.Getenv("CLOUDINARY_API_KEY"), os.Getenv("CLOUDINARY_API_SECRET"))
+	case "local":
+		return NewLocalMediaStore(os.Getenv("MEDIA_LOCAL_DIR"), os.Getenv("MEDIA_PUBLIC_URL"), os.Getenv("MEDIA_SIGNING_SECRET"))
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_BACKEND %q", backend)
+	}
+}
+
+// media keys are pointswap/<folder>/<owner id>/<media id>, the owner and folder are checked on confirm.
+// Variants of an image are stored next to it as <key>_<variant>. Clients only get signatures for the key itself,
+// the processed image served for it is kept under <key>_processed where only the API writes.
+var (
+	mediaKeyPattern    = regexp.MustCompile(`^pointswap/([a-z]+)/([0-9a-f-]{36})/([0-9a-f-]{36})$`)
+	mediaObjectPattern = regexp.MustCompile(`^pointswap/[a-z]+/[0-9a-f-]{36}/[0-9a-f-]{36}(_[a-z]+)?$`)
+)
+
+func newMediaKey(purpose string, ownerID uuid.UUID) string {
+	return "pointswap/" + models.MediaFolder(purpose) + "/" + ownerID.String() + "/" + uuid.NewString()
+}
+
+// parseMediaKey returns the folder and owner of a key, ok is false for keys the API did not hand out
+func parseMediaKey(key string) (folder string, ownerID uuid.UUID, ok bool) {
+	parts := mediaKeyPattern.FindStringSubmatch(key)
+	if parts == nil {
+		return "", uuid.Nil, false
+	}
+
+	ownerID, err := uuid.Parse(parts[2])
+	if err != nil {
+		return "", uuid.Nil, false
+	}
+	if _, err := uuid.Parse(parts[3]); err != nil {
+		return "", uuid.Nil, false
+	}
+
+	return parts[1], ownerID, true
+}
+
+// mediaProcessedVariant holds the checked and stripped copy of an upload that its media URL points to
+const mediaProcessedVariant = "processed"
+
+func mediaVariantKey(key, variant string) string {
+	return key + "_" + variant
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"postswapapi/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxSwapOfferProducts = 10
+
+// prepareContent validates a message against its type and returns what gets stored:
+// the legacy image_url becomes an attachment (checked against the sender's uploads when the message is saved),
+// product cards get a snapshot of the listing and payloads are re-encoded so only known fields are kept
+func (s *MessageService) prepareContent(ctx context.Context, conversationID, senderID uuid.UUID, content models.MessageContent) (models.MessageContent, error) {
+	content.MessageText = strings.TrimSpace(content.MessageText)
+
+	if content.ImageUrl != nil && *content.ImageUrl != "" {
+		content.Attachments = append(content.Attachments, models.AttachmentInput{URL: *content.ImageUrl})
+	}
+	if len(content.Attachments) > models.MaxMessageAttachments {
+		return content, invalidContent("attachments", "max", fmt.Sprintf("a message can have at most %d attachments", models.MaxMessageAttachments))
+	}
+	for i := range content.Attachments {
+		if content.Attachments[i].ContentType == "" {
+			content.Attachments[i].ContentType = "image"
+		}
+	}
+
+	if content.MessageType == "" {
+		content.MessageType = models.MessageTypeText
+		if len(content.Attachments) > 0 {
+			content.MessageType = models.MessageTypeImage
+		}
+	}
+
+	if content.MessageType != models.MessageTypeImage && len(content.Attachments) > 0 {
+		return content, invalidContent("attachments", "excluded", "only image messages can have attachments")
+	}
+
+	var payload any
+	var err error
+
+	switch content.MessageType {
+	case models.MessageTypeText:
+		if content.MessageText == "" {
+			return content, invalidContent("message_text", "required", "message must have text or an attachment")
+		}
+	case models.MessageTypeImage:
+		if len(content.Attachments) == 0 {
+			return content, invalidContent("attachments", "required", "image messages need at least one attachment")
+		}
+	case models.MessageTypeProductCard:
+		payload, err = s.productCardPayload(ctx, content.Payload)
+	case models.MessageTypeSwapOffer:
+		payload, err = s.swapOfferPayload(ctx, conversationID, senderID, content.Payload)
+	case models.MessageTypeMeetingLocation:
+		payload, err = meetingLocationPayload(content.Payload)
+	default:
+		return content, invalidContent("message_type", "oneof", "unsupported message type")
+	}
+	if err != nil {
+		return content, err
+	}
+
+	content.Payload = nil
+	if payload != nil {
+		if content.Payload, err = json.Marshal(payload); err != nil {
+			return content, fmt.Errorf("failed to encode payload: %w", err)
+		}
+	}
+
+	return content, nil
+}
+
+// attachMedia replaces each attachment's media ID or URL with the URL of the sender's upload it refers to,
+// inside the transaction saving the message
+func (s *MessageService) attachMedia(senderID uuid.UUID) repository.AttachFunc {
+	if s.media == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, tx repository.DBTX, attachments []models.AttachmentInput) error {
+		refs := make([]string, len(attachments))
+		for i, attachment := range attachments {
+			refs[i] = attachment.URL
+		}
+
+		media, err := s.media.Attach(ctx, tx, senderID, "attachments", refs)
+		if err != nil {
+			return err
+		}
+		for i := range attachments {
+			attachments[i].URL = media[i].URL
+		}
+
+		return nil
+	}
+}
+
+func (s *MessageService) productCardPayload(ctx context.Context, raw json.RawMessage) (*models.ProductCardPayload, error) {
+	var input models.ProductCardPayload
+	if err := decodePayload(raw, &input); err != nil {
+		return nil, err
+	}
+	if input.ProductID == uuid.Nil {
+		return nil, invalidContent("payload.product_id", "required", "product_id is required")
+	}
+
+	card, _, err := s.repo.GetProductCard(ctx, input.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	return card, nil
+}
+
+// swapOfferPayload checks the sender offers their own active listings for active listings of someone else in the conversation
+func (s *MessageService) swapOfferPayload(ctx context.Context, conversationID, senderID uuid.UUID, raw json.RawMessage) (*models.SwapOfferPayload, error) {
+	var offer models.SwapOfferPayload
+	if err := decodePayload(raw, &offer); err != nil {
+		return nil, err
+	}
+
+	if len(offer.OfferedProductIDs) == 0 || len(offer.OfferedProductIDs) > maxSwapOfferProducts {
+		return nil, invalidContent("payload.offered_product_ids", "required", fmt.Sprintf("offer between 1 and %d of your products", maxSwapOfferProducts))
+	}
+	if len(offer.RequestedProductIDs) == 0 || len(offer.RequestedProductIDs) > maxSwapOfferProducts {
+		return nil, invalidContent("payload.requested_product_ids", "required", fmt.Sprintf("request between 1 and %d products", maxSwapOfferProducts))
+	}
+	if len(offer.Note) > 1000 {
+		return nil, invalidContent("payload.note", "max", "note must be at most 1000 characters")
+	}
+
+	participants, err := s.repo.GetParticipants(ctx, []uuid.UUID{conversationID})
+	if err != nil {
+		return nil, err
+	}
+	others := map[uuid.UUID]bool{}
+	for _, participant := range participants[conversationID] {
+		if participant.UserID != senderID {
+			others[participant.UserID] = true
+		}
+	}
+
+	sellers, err := s.repo.GetActiveProductSellers(ctx, append(append([]uuid.UUID{}, offer.OfferedProductIDs...), offer.RequestedProductIDs...))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, productID := range offer.OfferedProductIDs {
+		if sellerID, ok := sellers[productID]; !ok || sellerID != senderID {
+			return nil, invalidContent("payload.offered_product_ids", "owned", "you can only offer your own active listings")
+		}
+	}
+	for _, productID := range offer.RequestedProductIDs {
+		if sellerID, ok := sellers[productID]; !ok || !others[sellerID] {
+			return nil, invalidContent("payload.requested_product_ids", "participant", "requested products must be active listings of someone in this conversation")
+		}
+	}
+
+	return &offer, nil
+}
+
+func meetingLocationPayload(raw json.RawMessage) (*models.MeetingLocationPayload, error) {
+	var location models.MeetingLocationPayload
+	if err := decodePayload(raw, &location); err != nil {
+		return nil, err
+	}
+
+	location.Name = strings.TrimSpace(location.Name)
+	switch {
+	case location.Name == "" || len(location.Name) > 200:
+		return nil, invalidContent("payload.name", "required", "name is required and at most 200 characters")
+	case len(location.Address) > 500:
+		return nil, invalidContent("payload.address", "max", "address must be at most 500 characters")
+	case location.Latitude < -90 || location.Latitude > 90:
+		return nil, invalidContent("payload.latitude", "range", "latitude must be between -90 and 90")
+	case location.Longitude < -180 || location.Longitude > 180:
+		return nil, invalidContent("payload.longitude", "range", "longitude must be between -180 and 180")
+	case location.MeetAt != nil && location.MeetAt.Before(time.Now()):
+		return nil, invalidContent("payload.meet_at", "future", "meet_at must be in the future")
+	}
+
+	return &location, nil
+}
+
+// decodePayload strictly decodes a message payload, unknown fields are rejected
+func decodePayload(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		return invalidContent("payload", "required", "this message type needs a payload")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return invalidContent("payload", "json", "invalid payload: "+err.Error())
+	}
+	return nil
+}
+
+func invalidContent(field, rule, message string) error {
+	return utils.NewValidation(message, utils.FieldError{Field: field, Rule: rule, Message: message})
+}
@@ -3,152 +3,322 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"postswapapi/metrics"
 	"postswapapi/models"
 	"postswapapi/repository"
+	"postswapapi/utils"
 	"time"
 
-	"github.com/ably/ably-go/ably"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("postswapapi/services")
+
+var errNotParticipant = utils.NewForbidden("user is not a participant in this conversation")
+
+// defaultEditWindow is how long senders can edit a message unless MESSAGE_EDIT_WINDOW says otherwise
+const defaultEditWindow = 15 * time.Minute
+
 type MessageService struct {
 	repo       *repository.MessageRepository
-	ablyClient *ably.Realtime
+	publisher  RealtimePublisher
+	outbox     *OutboxDispatcher
+	media      *MediaService
+	editWindow time.Duration
 }
 
-func NewMessageService(repo *repository.MessageRepository) (*MessageService, error) {
-	ablyAPIKey := os.Getenv("ABLY_KEY")
-	if ablyAPIKey == "" {
-		return nil, fmt.Errorf("ABLY_KEY environment variable not set")
-	}
-
-	// Initialize Ably client
-	client, err := ably.NewRealtime(
-		ably.WithKey(ablyAPIKey),
-		ably.WithEchoMessages(false), // Don't echo messages back to sender
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create ably client: %w", err)
+func NewMessageService(repo *repository.MessageRepository, publisher RealtimePublisher) *MessageService {
+	editWindow := defaultEditWindow
+	if raw := os.Getenv("MESSAGE_EDIT_WINDOW"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			slog.Warn("invalid MESSAGE_EDIT_WINDOW, using the default", "value", raw, "default", defaultEditWindow)
+		} else {
+			editWindow = parsed
+		}
 	}
 
 	return &MessageService{
 		repo:       repo,
-		ablyClient: client,
-	}, nil
+		publisher:  publisher,
+		editWindow: editWindow,
+	}
 }
 
-// Close cleanly closes the Ably connection
+// SetOutbox lets the service wake the dispatcher as soon as a message change commits
+func (s *MessageService) SetOutbox(outbox *OutboxDispatcher) {
+	s.outbox = outbox
+}
+
+// SetMedia makes attachments resolve to the sender's uploads, without it attachment URLs are stored as sent
+func (s *MessageService) SetMedia(media *MediaService) {
+	s.media = media
+}
+
+// Close cleanly closes the realtime connection
 func (s *MessageService) Close() {
-	s.ablyClient.Close()
+	s.publisher.Close()
 }
 
-// GetOrCreateConversation finds or creates a conversation
-func (s *MessageService) GetOrCreateConversation(user1ID, user2ID uuid.UUID) (uuid.UUID, error) {
-	conversationID, err := s.repo.GetOrCreateConversation(user1ID, user2ID)
+// GetOrCreateConversation finds or creates a conversation, optionally about a product one of the two users listed
+func (s *MessageService) GetOrCreateConversation(ctx context.Context, user1ID, user2ID uuid.UUID, productID *uuid.UUID) (uuid.UUID, error) {
+	if productID != nil {
+		sellerID, err := s.repo.GetProductSellerID(ctx, *productID)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to get product: %w", err)
+		}
+		if sellerID != user1ID && sellerID != user2ID {
+			return uuid.Nil, utils.NewBadRequest("product must belong to one of the participants")
+		}
+	}
+
+	conversationID, err := s.repo.GetOrCreateConversation(ctx, user1ID, user2ID, productID)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to get or create conversation: %w", err)
 	}
 	return conversationID, nil
 }
 
+// MessageSeller opens the buyer's conversation with the seller about a product
+func (s *MessageService) MessageSeller(ctx context.Context, productID, buyerID uuid.UUID) (uuid.UUID, error) {
+	sellerID, err := s.repo.GetProductSellerID(ctx, productID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	if sellerID == buyerID {
+		return uuid.Nil, utils.NewBadRequest("cannot message yourself about your own product")
+	}
+
+	return s.GetOrCreateConversation(ctx, buyerID, sellerID, &productID)
+}
+
 // SendMessage creates a new message or starts a conversation
-func (s *MessageService) SendMessage(senderID, recipientID uuid.UUID, messageText string, imageURL *string) (*models.Message, error) {
+func (s *MessageService) SendMessage(ctx context.Context, senderID, recipientID uuid.UUID, content models.MessageContent, productID *uuid.UUID) (*models.Message, error) {
 	// Get or create conversation
-	conversationID, err := s.repo.GetOrCreateConversation(senderID, recipientID)
+	conversationID, err := s.GetOrCreateConversation(ctx, senderID, recipientID, productID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get or create conversation: %w", err)
+		return nil, err
 	}
 
-	// Save message to database
-	message, err := s.repo.CreateMessage(conversationID, senderID, messageText, imageURL)
+	content, err = s.prepareContent(ctx, conversationID, senderID, content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create message: %w", err)
+		return nil, err
 	}
 
-	// Publish to Ably for real-time delivery
-	if err := s.publishMessageToAbly(conversationID, message); err != nil {
-		// Log error but don't fail the request - message is already saved
-		fmt.Printf("Warning: failed to publish message to Ably: %v\n", err)
+	// Save message to database
+	message, err := s.repo.CreateMessage(ctx, conversationID, senderID, content.MessageType, content.MessageText, content.Payload, content.Attachments, s.attachMedia(senderID), newMessageOutbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
+	metrics.MessagesSent.Inc()
+	s.outbox.Notify()
 
 	return message, nil
 }
 
 // SendMessageToConversation sends a message to an existing conversation
-// Update SendMessageToConversation to accept imageURL
-func (s *MessageService) SendMessageToConversation(conversationID, senderID uuid.UUID, messageText string, imageURL *string) (*models.Message, error) {
+func (s *MessageService) SendMessageToConversation(ctx context.Context, conversationID, senderID uuid.UUID, content models.MessageContent) (*models.Message, error) {
 	// Verify sender is in conversation
-	isParticipant, err := s.repo.VerifyUserInConversation(conversationID, senderID)
+	isParticipant, err := s.repo.VerifyUserInConversation(ctx, conversationID, senderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify participant: %w", err)
 	}
 	if !isParticipant {
-		return nil, fmt.Errorf("user is not a participant in this conversation")
+		return nil, errNotParticipant
+	}
+
+	content, err = s.prepareContent(ctx, conversationID, senderID, content)
+	if err != nil {
+		return nil, err
 	}
 
 	// Save message to database
-	message, err := s.repo.CreateMessage(conversationID, senderID, messageText, imageURL)
+	message, err := s.repo.CreateMessage(ctx, conversationID, senderID, content.MessageType, content.MessageText, content.Payload, content.Attachments, s.attachMedia(senderID), newMessageOutbox)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create message: %w", err)
 	}
+	metrics.MessagesSent.Inc()
+	s.outbox.Notify()
+
+	return message, nil
+}
+
+// productStatusNotices is the system message posted in a product's conversations when its status changes
+var productStatusNotices = map[string]string{
+	"active":   "This item is available again.",
+	"swapped":  "This item has been swapped.",
+	"inactive": "This item is no longer listed.",
+}
+
+// NotifyProductStatusChanged posts a system message in every conversation about the product as part of tx,
+// the outbox announces them once it commits
+func (s *MessageService) NotifyProductStatusChanged(ctx context.Context, tx repository.DBTX, productID, sellerID uuid.UUID, status string) error {
+	notice, ok := productStatusNotices[status]
+	if !ok {
+		return nil
+	}
 
-	// Publish to Ably for real-time delivery
-	if err := s.publishMessageToAbly(conversationID, message); err != nil {
-		fmt.Printf("Warning: failed to publish message to Ably: %v\n", err)
+	if err := s.repo.CreateProductSystemMessages(ctx, tx, productID, sellerID, notice, newMessageOutbox); err != nil {
+		return fmt.Errorf("failed to create system message: %w", err)
 	}
 
-	return message, nil
+	return nil
 }
 
-// publishMessageToAbly publishes a message to the conversation's Ably channel
-func (s *MessageService) publishMessageToAbly(conversationID uuid.UUID, message *models.Message) error {
-	channelName := fmt.Sprintf("conversation:%s", conversationID.String())
-	channel := s.ablyClient.Channels.Get(channelName)
+// newMessageOutbox announces a saved message on its conversation's realtime channel
+func newMessageOutbox(message *models.Message) ([]models.OutboxEntry, error) {
+	preview := models.MessagePreview(message.MessageType, message.MessageText, message.Payload, len(message.Attachments))
 
 	payload := map[string]interface{}{
 		"id":              message.ID.String(),
 		"conversation_id": message.ConversationID.String(),
 		"sender_id":       message.SenderID.String(),
 		"message_text":    message.MessageText,
+		"message_type":    message.MessageType,
+		"attachments":     message.Attachments,
+		"preview":         preview,
 		"created_at":      message.CreatedAt.Format(time.RFC3339),
 	}
 
+	if len(message.Payload) > 0 {
+		payload["payload"] = message.Payload
+	}
+
 	// Add image_url if present
 	if message.ImageUrl != nil {
 		payload["image_url"] = *message.ImageUrl
 	}
 
-	err := channel.Publish(context.Background(), "new_message", payload)
-	if err != nil {
-		return fmt.Errorf("failed to publish to ably: %w", err)
+	entry, err := models.NewRealtimeOutboxEntry(ConversationChannel(message.ConversationID), "new_message", payload)
+	if err != nil || message.MessageType == models.MessageTypeSystem {
+		return []models.OutboxEntry{entry}, err
 	}
 
+	// offline participants get a push instead
+	push, err := models.NewPushOutboxEntry(models.MessageNotification{
+		ConversationID:   message.ConversationID,
+		MessageID:        message.ID,
+		SenderID:         message.SenderID,
+		MessageType:      message.MessageType,
+		NotificationType: "new_message",
+		Preview:          preview,
+	})
+	return []models.OutboxEntry{entry, push}, err
+}
+
+// publish sends an event on a channel and counts failures
+func (s *MessageService) publish(ctx context.Context, channel, event string, payload any) error {
+	if err := s.publisher.Publish(ctx, channel, event, payload); err != nil {
+		metrics.RealtimePublishFailures.WithLabelValues(event).Inc()
+		return err
+	}
 	return nil
 }
 
-// GetConversationMessages retrieves messages with pagination
-func (s *MessageService) GetConversationMessages(conversationID, userID uuid.UUID, limit, offset int) ([]models.MessageWithSender, error) {
+// GetConversationMessages retrieves a page of messages, newest first, and reports whether there are more
+func (s *MessageService) GetConversationMessages(ctx context.Context, conversationID, userID uuid.UUID, page models.MessagePageQuery) ([]models.MessageWithSender, bool, error) {
 	// Verify user is in conversation
-	isParticipant, err := s.repo.VerifyUserInConversation(conversationID, userID)
+	isParticipant, err := s.repo.VerifyUserInConversation(ctx, conversationID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify participant: %w", err)
+		return nil, false, fmt.Errorf("failed to verify participant: %w", err)
 	}
 	if !isParticipant {
-		return nil, fmt.Errorf("user is not a participant in this conversation")
+		return nil, false, errNotParticipant
+	}
+
+	messages, err := s.repo.GetConversationMessages(ctx, conversationID, page)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	// the repository reads one extra row to tell us there is another page
+	hasMore := len(messages) > page.Limit
+	if hasMore {
+		if page.After != nil {
+			// walking forward the extra row is the newest one, at the front
+			messages = messages[1:]
+		} else {
+			messages = messages[:page.Limit]
+		}
+	}
+
+	s.markDelivered(ctx, conversationID, userID, messages)
+
+	return messages, hasMore, nil
+}
+
+const (
+	// syncMessageLimit caps how many messages one sync call returns
+	syncMessageLimit = 500
+	// changes newer than this may belong to transactions that haven't committed yet, they are left for the next sync
+	syncCommitLag = 5 * time.Second
+)
+
+// Sync collects everything that changed in the user's conversations after the cursor, for offline catch-up.
+// Changes are read up to the database clock less syncCommitLag, next_cursor resumes after the last message
+// returned or, once everything is returned, after that bound.
+func (s *MessageService) Sync(ctx context.Context, userID uuid.UUID, after models.MessageCursor) (*models.SyncResponse, error) {
+	ctx, span := tracer.Start(ctx, "MessageService.Sync")
+	defer span.End()
+
+	now, err := s.repo.Now(ctx)
+	if err != nil {
+		return nil, err
+	}
+	until := now.Add(-syncCommitLag)
+	if until.Before(after.CreatedAt) {
+		until = after.CreatedAt
+	}
+
+	messages, err := s.repo.GetMessagesChangedSince(ctx, userID, after, until, syncMessageLimit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	// uuid.Max puts the cursor after every change at until, all of them are returned
+	next := models.MessageCursor{CreatedAt: until, ID: uuid.Max}
+
+	hasMore := len(messages) > syncMessageLimit
+	if hasMore {
+		// resume after the last message returned, ties on its time are told apart by id.
+		// The other lists are small and safe to repeat.
+		messages = messages[:syncMessageLimit]
+		last := messages[syncMessageLimit-1]
+		next = models.MessageCursor{CreatedAt: messageChangedAt(last), ID: last.ID}
 	}
 
-	messages, err := s.repo.GetConversationMessages(conversationID, limit, offset)
+	deleted, err := s.repo.GetMessagesDeletedSince(ctx, userID, after.CreatedAt, until)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get messages: %w", err)
+		return nil, err
 	}
 
-	return messages, nil
+	readStates, err := s.repo.GetReadStatesSince(ctx, userID, after.CreatedAt, until)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts, err := s.repo.GetReceiptsSince(ctx, userID, after.CreatedAt, until)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SyncResponse{
+		Messages:   messages,
+		Deleted:    deleted,
+		ReadStates: readStates,
+		Receipts:   receipts,
+		HasMore:    hasMore,
+		NextCursor: next.Encode(),
+	}, nil
 }
 
 // GetUserConversations retrieves all conversations for a user
-func (s *MessageService) GetUserConversations(userID uuid.UUID) ([]models.ConversationWithDetails, error) {
-	conversations, err := s.repo.GetUserConversations(userID)
+func (s *MessageService) GetUserConversations(ctx context.Context, userID uuid.UUID) ([]models.ConversationWithDetails, error) {
+	conversations, err := s.repo.GetUserConversations(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conversations: %w", err)
 	}
@@ -157,65 +327,184 @@ func (s *MessageService) GetUserConversations(userID uuid.UUID) ([]models.Conver
 }
 
 // MarkConversationAsRead marks all messages in a conversation as read
-func (s *MessageService) MarkConversationAsRead(conversationID, userID uuid.UUID) error {
+func (s *MessageService) MarkConversationAsRead(ctx context.Context, conversationID, userID uuid.UUID) error {
 	// Verify user is in conversation
-	isParticipant, err := s.repo.VerifyUserInConversation(conversationID, userID)
+	isParticipant, err := s.repo.VerifyUserInConversation(ctx, conversationID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to verify participant: %w", err)
 	}
 	if !isParticipant {
-		return fmt.Errorf("user is not a participant in this conversation")
+		return errNotParticipant
 	}
 
-	err = s.repo.MarkConversationAsRead(conversationID, userID)
+	err = s.repo.MarkConversationAsRead(ctx, conversationID, userID)
 	if err != nil {
 		return fmt.Errorf("failed to mark as read: %w", err)
 	}
 
+	readIDs, err := s.repo.MarkMessagesRead(ctx, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to record read receipts: %w", err)
+	}
+
+	// Let the senders know their messages were read
+	if len(readIDs) > 0 {
+		receipt := models.ReceiptEvent{ConversationID: conversationID, UserID: userID, MessageIDs: readIDs, At: time.Now()}
+		if err := s.publish(ctx, ConversationChannel(conversationID), models.RealtimeMessagesRead, receipt); err != nil {
+			slog.WarnContext(ctx, "failed to publish read receipt", "conversation_id", conversationID, "error", err)
+		}
+	}
+
 	return nil
 }
 
-// DeleteMessage soft deletes a message
-func (s *MessageService) DeleteMessage(messageID, userID uuid.UUID) error {
-	err := s.repo.DeleteMessage(messageID, userID)
+// markDelivered records delivered receipts for the fetched messages and publishes the new ones
+func (s *MessageService) markDelivered(ctx context.Context, conversationID, userID uuid.UUID, messages []models.MessageWithSender) {
+	var messageIDs []uuid.UUID
+	for _, msg := range messages {
+		if msg.SenderID != userID {
+			messageIDs = append(messageIDs, msg.ID)
+		}
+	}
+
+	deliveredIDs, err := s.repo.MarkMessagesDelivered(ctx, conversationID, userID, messageIDs)
 	if err != nil {
-		return fmt.Errorf("failed to delete message: %w", err)
+		// receipts are best effort, the fetch itself succeeded
+		slog.WarnContext(ctx, "failed to record delivered receipts", "conversation_id", conversationID, "error", err)
+		return
 	}
 
-	// Optionally: publish deletion event to Ably
-	// so other clients can update their UI in real-time
+	if len(deliveredIDs) == 0 {
+		return
+	}
 
-	return nil
+	receipt := models.ReceiptEvent{ConversationID: conversationID, UserID: userID, MessageIDs: deliveredIDs, At: time.Now()}
+	if err := s.publish(ctx, ConversationChannel(conversationID), models.RealtimeMessagesDelivered, receipt); err != nil {
+		slog.WarnContext(ctx, "failed to publish delivered receipt", "conversation_id", conversationID, "error", err)
+	}
 }
 
-// GenerateAblyTokenForUser creates a token for client-side Ably authentication
-// This is important for security - clients shouldn't have your API key
-func (s *MessageService) GenerateAblyTokenForUser(userID uuid.UUID) (string, error) {
-	ablyAPIKey := os.Getenv("ABLY_KEY")
-	if ablyAPIKey == "" {
-		return "", fmt.Errorf("ABLY_KEY not configured")
+// SetTyping publishes a typing start/stop event to the conversation
+func (s *MessageService) SetTyping(ctx context.Context, conversationID, userID uuid.UUID, isTyping bool) error {
+	isParticipant, err := s.repo.VerifyUserInConversation(ctx, conversationID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to verify participant: %w", err)
+	}
+	if !isParticipant {
+		return errNotParticipant
+	}
+
+	event := models.TypingEvent{ConversationID: conversationID, UserID: userID, IsTyping: isTyping}
+	return s.publish(ctx, ConversationChannel(conversationID), models.RealtimeTyping, event)
+}
+
+// EditMessage replaces the text of the sender's own message while it is inside the edit window
+func (s *MessageService) EditMessage(ctx context.Context, messageID, userID uuid.UUID, messageText string) (*models.Message, error) {
+	message, err := s.repo.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	if message.SenderID != userID {
+		return nil, utils.NewForbidden("you can only edit your own messages")
+	}
+
+	if message.MessageType == models.MessageTypeSystem {
+		return nil, utils.NewForbidden("system messages cannot be edited")
+	}
+	if message.MessageType != models.MessageTypeText && message.MessageType != models.MessageTypeImage {
+		return nil, utils.NewBadRequest("only text messages and image captions can be edited")
+	}
+
+	if time.Since(message.CreatedAt) > s.editWindow {
+		return nil, utils.NewForbidden("the edit window for this message has passed")
+	}
+
+	if message.MessageText == messageText {
+		return message, nil
 	}
 
-	// Create a REST client for token generation
-	restClient, err := ably.NewREST(ably.WithKey(ablyAPIKey))
+	message, err = s.repo.EditMessage(ctx, messageID, messageText, func(message *models.Message) ([]models.OutboxEntry, error) {
+		entry, err := models.NewRealtimeOutboxEntry(ConversationChannel(message.ConversationID), "message_updated", map[string]interface{}{
+			"id":              message.ID.String(),
+			"conversation_id": message.ConversationID.String(),
+			"sender_id":       message.SenderID.String(),
+			"message_text":    message.MessageText,
+			"message_type":    message.MessageType,
+			"edited_at":       message.EditedAt.Format(time.RFC3339),
+		})
+		return []models.OutboxEntry{entry}, err
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create REST client: %w", err)
+		return nil, fmt.Errorf("failed to edit message: %w", err)
 	}
+	s.outbox.Notify()
+
+	return message, nil
+}
 
-	// Request a token with user's ID as ClientID
-	token, err := restClient.Auth.RequestToken(context.Background(), &ably.TokenParams{
-		ClientID: userID.String(),
+// GetMessageEdits returns a message's edit history to participants of its conversation
+func (s *MessageService) GetMessageEdits(ctx context.Context, messageID, userID uuid.UUID) ([]models.MessageEdit, error) {
+	message, err := s.repo.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	isParticipant, err := s.repo.VerifyUserInConversation(ctx, message.ConversationID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify participant: %w", err)
+	}
+	if !isParticipant {
+		return nil, errNotParticipant
+	}
+
+	edits, err := s.repo.GetMessageEdits(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message edits: %w", err)
+	}
+
+	return edits, nil
+}
+
+// DeleteMessage soft deletes a message and tells the conversation so clients can drop it
+func (s *MessageService) DeleteMessage(ctx context.Context, messageID, userID uuid.UUID) error {
+	_, err := s.repo.DeleteMessage(ctx, messageID, userID, func(message *models.Message) ([]models.OutboxEntry, error) {
+		entry, err := models.NewRealtimeOutboxEntry(ConversationChannel(message.ConversationID), "message_deleted", map[string]interface{}{
+			"id":              message.ID.String(),
+			"conversation_id": message.ConversationID.String(),
+			"deleted_at":      message.DeletedAt.Format(time.RFC3339),
+		})
+		return []models.OutboxEntry{entry}, err
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to request token: %w", err)
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	s.outbox.Notify()
+
+	return nil
+}
+
+// GenerateAblyTokenForUser creates a token for client-side realtime authentication
+// This is important for security - clients shouldn't have your API key
+func (s *MessageService) GenerateAblyTokenForUser(ctx context.Context, userID uuid.UUID) (string, error) {
+	issuer, ok := s.publisher.(TokenIssuer)
+	if !ok {
+		return "", utils.NewBadRequest("realtime backend does not issue tokens, connect with your API token instead")
 	}
 
-	// Return just the token string
-	return token.Token, nil
+	return issuer.ClientToken(ctx, userID)
 }
 
-// GetConversationChannelName returns the Ably channel name for a conversation
+// GetConversationChannelName returns the realtime channel name for a conversation
 // Useful for frontend to know which channel to subscribe to
 func (s *MessageService) GetConversationChannelName(conversationID uuid.UUID) string {
-	return fmt.Sprintf("conversation:%s", conversationID.String())
+	return ConversationChannel(conversationID)
+}
+
+// messageChangedAt is when a message was created or last edited
+func messageChangedAt(msg models.MessageWithSender) time.Time {
+	if msg.EditedAt != nil && msg.EditedAt.After(msg.CreatedAt) {
+		return *msg.EditedAt
+	}
+	return msg.CreatedAt
 }
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"postswapapi/utils"
+
+	"github.com/google/uuid"
+)
+
+// photos whose perceptual hashes differ in at most this many of 64 bits are treated as the same picture.
+// dHashes of a re-encoded, resized or lightly cropped copy usually differ in under 5.
+const similarPhotoDistance = 6
+
+// ModerationService screens new listings for reused and blocked photos and runs the moderation queue.
+// Matches are flagged for a moderator rather than rejected, a seller reposting their own photos is not flagged.
+type ModerationService struct {
+	repo *repository.ModerationRepository
+}
+
+func NewModerationService(repo *repository.ModerationRepository) *ModerationService {
+	return &ModerationService{repo: repo}
+}
+
+// ScreenProduct flags photos of a new listing that match another seller's photos or a blocked image.
+// It runs from the outbox after the listing is created, an error is retried there.
+func (s *ModerationService) ScreenProduct(ctx context.Context, productID uuid.UUID) error {
+	flagged, err := s.repo.FlagProductPhotos(ctx, productID, similarPhotoDistance)
+	if err != nil {
+		return err
+	}
+	if flagged > 0 {
+		slog.InfoContext(ctx, "product photos flagged for moderation", "product_id", productID, "flags", flagged)
+	}
+	return nil
+}
+
+func (s *ModerationService) ListFlags(ctx context.Context, status string, limit, offset int) ([]models.ModerationFlag, error) {
+	return s.repo.ListFlags(ctx, status, limit, offset)
+}
+
+func (s *ModerationService) ResolveFlag(ctx context.Context, flagID, moderatorID uuid.UUID, action string) error {
+	return s.repo.ResolveFlag(ctx, flagID, moderatorID, action == models.ModerationActionRemoveListing)
+}
+
+// BlockImage adds the image of a listing photo or upload to the blocklist and flags active listings already using it
+func (s *ModerationService) BlockImage(ctx context.Context, moderatorID uuid.UUID, req models.BlockImageRequest) (*models.BlockedImageHash, error) {
+	var hash *int64
+	var err error
+
+	switch {
+	case req.PhotoID != nil && req.MediaID == nil:
+		hash, err = s.repo.GetPhotoHash(ctx, *req.PhotoID)
+	case req.MediaID != nil && req.PhotoID == nil:
+		hash, err = s.repo.GetMediaHash(ctx, *req.MediaID)
+	default:
+		return nil, utils.NewValidation("send one of photo_id or media_id", utils.FieldError{
+			Field: "photo_id", Rule: "required_without", Message: "send one of photo_id or media_id",
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		return nil, utils.NewValidation("this image has no perceptual hash and can't be blocked", utils.FieldError{
+			Field: "photo_id", Rule: "hashed", Message: "only images uploaded through the API have a perceptual hash",
+		})
+	}
+
+	blocked, err := s.repo.BlockHash(ctx, *hash, req.Reason, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	flagged, err := s.repo.FlagBlockedPhotos(ctx, blocked.HashID, similarPhotoDistance)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to flag listings using a blocked image", "hash_id", blocked.HashID, "error", err)
+	} else if flagged > 0 {
+		slog.InfoContext(ctx, "listings using a blocked image flagged for moderation", "hash_id", blocked.HashID, "flags", flagged)
+	}
+
+	return blocked, nil
+}
+
+func (s *ModerationService) ListBlockedImages(ctx context.Context) ([]models.BlockedImageHash, error) {
+	return s.repo.ListBlockedHashes(ctx)
+}
+
+func (s *ModerationService) UnblockImage(ctx context.Context, hashID uuid.UUID) error {
+	return s.repo.DeleteBlockedHash(ctx, hashID)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"postswapapi/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationPreferenceService holds per-type channel preferences, quiet hours and the digest frequency.
+// Quiet hours and push preferences are applied by the push queries, in-app by the notification producers.
+type NotificationPreferenceService struct {
+	repo *repository.NotificationPreferenceRepository
+}
+
+func NewNotificationPreferenceService(repo *repository.NotificationPreferenceRepository) *NotificationPreferenceService {
+	return &NotificationPreferenceService{repo: repo}
+}
+
+// GetSettings returns the user's notification settings
+func (s *NotificationPreferenceService) GetSettings(ctx context.Context, userID uuid.UUID) (*models.NotificationSettings, error) {
+	return s.repo.GetSettings(ctx, userID)
+}
+
+// UpdateSettings applies the changes in req to the user's settings and returns the result
+func (s *NotificationPreferenceService) UpdateSettings(ctx context.Context, userID uuid.UUID, req models.UpdateNotificationSettingsRequest) (*models.NotificationSettings, error) {
+	settings, err := s.repo.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			return nil, utils.NewValidation("unknown timezone", utils.FieldError{
+				Field: "timezone", Rule: "timezone", Message: "must be an IANA timezone such as Europe/Berlin",
+			})
+		}
+		settings.Timezone = *req.Timezone
+	}
+
+	if req.QuietHoursStart != nil || req.QuietHoursEnd != nil {
+		if req.QuietHoursStart == nil || req.QuietHoursEnd == nil || (*req.QuietHoursStart == "") != (*req.QuietHoursEnd == "") {
+			return nil, utils.NewValidation("quiet hours need both a start and an end", utils.FieldError{
+				Field: "quiet_hours_end", Rule: "required_with", Message: "set quiet_hours_start and quiet_hours_end together",
+			})
+		}
+		if *req.QuietHoursStart == "" {
+			settings.QuietHoursStart, settings.QuietHoursEnd = nil, nil
+		} else {
+			settings.QuietHoursStart, settings.QuietHoursEnd = req.QuietHoursStart, req.QuietHoursEnd
+		}
+	}
+
+	if req.DigestFrequency != nil {
+		settings.DigestFrequency = *req.DigestFrequency
+	}
+
+	var changed []models.ChannelPreference
+	for _, update := range req.Preferences {
+		for i := range settings.Preferences {
+			pref := &settings.Preferences[i]
+			if pref.NotificationType != update.NotificationType {
+				continue
+			}
+			if update.InApp != nil {
+				pref.InApp = *update.InApp
+			}
+			if update.Push != nil {
+				pref.Push = *update.Push
+			}
+			if update.Email != nil {
+				pref.Email = *update.Email
+			}
+			changed = append(changed, *pref)
+		}
+	}
+
+	if err := s.repo.SaveSettings(ctx, userID, settings, changed); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// Channels returns where a notification of notificationType should go for the user.
+// A failed lookup falls back to every channel rather than dropping the notification.
+func (s *NotificationPreferenceService) Channels(ctx context.Context, userID uuid.UUID, notificationType string) models.ChannelPreference {
+	preferenceType := models.PreferenceTypeFor(notificationType)
+
+	pref, err := s.repo.GetChannelPreference(ctx, userID, preferenceType)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load notification preference", "user_id", userID, "type", preferenceType, "error", err)
+		return models.DefaultChannelPreference(preferenceType)
+	}
+
+	return pref
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"postswapapi/repository"
+	"time"
+)
+
+const notificationArchiveBatch = 1000
+
+// NotificationRetention archives read notifications once they are older than the retention period.
+// Unread notifications are kept however old they are.
+type NotificationRetention struct {
+	repo   *repository.NotificationRepository
+	maxAge time.Duration
+}
+
+func NewNotificationRetention(repo *repository.NotificationRepository, maxAge time.Duration) *NotificationRetention {
+	return &NotificationRetention{repo: repo, maxAge: maxAge}
+}
+
+// Run archives in batches once an hour until ctx is cancelled
+func (s *NotificationRetention) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		s.archive(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *NotificationRetention) archive(ctx context.Context) {
+	var total int64
+	for ctx.Err() == nil {
+		archived, err := s.repo.ArchiveReadBefore(ctx, time.Now().Add(-s.maxAge), notificationArchiveBatch)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to archive notifications", "error", err)
+			break
+		}
+
+		total += archived
+		if archived < notificationArchiveBatch {
+			break
+		}
+	}
+
+	if total > 0 {
+		slog.InfoContext(ctx, "archived read notifications", "archived", total)
+	}
+}
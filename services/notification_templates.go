@@ -0,0 +1,291 @@
+package services
+
+import (
+	"fmt"
+	"postswapapi/models"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// notificationTemplateSource is the title and body of one notification type in one language
+type notificationTemplateSource struct {
+	Title string
+	Body  string
+}
+
+const (
+	// group summaries are looked up as "<preference type>.group", falling back to "group"
+	groupSummaryKey = "group"
+)
+
+// both sides of a match get the same text, the params say which side is which
+var notificationTemplateSources = map[string]map[string]notificationTemplateSource{
+	"en": {
+		models.NotificationMutualMatch: {
+			Title: "Mutual Swap Interest",
+			Body:  "{{.other_user}} has {{.other_product}} and wants {{.other_wants}} - you have {{.your_product}} and want {{.your_wants}}, you have a perfect match!",
+		},
+		models.NotificationMutualInterest: {
+			Title: "Mutual Swap Interest",
+			Body:  "{{.other_user}} has {{.other_product}} and wants {{.other_wants}} - you have {{.your_product}} and want {{.your_wants}}, you have a perfect match!",
+		},
+		models.NotificationSavedProductStatus: {
+			Title: "{{.product}} is no longer available",
+			Body:  "{{if eq .status \"swapped\"}}{{.product}}, which you saved, has been swapped.{{else}}{{.product}}, which you saved, was taken down by its seller.{{end}}",
+		},
+		models.NotificationSavedProductWants: {
+			Title: "{{.seller}} changed what they want for {{.product}}",
+			Body:  "{{.product}}, which you saved, is now up for {{.category}}{{with .size}} in size {{.}}{{end}}.",
+		},
+		models.NotificationSavedSearchMatches: {
+			Title: "{{.count}} new {{if eq .count \"1\"}}listing matches{{else}}listings match{{end}} '{{.search}}'",
+			Body:  "Take a look before someone else swaps {{if eq .count \"1\"}}it{{else}}them{{end}}.",
+		},
+		models.PreferenceMatches + ".group":  {Title: "{{.count}} new matches{{with .actor}} with {{.}}{{end}}"},
+		models.PreferenceMessages + ".group": {Title: "{{.count}} new messages{{with .actor}} from {{.}}{{end}}"},
+		models.PreferenceSaved + ".group":    {Title: "{{.count}} updates on items you saved"},
+		models.PreferenceSearches + ".group": {Title: "{{.count}} saved search alerts"},
+		groupSummaryKey:                      {Title: "{{.count}} new notifications{{with .actor}} from {{.}}{{end}}"},
+	},
+	"es": {
+		models.NotificationMutualMatch: {
+			Title: "Interés mutuo de intercambio",
+			Body:  "{{.other_user}} tiene {{.other_product}} y busca {{.other_wants}}; tú tienes {{.your_product}} y buscas {{.your_wants}}. ¡Es un intercambio perfecto!",
+		},
+		models.NotificationMutualInterest: {
+			Title: "Interés mutuo de intercambio",
+			Body:  "{{.other_user}} tiene {{.other_product}} y busca {{.other_wants}}; tú tienes {{.your_product}} y buscas {{.your_wants}}. ¡Es un intercambio perfecto!",
+		},
+		models.NotificationSavedProductStatus: {
+			Title: "{{.product}} ya no está disponible",
+			Body:  "{{if eq .status \"swapped\"}}{{.product}}, que guardaste, ya se intercambió.{{else}}{{.product}}, que guardaste, fue retirado por su vendedor.{{end}}",
+		},
+		models.NotificationSavedProductWants: {
+			Title: "{{.seller}} cambió lo que busca por {{.product}}",
+			Body:  "{{.product}}, que guardaste, ahora se cambia por {{.category}}{{with .size}} en talla {{.}}{{end}}.",
+		},
+		models.NotificationSavedSearchMatches: {
+			Title: "{{if eq .count \"1\"}}1 anuncio nuevo coincide{{else}}{{.count}} anuncios nuevos coinciden{{end}} con '{{.search}}'",
+			Body:  "{{if eq .count \"1\"}}Échale un vistazo antes de que otro lo intercambie.{{else}}Échales un vistazo antes de que otro los intercambie.{{end}}",
+		},
+		models.PreferenceMatches + ".group":  {Title: "{{.count}} coincidencias nuevas{{with .actor}} con {{.}}{{end}}"},
+		models.PreferenceMessages + ".group": {Title: "{{.count}} mensajes nuevos{{with .actor}} de {{.}}{{end}}"},
+		models.PreferenceSaved + ".group":    {Title: "{{.count}} novedades de artículos que guardaste"},
+		models.PreferenceSearches + ".group": {Title: "{{.count}} alertas de búsquedas guardadas"},
+		groupSummaryKey:                      {Title: "{{.count}} notificaciones nuevas{{with .actor}} de {{.}}{{end}}"},
+	},
+	"fr": {
+		models.NotificationMutualMatch: {
+			Title: "Intérêt mutuel d'échange",
+			Body:  "{{.other_user}} a {{.other_product}} et cherche {{.other_wants}} ; vous avez {{.your_product}} et cherchez {{.your_wants}}. C'est l'échange parfait !",
+		},
+		models.NotificationMutualInterest: {
+			Title: "Intérêt mutuel d'échange",
+			Body:  "{{.other_user}} a {{.other_product}} et cherche {{.other_wants}} ; vous avez {{.your_product}} et cherchez {{.your_wants}}. C'est l'échange parfait !",
+		},
+		models.NotificationSavedProductStatus: {
+			Title: "{{.product}} n'est plus disponible",
+			Body:  "{{if eq .status \"swapped\"}}{{.product}}, que vous avez enregistré, a été échangé.{{else}}{{.product}}, que vous avez enregistré, a été retiré par son vendeur.{{end}}",
+		},
+		models.NotificationSavedProductWants: {
+			Title: "{{.seller}} a modifié sa recherche pour {{.product}}",
+			Body:  "{{.product}}, que vous avez enregistré, s'échange maintenant contre {{.category}}{{with .size}} en taille {{.}}{{end}}.",
+		},
+		models.NotificationSavedSearchMatches: {
+			Title: "{{if eq .count \"1\"}}1 nouvelle annonce correspond{{else}}{{.count}} nouvelles annonces correspondent{{end}} à « {{.search}} »",
+			Body:  "Jetez un œil avant que quelqu'un d'autre ne {{if eq .count \"1\"}}l'échange{{else}}les échange{{end}}.",
+		},
+		models.PreferenceMatches + ".group":  {Title: "{{.count}} nouvelles correspondances{{with .actor}} avec {{.}}{{end}}"},
+		models.PreferenceMessages + ".group": {Title: "{{.count}} nouveaux messages{{with .actor}} de {{.}}{{end}}"},
+		models.PreferenceSaved + ".group":    {Title: "{{.count}} nouvelles sur des articles que vous avez enregistrés"},
+		models.PreferenceSearches + ".group": {Title: "{{.count}} alertes de recherches enregistrées"},
+		groupSummaryKey:                      {Title: "{{.count}} nouvelles notifications{{with .actor}} de {{.}}{{end}}"},
+	},
+}
+
+type notificationTemplate struct {
+	title *template.Template
+	body  *template.Template
+}
+
+// notificationTemplates is the parsed registry: language, then notification type
+var notificationTemplates = parseNotificationTemplates(notificationTemplateSources)
+
+func parseNotificationTemplates(sources map[string]map[string]notificationTemplateSource) map[string]map[string]notificationTemplate {
+	parsed := make(map[string]map[string]notificationTemplate, len(sources))
+	for language, types := range sources {
+		parsed[language] = make(map[string]notificationTemplate, len(types))
+		for notificationType, source := range types {
+			name := language + "/" + notificationType
+			parsed[language][notificationType] = notificationTemplate{
+				title: template.Must(template.New(name + "/title").Option("missingkey=error").Parse(source.Title)),
+				body:  template.Must(template.New(name + "/body").Option("missingkey=error").Parse(source.Body)),
+			}
+		}
+	}
+	return parsed
+}
+
+// NotificationLanguage returns language if notifications can be rendered in it, else the default
+func NotificationLanguage(language string) string {
+	language = strings.ToLower(language)
+	if slices.Contains(models.SupportedLanguages, language) {
+		return language
+	}
+	return models.DefaultLanguage
+}
+
+// lookupTemplate finds the template in the language, falling back to the default language
+func lookupTemplate(language, notificationType string) (notificationTemplate, bool) {
+	if tmpl, ok := notificationTemplates[NotificationLanguage(language)][notificationType]; ok {
+		return tmpl, true
+	}
+	tmpl, ok := notificationTemplates[models.DefaultLanguage][notificationType]
+	return tmpl, ok
+}
+
+func execute(tmpl *template.Template, params models.NotificationParams) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, map[string]string(params)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderNotification renders the title and message of a notification type in the language
+func RenderNotification(language, notificationType string, params models.NotificationParams) (string, string, error) {
+	tmpl, ok := lookupTemplate(language, notificationType)
+	if !ok {
+		return "", "", fmt.Errorf("no template for notification type %q", notificationType)
+	}
+
+	title, err := execute(tmpl.title, params)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render %q title: %w", notificationType, err)
+	}
+
+	message, err := execute(tmpl.body, params)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render %q message: %w", notificationType, err)
+	}
+
+	return title, message, nil
+}
+
+// LocalizeNotification re-renders a stored notification in the reader's language.
+// Notifications without params, or whose params no longer fit the template, keep their stored text.
+func LocalizeNotification(n *models.Notifications, language string) {
+	if n.Params == nil {
+		return
+	}
+
+	title, message, err := RenderNotification(language, n.Notification_type, n.Params)
+	if err != nil {
+		return
+	}
+
+	n.Title, n.Message = title, message
+}
+
+// NotificationGroupSummary describes a group in one line, a single notification keeps its own title
+func NotificationGroupSummary(language, notificationType string, count int, actorName *string, latestTitle string) string {
+	if count <= 1 {
+		return latestTitle
+	}
+
+	tmpl, ok := lookupTemplate(language, models.PreferenceTypeFor(notificationType)+".group")
+	if !ok {
+		tmpl, _ = lookupTemplate(language, groupSummaryKey)
+	}
+
+	params := models.NotificationParams{"count": strconv.Itoa(count), "actor": ""}
+	if actorName != nil {
+		params["actor"] = *actorName
+	}
+
+	summary, err := execute(tmpl.title, params)
+	if err != nil {
+		return latestTitle
+	}
+	return summary
+}
+
+// digestTemplateData is what the digest email templates are rendered with
+type digestTemplateData struct {
+	Username      string
+	Daily         bool
+	UnreadCount   int
+	NewMatchCount int
+	Notifications []models.Notifications
+	More          int
+}
+
+// digest subject and body per language, the body lists the notifications in the digest
+var digestTemplateSources = map[string]notificationTemplateSource{
+	"en": {
+		Title: "Your {{if .Daily}}day{{else}}week{{end}} on PointSwap: " +
+			"{{if .NewMatchCount}}{{.NewMatchCount}} new matches{{else}}{{.UnreadCount}} unread notifications{{end}}",
+		Body: "Hi {{.Username}},\n\n" +
+			"You have {{.UnreadCount}} unread notifications{{if .NewMatchCount}}, including {{.NewMatchCount}} new matches{{end}}.\n\n" +
+			"{{range .Notifications}}- {{.Title}}: {{.Message}}\n{{end}}" +
+			"{{if .More}}...and {{.More}} more\n{{end}}" +
+			"\nOpen the app to catch up. You can change how often you get this email in your notification settings.\n",
+	},
+	"es": {
+		Title: "Tu {{if .Daily}}día{{else}}semana{{end}} en PointSwap: " +
+			"{{if .NewMatchCount}}{{.NewMatchCount}} coincidencias nuevas{{else}}{{.UnreadCount}} notificaciones sin leer{{end}}",
+		Body: "Hola {{.Username}}:\n\n" +
+			"Tienes {{.UnreadCount}} notificaciones sin leer{{if .NewMatchCount}}, con {{.NewMatchCount}} coincidencias nuevas{{end}}.\n\n" +
+			"{{range .Notifications}}- {{.Title}}: {{.Message}}\n{{end}}" +
+			"{{if .More}}...y {{.More}} más\n{{end}}" +
+			"\nAbre la app para ponerte al día. Puedes cambiar cada cuánto recibes este correo en los ajustes de notificaciones.\n",
+	},
+	"fr": {
+		Title: "Votre {{if .Daily}}journée{{else}}semaine{{end}} sur PointSwap : " +
+			"{{if .NewMatchCount}}{{.NewMatchCount}} nouvelles correspondances{{else}}{{.UnreadCount}} notifications non lues{{end}}",
+		Body: "Bonjour {{.Username}},\n\n" +
+			"Vous avez {{.UnreadCount}} notifications non lues{{if .NewMatchCount}}, dont {{.NewMatchCount}} nouvelles correspondances{{end}}.\n\n" +
+			"{{range .Notifications}}- {{.Title}} : {{.Message}}\n{{end}}" +
+			"{{if .More}}...et {{.More}} de plus\n{{end}}" +
+			"\nOuvrez l'application pour rattraper votre retard. Vous pouvez changer la fréquence de cet e-mail dans vos réglages de notifications.\n",
+	},
+}
+
+var digestTemplates = parseDigestTemplates(digestTemplateSources)
+
+func parseDigestTemplates(sources map[string]notificationTemplateSource) map[string]notificationTemplate {
+	parsed := make(map[string]notificationTemplate, len(sources))
+	for language, source := range sources {
+		parsed[language] = notificationTemplate{
+			title: template.Must(template.New(language + "/digest/subject").Parse(source.Title)),
+			body:  template.Must(template.New(language + "/digest/body").Parse(source.Body)),
+		}
+	}
+	return parsed
+}
+
+// RenderDigest renders the subject and body of a digest email in the recipient's language
+func RenderDigest(recipient models.DigestRecipient, digest *models.Digest) (string, string, error) {
+	tmpl := digestTemplates[NotificationLanguage(recipient.Language)]
+
+	data := digestTemplateData{
+		Username:      recipient.Username,
+		Daily:         recipient.Frequency == models.DigestDaily,
+		UnreadCount:   digest.UnreadCount,
+		NewMatchCount: digest.NewMatchCount,
+		Notifications: digest.Notifications,
+		More:          digest.UnreadCount - len(digest.Notifications),
+	}
+
+	var subject, body strings.Builder
+	if err := tmpl.title.Execute(&subject, data); err != nil {
+		return "", "", fmt.Errorf("failed to render digest subject: %w", err)
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("failed to render digest body: %w", err)
+	}
+
+	return subject.String(), body.String(), nil
+}
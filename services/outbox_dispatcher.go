@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"postswapapi/metrics"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	outboxBatchSize    = 100
+	outboxPollInterval = 2 * time.Second
+	outboxLease        = 30 * time.Second
+	outboxMaxAttempts  = 10
+	outboxBaseBackoff  = time.Second
+	outboxMaxBackoff   = 10 * time.Minute
+	outboxRetention    = 24 * time.Hour
+)
+
+// OutboxDispatcher delivers outbox entries after the transaction that wrote them commits.
+// Failed deliveries back off exponentially and are dead-lettered after outboxMaxAttempts.
+// Delivery is at least once, realtime payloads carry event_id (the idempotency key) so clients can drop repeats.
+type OutboxDispatcher struct {
+	repo       *repository.OutboxRepository
+	publisher  RealtimePublisher
+	events     *EventService
+	push       *PushService
+	moderation *ModerationService
+	notify     NotificationDeliverer
+	wake       chan struct{}
+}
+
+func NewOutboxDispatcher(repo *repository.OutboxRepository, publisher RealtimePublisher, events *EventService) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		repo:      repo,
+		publisher: publisher,
+		events:    events,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// SetPush routes chat message pushes to the push service, without it push entries are dropped
+func (d *OutboxDispatcher) SetPush(push *PushService) {
+	d.push = push
+}
+
+// NotificationDeliverer saves a queued notification under notificationID, delivering the same id twice saves it once
+type NotificationDeliverer func(ctx context.Context, notificationID uuid.UUID, job models.NotificationJob) error
+
+// SetNotifier saves queued notifications, handlers.DeliverNotification in the server. Without it they are dropped.
+func (d *OutboxDispatcher) SetNotifier(notify NotificationDeliverer) {
+	d.notify = notify
+}
+
+// SetModeration screens new listings from their outbox entries, without it screening entries are dropped
+func (d *OutboxDispatcher) SetModeration(moderation *ModerationService) {
+	d.moderation = moderation
+}
+
+// Notify wakes the dispatcher after a commit instead of waiting for the next poll, safe on a nil dispatcher
+func (d *OutboxDispatcher) Notify() {
+	if d == nil {
+		return
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due entries until ctx is cancelled, polling so entries written by other instances
+// and retries are picked up, and prunes delivered entries once an hour
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	poll := time.NewTicker(outboxPollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		d.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-poll.C:
+		case <-prune.C:
+			removed, err := d.repo.DeleteDispatchedBefore(ctx, time.Now().Add(-outboxRetention))
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to prune outbox", "error", err)
+			} else if removed > 0 {
+				slog.InfoContext(ctx, "pruned outbox", "removed", removed)
+			}
+		}
+	}
+}
+
+// drain dispatches batches until nothing is due
+func (d *OutboxDispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		entries, err := d.repo.ClaimDue(ctx, outboxBatchSize, outboxLease)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to claim outbox entries", "error", err)
+			return
+		}
+
+		for _, entry := range entries {
+			d.dispatch(ctx, entry)
+		}
+
+		if len(entries) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (d *OutboxDispatcher) dispatch(ctx context.Context, entry models.OutboxEntry) {
+	err := d.deliver(ctx, entry)
+	if err == nil {
+		if err := d.repo.MarkDispatched(ctx, entry.ID); err != nil {
+			// the lease runs out and the entry is delivered again, the idempotency key covers it
+			slog.WarnContext(ctx, "failed to mark outbox entry dispatched", "outbox_id", entry.ID, "error", err)
+		}
+		return
+	}
+
+	if entry.Attempts >= outboxMaxAttempts {
+		metrics.OutboxDeadLettered.WithLabelValues(entry.EventType).Inc()
+		slog.ErrorContext(ctx, "dead-lettering outbox entry", "outbox_id", entry.ID, "event", entry.EventType, "attempts", entry.Attempts, "error", err)
+		if err := d.repo.MarkDead(ctx, entry.ID, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "failed to dead-letter outbox entry", "outbox_id", entry.ID, "error", err)
+		}
+		return
+	}
+
+	metrics.OutboxRetries.WithLabelValues(entry.EventType).Inc()
+	slog.WarnContext(ctx, "outbox delivery failed, retrying", "outbox_id", entry.ID, "event", entry.EventType, "attempts", entry.Attempts, "error", err)
+	if err := d.repo.MarkRetry(ctx, entry.ID, time.Now().Add(outboxBackoff(entry.Attempts)), err.Error()); err != nil {
+		slog.ErrorContext(ctx, "failed to reschedule outbox entry", "outbox_id", entry.ID, "error", err)
+	}
+}
+
+func (d *OutboxDispatcher) deliver(ctx context.Context, entry models.OutboxEntry) error {
+	switch entry.Destination {
+	case models.OutboxRealtime:
+		var payload map[string]any
+		if err := json.Unmarshal(entry.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		payload["event_id"] = entry.IdempotencyKey.String()
+
+		var err error
+		if publisher, ok := d.publisher.(IdempotentPublisher); ok {
+			err = publisher.PublishIdempotent(ctx, entry.IdempotencyKey.String(), entry.Channel, entry.EventType, payload)
+		} else {
+			err = d.publisher.Publish(ctx, entry.Channel, entry.EventType, payload)
+		}
+		if err != nil {
+			metrics.RealtimePublishFailures.WithLabelValues(entry.EventType).Inc()
+		}
+		return err
+
+	case models.OutboxUserEvent:
+		if entry.UserID == nil {
+			return fmt.Errorf("user event without a user")
+		}
+		return d.events.PublishOnce(ctx, entry.IdempotencyKey, *entry.UserID, entry.EventType, entry.Payload)
+
+	case models.OutboxPush:
+		if d.push == nil {
+			return nil
+		}
+		var message models.MessageNotification
+		if err := json.Unmarshal(entry.Payload, &message); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		return d.push.PushChatMessage(ctx, message)
+
+	case models.OutboxModeration:
+		if d.moderation == nil {
+			return nil
+		}
+		var job models.ScreenProductJob
+		if err := json.Unmarshal(entry.Payload, &job); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		return d.moderation.ScreenProduct(ctx, job.ProductID)
+
+	case models.OutboxNotification:
+		if d.notify == nil {
+			return nil
+		}
+		var job models.NotificationJob
+		if err := json.Unmarshal(entry.Payload, &job); err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
+		}
+		return d.notify(ctx, entry.IdempotencyKey, job)
+	}
+
+	return fmt.Errorf("unknown outbox destination %q", entry.Destination)
+}
+
+// outboxBackoff doubles the wait after each failed attempt, up to outboxMaxBackoff
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"postswapapi/metrics"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// a user is offline once nothing has heard from them for this long
+	presenceTimeout = 60 * time.Second
+	// heartbeats inside this window skip the database write
+	presenceWriteInterval = 20 * time.Second
+)
+
+// PresenceService derives users.is_online/last_seen from realtime activity.
+// Open WebSocket and SSE connections heartbeat through Touch, Ably clients call the heartbeat endpoint,
+// and Run marks users offline once their heartbeats stop.
+type PresenceService struct {
+	repo      *repository.PresenceRepository
+	messages  *repository.MessageRepository
+	publisher RealtimePublisher
+
+	mu        sync.Mutex
+	lastWrite map[uuid.UUID]time.Time
+}
+
+func NewPresenceService(repo *repository.PresenceRepository, messages *repository.MessageRepository, publisher RealtimePublisher) *PresenceService {
+	return &PresenceService{
+		repo:      repo,
+		messages:  messages,
+		publisher: publisher,
+		lastWrite: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Touch records activity from the user, publishing a presence event if they just came online
+func (s *PresenceService) Touch(ctx context.Context, userID uuid.UUID) {
+	now := time.Now()
+
+	s.mu.Lock()
+	if now.Sub(s.lastWrite[userID]) < presenceWriteInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastWrite[userID] = now
+	s.mu.Unlock()
+
+	cameOnline, err := s.repo.TouchOnline(ctx, userID)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to record presence heartbeat", "user_id", userID, "error", err)
+		return
+	}
+
+	if cameOnline {
+		s.publishPresence(ctx, models.PresenceEvent{UserID: userID, IsOnline: true, LastSeen: now})
+	}
+}
+
+// Run expires stale users every presenceTimeout/2 until ctx is cancelled
+func (s *PresenceService) Run(ctx context.Context) {
+	ticker := time.NewTicker(presenceTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expire(ctx)
+		}
+	}
+}
+
+func (s *PresenceService) expire(ctx context.Context) {
+	expired, err := s.repo.ExpireOnline(ctx, time.Now().Add(-presenceTimeout))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to expire presence", "error", err)
+		return
+	}
+
+	for userID, lastSeen := range expired {
+		s.mu.Lock()
+		delete(s.lastWrite, userID)
+		s.mu.Unlock()
+
+		s.publishPresence(ctx, models.PresenceEvent{UserID: userID, IsOnline: false, LastSeen: lastSeen})
+	}
+}
+
+// publishPresence tells every conversation the user is in about the change
+func (s *PresenceService) publishPresence(ctx context.Context, event models.PresenceEvent) {
+	conversationIDs, err := s.messages.GetUserConversationIDs(ctx, event.UserID)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load conversations for presence", "user_id", event.UserID, "error", err)
+		return
+	}
+
+	for _, conversationID := range conversationIDs {
+		if err := s.publisher.Publish(ctx, ConversationChannel(conversationID), models.RealtimePresence, event); err != nil {
+			metrics.RealtimePublishFailures.WithLabelValues(models.RealtimePresence).Inc()
+			slog.WarnContext(ctx, "failed to publish presence", "conversation_id", conversationID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidPushToken is returned by a PushSender when the device token is no longer registered with the provider
+var ErrInvalidPushToken = errors.New("push token is no longer valid")
+
+// PushMessage is one notification shown on a device, data is passed to the app untouched
+type PushMessage struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// PushSender delivers a push to a single device token
+type PushSender interface {
+	Send(ctx context.Context, token string, message PushMessage) error
+}
+
+// NewPushSender builds the sender named by PUSH_BACKEND: "fcm" or "memory".
+// When unset it uses FCM if FCM_CREDENTIALS_FILE is configured and the in-memory sender otherwise.
+func NewPushSender() (PushSender, error) {
+	backend := strings.ToLower(os.Getenv("PUSH_BACKEND"))
+	if backend == "" {
+		backend = "memory"
+		if os.Getenv("FCM_CREDENTIALS_FILE") != "" {
+			backend = "fcm"
+		}
+	}
+
+	switch backend {
+	case "fcm":
+		return NewFCMSender(os.Getenv("FCM_CREDENTIALS_FILE"))
+	case "memory":
+		return NewMemoryPushSender(), nil
+	default:
+		return nil, fmt.Errorf("unknown PUSH_BACKEND %q", backend)
+	}
+}
+
+// SentPush is one push captured by MemoryPushSender
+type SentPush struct {
+	Token   string
+	Message PushMessage
+}
+
+// MemoryPushSender logs pushes and keeps them in memory, for tests and local runs without FCM
+type MemoryPushSender struct {
+	mu   sync.Mutex
+	sent []SentPush
+}
+
+func NewMemoryPushSender() *MemoryPushSender {
+	return &MemoryPushSender{}
+}
+
+func (s *MemoryPushSender) Send(ctx context.Context, token string, message PushMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slog.DebugContext(ctx, "push sent", "title", message.Title, "body", message.Body)
+	s.sent = append(s.sent, SentPush{Token: token, Message: message})
+	return nil
+}
+
+// Sent returns a copy of every push sent so far
+func (s *MemoryPushSender) Sent() []SentPush {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]SentPush(nil), s.sent...)
+}
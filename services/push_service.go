@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	pushPollInterval = 5 * time.Second
+	pushBatchSize    = 100
+	// notifications older than this are left in the app rather than pushed late
+	pushMaxAge = 24 * time.Hour
+)
+
+// PushService manages device tokens and sends pushes: unpushed notifications are picked up by Run,
+// chat messages arrive through the outbox and only go to participants who are offline
+type PushService struct {
+	repo   *repository.PushRepository
+	sender PushSender
+}
+
+func NewPushService(repo *repository.PushRepository, sender PushSender) *PushService {
+	return &PushService{repo: repo, sender: sender}
+}
+
+// RegisterDevice saves a push token for the user
+func (s *PushService) RegisterDevice(ctx context.Context, userID uuid.UUID, token, platform string) (*models.Device, error) {
+	return s.repo.RegisterDevice(ctx, userID, token, platform)
+}
+
+// UnregisterDevice removes one of the user's push tokens
+func (s *PushService) UnregisterDevice(ctx context.Context, userID uuid.UUID, token string) error {
+	return s.repo.UnregisterDevice(ctx, userID, token)
+}
+
+// Run pushes new notifications until ctx is cancelled
+func (s *PushService) Run(ctx context.Context) {
+	ticker := time.NewTicker(pushPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.pushNotifications(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PushService) pushNotifications(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := s.repo.ClaimUnpushedNotifications(ctx, time.Now().Add(-pushMaxAge), pushBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to claim notifications for push", "error", err)
+			return
+		}
+
+		for _, delivery := range deliveries {
+			notification := delivery.Notifications
+			LocalizeNotification(&notification, delivery.Language)
+
+			data := map[string]string{
+				"notification_id":   notification.Notification_ID.String(),
+				"notification_type": notification.Notification_type,
+				"deep_link":         notification.Deep_link,
+			}
+			if notification.Related_product_ID != nil {
+				data["product_id"] = notification.Related_product_ID.String()
+			}
+
+			s.sendToUsers(ctx, []uuid.UUID{notification.User_ID}, PushMessage{
+				Title: notification.Title,
+				Body:  notification.Message,
+				Data:  data,
+			})
+		}
+
+		if len(deliveries) < pushBatchSize {
+			return
+		}
+	}
+}
+
+// PushChatMessage sends a new message to the conversation's offline participants.
+// Only lookups can fail it, failures on individual devices are logged so the outbox doesn't push twice.
+func (s *PushService) PushChatMessage(ctx context.Context, message models.MessageNotification) error {
+	recipients, err := s.repo.GetOfflineParticipants(ctx, message.ConversationID, message.SenderID)
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+
+	senderName, err := s.repo.GetSenderName(ctx, message.SenderID)
+	if err != nil {
+		return err
+	}
+
+	s.sendToUsers(ctx, recipients, PushMessage{
+		Title: senderName,
+		Body:  message.Preview,
+		Data: map[string]string{
+			"notification_type": message.NotificationType,
+			"conversation_id":   message.ConversationID.String(),
+			"message_id":        message.MessageID.String(),
+			"message_type":      message.MessageType,
+		},
+	})
+
+	return nil
+}
+
+// sendToUsers pushes to every device of the users and forgets tokens the provider no longer accepts
+func (s *PushService) sendToUsers(ctx context.Context, userIDs []uuid.UUID, message PushMessage) {
+	devices, err := s.repo.GetDevices(ctx, userIDs)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load devices for push", "error", err)
+		return
+	}
+
+	var invalid []string
+	for _, userDevices := range devices {
+		for _, device := range userDevices {
+			err := s.sender.Send(ctx, device.Token, message)
+			if errors.Is(err, ErrInvalidPushToken) {
+				invalid = append(invalid, device.Token)
+			} else if err != nil {
+				slog.WarnContext(ctx, "failed to send push", "user_id", device.UserID, "device_id", device.DeviceID, "error", err)
+			}
+		}
+	}
+
+	if err := s.repo.DeleteTokens(ctx, invalid); err != nil {
+		slog.WarnContext(ctx, "failed to prune invalid push tokens", "error", err)
+	} else if len(invalid) > 0 {
+		slog.InfoContext(ctx, "pruned invalid push tokens", "count", len(invalid))
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"postswapapi/repository"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RealtimePublisher delivers events to the clients subscribed to a channel
+type RealtimePublisher interface {
+	Publish(ctx context.Context, channel, event string, payload any) error
+	Close()
+}
+
+// IdempotentPublisher is implemented by backends that can drop redeliveries of the same event themselves (Ably).
+// The outbox passes its idempotency key, clients of other backends dedupe on the payload's event_id.
+type IdempotentPublisher interface {
+	PublishIdempotent(ctx context.Context, id, channel, event string, payload any) error
+}
+
+// TokenIssuer is implemented by backends that hand clients their own credential (Ably).
+// Backends without it authenticate clients with the API's JWT directly.
+type TokenIssuer interface {
+	ClientToken(ctx context.Context, userID uuid.UUID) (string, error)
+}
+
+// ChannelAuthorizer decides whether a user may subscribe to a channel
+type ChannelAuthorizer func(ctx context.Context, userID uuid.UUID, channel string) (bool, error)
+
+const conversationChannelPrefix = "conversation:"
+
+// ConversationChannel returns the realtime channel name for a conversation
+func ConversationChannel(conversationID uuid.UUID) string {
+	return conversationChannelPrefix + conversationID.String()
+}
+
+// ConversationAuthorizer only lets participants subscribe to a conversation's channel
+func ConversationAuthorizer(repo *repository.MessageRepository) ChannelAuthorizer {
+	return func(ctx context.Context, userID uuid.UUID, channel string) (bool, error) {
+		idStr, ok := strings.CutPrefix(channel, conversationChannelPrefix)
+		if !ok {
+			return false, nil
+		}
+
+		conversationID, err := uuid.Parse(idStr)
+		if err != nil {
+			return false, nil
+		}
+
+		return repo.VerifyUserInConversation(ctx, conversationID, userID)
+	}
+}
+
+// NewRealtimePublisher builds the backend named by REALTIME_BACKEND: "ably", "websocket" or "memory".
+// When unset it uses Ably if ABLY_KEY is configured and the built-in WebSocket hub otherwise.
+func NewRealtimePublisher(authorize ChannelAuthorizer) (RealtimePublisher, error) {
+	backend := strings.ToLower(os.Getenv("REALTIME_BACKEND"))
+	if backend == "" {
+		backend = "websocket"
+		if os.Getenv("ABLY_KEY") != "" {
+			backend = "ably"
+		}
+	}
+
+	switch backend {
+	case "ably":
+		return NewAblyPublisher(os.Getenv("ABLY_KEY"))
+	case "websocket":
+		return NewWebSocketHub(authorize), nil
+	case "memory":
+		return NewMemoryPublisher(), nil
+	default:
+		return nil, fmt.Errorf("unknown REALTIME_BACKEND %q", backend)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ably/ably-go/ably"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AblyPublisher publishes through Ably, clients subscribe with a token from ClientToken
+type AblyPublisher struct {
+	client *ably.Realtime
+	rest   *ably.REST
+}
+
+func NewAblyPublisher(apiKey string) (*AblyPublisher, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("ABLY_KEY environment variable not set")
+	}
+
+	client, err := ably.NewRealtime(
+		ably.WithKey(apiKey),
+		ably.WithEchoMessages(false), // Don't echo messages back to sender
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ably client: %w", err)
+	}
+
+	// REST client for token generation
+	rest, err := ably.NewREST(ably.WithKey(apiKey))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create REST client: %w", err)
+	}
+
+	return &AblyPublisher{client: client, rest: rest}, nil
+}
+
+func (p *AblyPublisher) Publish(ctx context.Context, channel, event string, payload any) error {
+	return p.PublishIdempotent(ctx, "", channel, event, payload)
+}
+
+// PublishIdempotent publishes with a client supplied message id, Ably drops repeats of the same id
+func (p *AblyPublisher) PublishIdempotent(ctx context.Context, id, channel, event string, payload any) error {
+	ctx, span := tracer.Start(ctx, "ably.publish", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "ably"),
+			attribute.String("messaging.destination.name", channel),
+			attribute.String("messaging.operation.name", event),
+		))
+	defer span.End()
+
+	message := &ably.Message{ID: id, Name: event, Data: payload}
+	if err := p.client.Channels.Get(channel).PublishMultiple(ctx, []*ably.Message{message}); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "ably publish failed")
+		return fmt.Errorf("failed to publish to ably: %w", err)
+	}
+
+	return nil
+}
+
+// ClientToken creates a token for client-side Ably authentication
+// This is important for security - clients shouldn't have your API key
+func (p *AblyPublisher) ClientToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	ctx, span := tracer.Start(ctx, "ably.request_token", trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	// Request a token with user's ID as ClientID
+	token, err := p.rest.Auth.RequestToken(ctx, &ably.TokenParams{
+		ClientID: userID.String(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "ably token request failed")
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+
+	return token.Token, nil
+}
+
+// Close cleanly closes the Ably connection
+func (p *AblyPublisher) Close() {
+	p.client.Close()
+}
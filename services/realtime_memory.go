@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// PublishedEvent is one event captured by MemoryPublisher
+type PublishedEvent struct {
+	Channel string
+	Event   string
+	Payload any
+}
+
+// MemoryPublisher keeps published events in memory, for tests and local runs without a realtime backend
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []PublishedEvent
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, channel, event string, payload any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, PublishedEvent{Channel: channel, Event: event, Payload: payload})
+	return nil
+}
+
+// Events returns a copy of everything published so far
+func (p *MemoryPublisher) Events() []PublishedEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]PublishedEvent(nil), p.events...)
+}
+
+// Reset forgets all captured events
+func (p *MemoryPublisher) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = nil
+}
+
+func (p *MemoryPublisher) Close() {}
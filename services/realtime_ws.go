@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"postswapapi/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"nhooyr.io/websocket"
+)
+
+const (
+	wsSendBuffer   = 64
+	wsWriteTimeout = 10 * time.Second
+	wsReadLimit    = 4096
+	wsPingInterval = 20 * time.Second
+)
+
+// wsEnvelope is what the hub sends to clients
+type wsEnvelope struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel,omitempty"`
+	Event   string `json:"event,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// wsCommand is what clients send to the hub
+type wsCommand struct {
+	Action   string `json:"action"`
+	Channel  string `json:"channel"`
+	IsTyping bool   `json:"is_typing"`
+}
+
+type wsClient struct {
+	userID   uuid.UUID
+	send     chan []byte
+	channels map[string]struct{}
+}
+
+// WebSocketHub is the self-hosted realtime backend. Clients connect with their JWT,
+// send {"action":"subscribe","channel":"conversation:<id>"} and receive
+// {"type":"event","channel":...,"event":...,"data":...} for everything published there.
+// {"action":"typing","channel":...,"is_typing":true} on a subscribed channel publishes a typing event.
+type WebSocketHub struct {
+	authorize ChannelAuthorizer
+	origins   []string
+
+	mu       sync.RWMutex
+	channels map[string]map[*wsClient]struct{}
+	clients  map[*wsClient]struct{}
+}
+
+func NewWebSocketHub(authorize ChannelAuthorizer) *WebSocketHub {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+
+	return &WebSocketHub{
+		authorize: authorize,
+		origins:   origins,
+		channels:  make(map[string]map[*wsClient]struct{}),
+		clients:   make(map[*wsClient]struct{}),
+	}
+}
+
+func (h *WebSocketHub) Publish(ctx context.Context, channel, event string, payload any) error {
+	msg, err := json.Marshal(wsEnvelope{Type: "event", Channel: channel, Event: event, Data: payload})
+	if err != nil {
+		return fmt.Errorf("failed to encode realtime event: %w", err)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.channels[channel] {
+		select {
+		case client.send <- msg:
+		default:
+			// a client this far behind is dropped rather than blocking everyone else
+			slog.WarnContext(ctx, "dropping realtime event for slow client", "channel", channel, "user_id", client.userID)
+		}
+	}
+
+	return nil
+}
+
+// Serve upgrades the request and runs the connection for an already authenticated user until it closes.
+// heartbeat is called whenever the client proves it is still there, a command or an answered ping.
+func (h *WebSocketHub) Serve(w http.ResponseWriter, r *http.Request, userID uuid.UUID, heartbeat func(context.Context)) error {
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.origins})
+	if err != nil {
+		return fmt.Errorf("failed to accept websocket: %w", err)
+	}
+	defer conn.CloseNow()
+
+	conn.SetReadLimit(wsReadLimit)
+
+	client := &wsClient{
+		userID:   userID,
+		send:     make(chan []byte, wsSendBuffer),
+		channels: make(map[string]struct{}),
+	}
+	h.register(client)
+	defer h.unregister(client)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	heartbeat(ctx)
+
+	go h.writeLoop(ctx, cancel, conn, client)
+	go h.pingLoop(ctx, cancel, conn, heartbeat)
+
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			if status := websocket.CloseStatus(err); status == websocket.StatusNormalClosure || status == websocket.StatusGoingAway || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+
+		heartbeat(ctx)
+
+		var cmd wsCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			h.reply(client, wsEnvelope{Type: "error", Message: "invalid command"})
+			continue
+		}
+
+		h.handleCommand(ctx, client, cmd)
+	}
+}
+
+func (h *WebSocketHub) handleCommand(ctx context.Context, client *wsClient, cmd wsCommand) {
+	switch cmd.Action {
+	case "subscribe":
+		allowed, err := h.authorize(ctx, client.userID, cmd.Channel)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to authorize realtime subscription", "channel", cmd.Channel, "error", err)
+			h.reply(client, wsEnvelope{Type: "error", Channel: cmd.Channel, Message: "could not subscribe"})
+			return
+		}
+		if !allowed {
+			h.reply(client, wsEnvelope{Type: "error", Channel: cmd.Channel, Message: "not allowed to subscribe to this channel"})
+			return
+		}
+
+		h.subscribe(client, cmd.Channel)
+		h.reply(client, wsEnvelope{Type: "subscribed", Channel: cmd.Channel})
+	case "unsubscribe":
+		h.unsubscribe(client, cmd.Channel)
+		h.reply(client, wsEnvelope{Type: "unsubscribed", Channel: cmd.Channel})
+	case "typing":
+		h.typing(ctx, client, cmd)
+	default:
+		h.reply(client, wsEnvelope{Type: "error", Message: "unknown action"})
+	}
+}
+
+func (h *WebSocketHub) writeLoop(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, client *wsClient) {
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-client.send:
+			writeCtx, done := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Write(writeCtx, websocket.MessageText, msg)
+			done()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// typing relays a typing indicator, only on channels the client already passed authorization for
+func (h *WebSocketHub) typing(ctx context.Context, client *wsClient, cmd wsCommand) {
+	h.mu.RLock()
+	_, subscribed := client.channels[cmd.Channel]
+	h.mu.RUnlock()
+
+	conversationID, err := uuid.Parse(strings.TrimPrefix(cmd.Channel, conversationChannelPrefix))
+	if !subscribed || err != nil {
+		h.reply(client, wsEnvelope{Type: "error", Channel: cmd.Channel, Message: "subscribe to the conversation before sending typing events"})
+		return
+	}
+
+	event := models.TypingEvent{ConversationID: conversationID, UserID: client.userID, IsTyping: cmd.IsTyping}
+	if err := h.Publish(ctx, cmd.Channel, models.RealtimeTyping, event); err != nil {
+		slog.WarnContext(ctx, "failed to relay typing event", "channel", cmd.Channel, "error", err)
+	}
+}
+
+// pingLoop keeps the connection alive and detects dead peers, every pong counts as a heartbeat
+func (h *WebSocketHub) pingLoop(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, heartbeat func(context.Context)) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, done := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Ping(pingCtx)
+			done()
+			if err != nil {
+				cancel()
+				return
+			}
+			heartbeat(ctx)
+		}
+	}
+}
+
+func (h *WebSocketHub) reply(client *wsClient, env wsEnvelope) {
+	msg, err := json.Marshal(env)
+	if err != nil {
+		return
+	}
+
+	select {
+	case client.send <- msg:
+	default:
+	}
+}
+
+func (h *WebSocketHub) register(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clients[client] = struct{}{}
+}
+
+func (h *WebSocketHub) unregister(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for channel := range client.channels {
+		h.removeFromChannel(client, channel)
+	}
+	delete(h.clients, client)
+}
+
+func (h *WebSocketHub) subscribe(client *wsClient, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.channels[channel] == nil {
+		h.channels[channel] = make(map[*wsClient]struct{})
+	}
+	h.channels[channel][client] = struct{}{}
+	client.channels[channel] = struct{}{}
+}
+
+func (h *WebSocketHub) unsubscribe(client *wsClient, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeFromChannel(client, channel)
+}
+
+// removeFromChannel expects h.mu to be held
+func (h *WebSocketHub) removeFromChannel(client *wsClient, channel string) {
+	delete(client.channels, channel)
+
+	subscribers := h.channels[channel]
+	delete(subscribers, client)
+	if len(subscribers) == 0 {
+		delete(h.channels, channel)
+	}
+}
+
+// Evict unsubscribes every connection the user has on the channel, used when they leave a conversation
+func (h *WebSocketHub) Evict(channel string, userID uuid.UUID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for client := range h.channels[channel] {
+		if client.userID == userID {
+			h.removeFromChannel(client, channel)
+		}
+	}
+}
+
+// Close drops every open connection's subscriptions, connections end when the server shuts down
+func (h *WebSocketHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.channels = make(map[string]map[*wsClient]struct{})
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"postswapapi/models"
+	"postswapapi/repository"
+	"postswapapi/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// instant searches are checked this often, daily and weekly ones on the first run after they are due
+	savedSearchPollInterval = 10 * time.Minute
+	savedSearchBatchSize    = 100
+	// listings created in the last minute may not be committed yet, they are left for the next check
+	savedSearchCommitLag = time.Minute
+)
+
+// Notifier creates a notification, handlers.CreateNotification in the server
+type Notifier func(userID uuid.UUID, notificationType string, params models.NotificationParams, relatedProductID, relatedUserID *uuid.UUID)
+
+// SavedSearchService stores saved searches and alerts their owners about new matching listings. Each alert
+// covers every listing created since the search was last checked, instant searches are checked every
+// savedSearchPollInterval and daily and weekly ones once a day or week.
+type SavedSearchService struct {
+	repo   *repository.SavedSearchRepository
+	notify Notifier
+}
+
+func NewSavedSearchService(repo *repository.SavedSearchRepository) *SavedSearchService {
+	return &SavedSearchService{repo: repo}
+}
+
+// SetNotifier sets how alerts are delivered, without one the job only advances the searches
+func (s *SavedSearchService) SetNotifier(notify Notifier) {
+	s.notify = notify
+}
+
+func (s *SavedSearchService) Create(ctx context.Context, userID uuid.UUID, req models.CreateSavedSearchRequest) (*models.SavedSearch, error) {
+	count, err := s.repo.CountSavedSearches(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= models.MaxSavedSearches {
+		return nil, utils.NewValidation("you have too many saved searches, delete one first", utils.FieldError{
+			Field: "query", Rule: "max_saved_searches", Message: "at most " + strconv.Itoa(models.MaxSavedSearches) + " saved searches",
+		})
+	}
+
+	search := &models.SavedSearch{
+		SearchID:  uuid.New(),
+		UserID:    userID,
+		Query:     strings.TrimSpace(req.Query),
+		Category:  optionalFilter(req.Category),
+		Size:      optionalFilter(req.Size),
+		RadiusKm:  req.RadiusKm,
+		Frequency: req.Frequency,
+	}
+	if search.Frequency == "" {
+		search.Frequency = models.SearchFrequencyDaily
+	}
+	if err := validateSavedSearch(search); err != nil {
+		return nil, err
+	}
+
+	return s.repo.CreateSavedSearch(ctx, search)
+}
+
+func (s *SavedSearchService) List(ctx context.Context, userID uuid.UUID) ([]models.SavedSearch, error) {
+	return s.repo.ListSavedSearches(ctx, userID)
+}
+
+func (s *SavedSearchService) Update(ctx context.Context, userID, searchID uuid.UUID, req models.UpdateSavedSearchRequest) (*models.SavedSearch, error) {
+	search, err := s.repo.GetSavedSearch(ctx, searchID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Query != nil {
+		search.Query = strings.TrimSpace(*req.Query)
+	}
+	if req.Category != nil {
+		search.Category = optionalFilter(req.Category)
+	}
+	if req.Size != nil {
+		search.Size = optionalFilter(req.Size)
+	}
+	if req.RadiusKm != nil {
+		search.RadiusKm = req.RadiusKm
+		if *req.RadiusKm == 0 {
+			search.RadiusKm = nil
+		}
+	}
+	if req.Frequency != nil {
+		search.Frequency = *req.Frequency
+	}
+	if err := validateSavedSearch(search); err != nil {
+		return nil, err
+	}
+
+	return s.repo.UpdateSavedSearch(ctx, search)
+}
+
+func (s *SavedSearchService) Delete(ctx context.Context, userID, searchID uuid.UUID) error {
+	return s.repo.DeleteSavedSearch(ctx, searchID, userID)
+}
+
+// Results returns the active listings matching one of the user's saved searches, newest first
+func (s *SavedSearchService) Results(ctx context.Context, userID, searchID uuid.UUID, limit, offset int) ([]models.FeedItem, error) {
+	if _, err := s.repo.GetSavedSearch(ctx, searchID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.SearchResults(ctx, searchID, limit, offset)
+}
+
+// Run checks due searches until ctx is cancelled
+func (s *SavedSearchService) Run(ctx context.Context) {
+	ticker := time.NewTicker(savedSearchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.checkDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *SavedSearchService) checkDue(ctx context.Context) {
+	until := time.Now().Add(-savedSearchCommitLag)
+
+	for ctx.Err() == nil {
+		claimed, err := s.repo.ClaimDueSearches(ctx, until, savedSearchBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to claim saved searches", "error", err)
+			return
+		}
+
+		for _, due := range claimed {
+			if err := s.check(ctx, due); err != nil {
+				slog.WarnContext(ctx, "failed to check saved search", "search_id", due.Search.SearchID, "error", err)
+			}
+		}
+
+		if len(claimed) < savedSearchBatchSize {
+			return
+		}
+	}
+}
+
+// check sends one alert for all the new listings matching a search, a single listing links to itself
+func (s *SavedSearchService) check(ctx context.Context, due models.SavedSearchDue) error {
+	count, newest, err := s.repo.CountNewMatches(ctx, due.Search.SearchID, due.Since, due.Until)
+	if err != nil || count == 0 || s.notify == nil {
+		return err
+	}
+
+	var relatedProductID *uuid.UUID
+	if count == 1 {
+		relatedProductID = newest
+	}
+
+	s.notify(due.Search.UserID, models.NotificationSavedSearchMatches, models.NotificationParams{
+		"count":     strconv.Itoa(count),
+		"search":    due.Search.Label,
+		"search_id": due.Search.SearchID.String(),
+	}, relatedProductID, nil)
+
+	return nil
+}
+
+// optionalFilter turns a blank filter into no filter
+func optionalFilter(value *string) *string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	return &trimmed
+}
+
+func validateSavedSearch(search *models.SavedSearch) error {
+	if search.Query == "" && search.Category == nil && search.Size == nil {
+		return utils.NewValidation("a saved search needs a query, category or size", utils.FieldError{
+			Field: "query", Rule: "required_without_all", Message: "send a query, category or size",
+		})
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Stable machine-readable error codes returned in the "code" field
+const (
+	CodeBadRequest   = "bad_request"
+	CodeValidation   = "validation_error"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeInternal     = "internal_error"
+)
+
+var statusByCode = map[string]int{
+	CodeBadRequest:   http.StatusBadRequest,
+	CodeValidation:   http.StatusBadRequest,
+	CodeUnauthorized: http.StatusUnauthorized,
+	CodeForbidden:    http.StatusForbidden,
+	CodeNotFound:     http.StatusNotFound,
+	CodeConflict:     http.StatusConflict,
+	CodeInternal:     http.StatusInternalServerError,
+}
+
+// FieldError describes one invalid field in a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// AppError is a domain error that is safe to show to clients.
+// Message is what the client sees, Err is the underlying cause and only ever gets logged.
+type AppError struct {
+	Code    string
+	Message string
+	Details []FieldError
+	Err     error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status the error code maps to
+func (e *AppError) Status() int {
+	if status, ok := statusByCode[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func NewNotFound(message string) *AppError {
+	return &AppError{Code: CodeNotFound, Message: message}
+}
+
+func NewForbidden(message string) *AppError {
+	return &AppError{Code: CodeForbidden, Message: message}
+}
+
+func NewConflict(message string) *AppError {
+	return &AppError{Code: CodeConflict, Message: message}
+}
+
+func NewBadRequest(message string) *AppError {
+	return &AppError{Code: CodeBadRequest, Message: message}
+}
+
+func NewValidation(message string, details ...FieldError) *AppError {
+	return &AppError{Code: CodeValidation, Message: message, Details: details}
+}
+
+// NewInternal wraps an unexpected error, the client only ever sees the generic message
+func NewInternal(message string, err error) *AppError {
+	return &AppError{Code: CodeInternal, Message: message, Err: err}
+}
+
+// IsCode reports whether err is an AppError with the given code
+func IsCode(err error, code string) bool {
+	var appErr *AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
+
+// codeForStatus is used by ErrorResponse so plain status responses still carry a code
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnprocessableEntity:
+		return CodeValidation
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	default:
+		if status >= 500 {
+			return CodeInternal
+		}
+		return CodeBadRequest
+	}
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type logContextKey string
+
+const (
+	requestIDKey logContextKey = "request_id"
+	userIDKey    logContextKey = "user_id"
+	routeKey     logContextKey = "route"
+)
+
+// keys whose values never make it into the logs
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"password_hash": true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"authorization": true,
+	"email":         true,
+	"fcm_token":     true,
+	"secret":        true,
+	"api_key":       true,
+}
+
+var (
+	jwtPattern   = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+const redacted = "[REDACTED]"
+
+// NewLogger builds the app logger, level is debug/info/warn/error and format is json or text
+func NewLogger(w io.Writer, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       parseLevel(level),
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	if strings.ToLower(format) == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// redactAttr hides sensitive keys and scrubs tokens/emails that slipped into free text
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	return slog.Attr{Key: a.Key, Value: redactValue(a.Value)}
+}
+
+// redactValue scrubs strings, errors and Stringers, and the attrs of groups
+func redactValue(v slog.Value) slog.Value {
+	v = v.Resolve()
+
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.StringValue(RedactString(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		scrubbed := make([]slog.Attr, len(attrs))
+		for i, attr := range attrs {
+			scrubbed[i] = redactAttr(nil, attr)
+		}
+		return slog.GroupValue(scrubbed...)
+	case slog.KindAny:
+		switch value := v.Any().(type) {
+		case error:
+			return slog.StringValue(RedactString(value.Error()))
+		case fmt.Stringer:
+			return slog.StringValue(RedactString(value.String()))
+		}
+	}
+
+	return v
+}
+
+// RedactString masks anything that looks like a JWT or an email address
+func RedactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	return emailPattern.ReplaceAllString(s, redacted)
+}
+
+// contextHandler adds the request id, user id, route and trace stored on the context to every entry
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		for _, key := range []logContextKey{requestIDKey, userIDKey, routeKey} {
+			if v, ok := ctx.Value(key).(string); ok && v != "" {
+				r.AddAttrs(slog.String(string(key), v))
+			}
+		}
+
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			r.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// WithRequestID stores the request id on the context for logging
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request id stored by WithRequestID
+func RequestIDFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(requestIDKey).(string)
+	return v
+}
+
+// WithUserID stores the authenticated user's id on the context for logging
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// WithRoute stores the matched route pattern on the context for logging
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey, route)
+}
@@ -1,17 +1,23 @@
 package utils
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
 
 //model for response properties
 
 type Response struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Data    any    `json:"data,omitempty"`
+	Success bool         `json:"success"`
+	Message string       `json:"message"`
+	Code    string       `json:"code,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
+	Data    any          `json:"data,omitempty"`
 }
 
-
-//Function to relay if the operation was a success
+// Function to relay if the operation was a success
 func SuccessResponse(ctx *gin.Context, status int, message string, data any) {
 	ctx.JSON(status, Response{
 		Success: true,
@@ -20,10 +26,36 @@ func SuccessResponse(ctx *gin.Context, status int, message string, data any) {
 	})
 }
 
-//function to relay if there was an error
+// function to relay if there was an error
 func ErrorResponse(ctx *gin.Context, status int, message string) {
 	ctx.JSON(status, Response{
 		Success: false,
 		Message: message,
+		Code:    codeForStatus(status),
+	})
+}
+
+// maps any error onto the response, domain errors keep their message and anything else becomes a generic 500
+func HandleError(ctx *gin.Context, err error) {
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		appErr = NewInternal("internal server error", err)
+	}
+
+	if appErr.Code == CodeInternal {
+		slog.ErrorContext(ctx.Request.Context(), "request failed", "error", err)
+		ctx.Error(err)
+	}
+
+	ctx.JSON(appErr.Status(), Response{
+		Success: false,
+		Message: appErr.Message,
+		Code:    appErr.Code,
+		Details: appErr.Details,
 	})
 }
+
+// relays a failed ShouldBind as a validation error with per-field details
+func BindingErrorResponse(ctx *gin.Context, err error) {
+	HandleError(ctx, BindingError(err))
+}